@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+const (
+	defaultAnalyzeTimeout = 2 * time.Second
+	maxAnalyzeTimeout     = 10 * time.Second
+)
+
+// analyzeRequest is the body of POST /puzzle/analyze. Puzzle is read like
+// the puzzle of an upload, and Timeout bounds the search for solutions.
+type analyzeRequest struct {
+	Puzzle  json.RawMessage `json:"puzzle"`
+	Timeout string          `json:"timeout"`
+}
+
+// analysis tells whether a puzzle being edited is valid and solvable.
+// Solutions is exact only when Complete is set, otherwise the search ran
+// out of time and it is the number found until then.
+type analysis struct {
+	Valid     bool          `json:"valid"`
+	Error     string        `json:"error,omitempty"`
+	Strokes   int           `json:"strokes"`
+	Degrees   []pointDegree `json:"degrees,omitempty"`
+	OddPoints []uint16      `json:"odd_points"`
+	Connected bool          `json:"connected"`
+	Solutions int           `json:"solutions"`
+	Complete  bool          `json:"complete"`
+	// Solution is one of the solutions, when any was found.
+	Solution solver.Solution `json:"solution,omitempty"`
+}
+
+// pointDegree is a solver.Degree with the keys of the API.
+type pointDegree struct {
+	Point      uint16 `json:"point"`
+	Undirected int    `json:"undirected"`
+	In         int    `json:"in"`
+	Out        int    `json:"out"`
+}
+
+// analyzePuzzle checks a puzzle without saving it, for the editor: an
+// invalid puzzle is a 200 with Valid unset and the reason in Error.
+func (this *Server) analyzePuzzle(w http.ResponseWriter, r *http.Request) error {
+	var req analyzeRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		return httpErrorf(http.StatusBadRequest, "invalid request: %v", err)
+	}
+	timeout := defaultAnalyzeTimeout
+	if req.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(req.Timeout); err != nil || timeout <= 0 {
+			return httpErrorf(http.StatusBadRequest, "invalid timeout %q", req.Timeout)
+		}
+		if timeout > maxAnalyzeTimeout {
+			timeout = maxAnalyzeTimeout
+		}
+	}
+	if this.solveTimeout > 0 && timeout > this.solveTimeout {
+		timeout = this.solveTimeout
+	}
+	if len(req.Puzzle) == 0 {
+		return httpErrorf(http.StatusBadRequest, "request has no puzzle")
+	}
+
+	puzzle, err := decodeUploadedPuzzle(req.Puzzle)
+	if err != nil {
+		return writeJson(w, http.StatusOK, analysis{Error: err.Error(), OddPoints: []uint16{}})
+	}
+	if err := this.acquireSlot(w); err != nil {
+		return err
+	}
+	defer this.slots.release()
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	return writeJson(w, http.StatusOK, analyze(ctx, puzzle, this.metrics, this.maxNodes))
+}
+
+// analyze looks at the degrees and connectivity of a valid puzzle and
+// counts its solutions until ctx is done or maxNodes are explored.
+func analyze(ctx context.Context, puzzle *solver.Puzzle, metrics *serverMetrics, maxNodes int64) analysis {
+	game := solver.NewGameState(puzzle)
+	a := analysis{
+		Valid:     true,
+		Strokes:   game.Remaining(),
+		OddPoints: []uint16{},
+		Connected: isConnected(puzzle),
+	}
+	for _, d := range game.Degrees() {
+		a.Degrees = append(a.Degrees, pointDegree(d))
+		if (d.Undirected+d.In+d.Out)%2 == 1 {
+			a.OddPoints = append(a.OddPoints, d.Point)
+		}
+	}
+
+	counted := &solver.Progress{MaxNodes: maxNodes}
+	done := metrics.search(counted)
+	n, _, err := solver.CountProgress(ctx, puzzle, counted)
+	done()
+	a.Solutions, a.Complete = n, err == nil
+	if n == 0 {
+		return a
+	}
+	// Find one to show, stopping at the first.
+	findCtx, found := context.WithCancel(ctx)
+	defer found()
+	var first sync.Once
+	progress := &solver.Progress{MaxNodes: maxNodes, OnSolution: func(s solver.Solution) {
+		first.Do(func() { a.Solution = s })
+		found()
+	}}
+	done = metrics.search(progress)
+	solver.SolveProgress(findCtx, puzzle, progress)
+	done()
+	return a
+}
+
+// isConnected reports whether every edge can be reached from any other.
+func isConnected(puzzle *solver.Puzzle) bool {
+	parent := map[uint16]uint16{}
+	var find func(uint16) uint16
+	find = func(p uint16) uint16 {
+		if _, ok := parent[p]; !ok {
+			parent[p] = p
+		}
+		if parent[p] != p {
+			parent[p] = find(parent[p])
+		}
+		return parent[p]
+	}
+	for _, e := range puzzle.Edges {
+		parent[find(e.PointA)] = find(e.PointB)
+	}
+	roots := map[uint16]bool{}
+	for p := range parent {
+		roots[find(p)] = true
+	}
+	return len(roots) <= 1
+}
+
+// decodeUploadedPuzzle reads a puzzle given as JSON, or as a JSON string
+// in any of the formats of the convert command, and validates it.
+func decodeUploadedPuzzle(raw json.RawMessage) (*solver.Puzzle, error) {
+	data := []byte(raw)
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		data = []byte(text)
+	}
+	puzzle, err := solver.NewPuzzleFromBytes(data)
+	if err == nil {
+		err = puzzle.Validate()
+	}
+	if err != nil {
+		return nil, errors.New("invalid puzzle: " + err.Error())
+	}
+	return puzzle, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decodeAnalysis(t *testing.T, s *Server, body string) analysis {
+	t.Helper()
+	w := request(t, s, "POST", "/puzzle/analyze", body)
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	var a analysis
+	if err := json.Unmarshal(w.Body.Bytes(), &a); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestAnalyzePuzzle(t *testing.T) {
+	s := newTestServer(t, ServerOptions{Workers: 1})
+	a := decodeAnalysis(t, s, `{"puzzle": "1-2-3-1\n3-4"}`)
+	if !a.Valid || !a.Connected || a.Strokes != 4 || !a.Complete {
+		t.Errorf("Unexpected analysis %+v", a)
+	}
+	if !reflect.DeepEqual(a.OddPoints, []uint16{3, 4}) {
+		t.Errorf("Expected points 3 and 4 odd, got %v", a.OddPoints)
+	}
+	if a.Solutions != 4 || len(a.Solution) != 5 {
+		t.Errorf("Expected 4 solutions and one of them, got %d and %v", a.Solutions, a.Solution)
+	}
+	w := request(t, s, "POST", "/puzzle/analyze", `{"puzzle": "1>2"}`)
+	if expected := `"degrees":[{"point":1,"undirected":0,"in":0,"out":1},{"point":2,"undirected":0,"in":1,"out":0}]`; !strings.Contains(w.Body.String(), expected) {
+		t.Errorf("Expected %s, got %s", expected, w.Body.String())
+	}
+
+	a = decodeAnalysis(t, s, `{"puzzle": {"Edges": [{"PointA": 1, "PointB": 2, "Count": 1}, {"PointA": 3, "PointB": 4, "Count": 1}]}}`)
+	if a.Connected || a.Solutions != 0 || a.Solution != nil {
+		t.Errorf("Expected two apart edges unsolvable, got %+v", a)
+	}
+}
+
+func TestAnalyzeInvalidPuzzle(t *testing.T) {
+	s := newTestServer(t, ServerOptions{Workers: 1})
+	a := decodeAnalysis(t, s, `{"puzzle": {"Edges": [{"PointA": 1, "PointB": 2, "Count": 0}]}}`)
+	if a.Valid || a.Error != "invalid puzzle: edge 1-2: count must be positive" {
+		t.Errorf("Expected the validation error, got %+v", a)
+	}
+	assertJsonError(t, request(t, s, "POST", "/puzzle/analyze", `{}`), 400, "no puzzle")
+	assertJsonError(t, request(t, s, "POST", "/puzzle/analyze", `{"puzzle": "1-2", "timeout": "x"}`), 400, "invalid timeout")
+}
+
+func TestAnalyzeTimeout(t *testing.T) {
+	s := newTestServer(t, ServerOptions{Workers: 1})
+	level54, err := loadPuzzle("puzzles/level54.json", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(map[string]interface{}{"puzzle": level54, "timeout": "50ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := decodeAnalysis(t, s, string(data))
+	if !a.Valid || a.Complete {
+		t.Errorf("Expected the search cut short, got %+v", a)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+const (
+	defaultCacheEntries   = 256
+	defaultCacheSolutions = 1000000
+)
+
+// cacheEntry is what is known of the solutions of a puzzle.
+type cacheEntry struct {
+	Hash  string `json:"hash"`
+	Count int    `json:"count"`
+	// Solutions is nil when only the count is kept, for count searches or
+	// puzzles with more solutions than the cache holds.
+	Solutions solver.Solutions `json:"solutions,omitempty"`
+}
+
+// solveCache keeps the results of searches by the canonical hash of their
+// puzzle, dropping the least recently used ones once it holds more than
+// maxEntries puzzles or maxSolutions solutions. With a dir, entries are
+// also written there and read back on misses, so that they survive
+// restarts; the directory itself is not limited.
+type solveCache struct {
+	maxEntries   int
+	maxSolutions int
+	dir          string
+	hits         atomic.Int64
+	misses       atomic.Int64
+
+	mu        sync.Mutex
+	lru       *list.List
+	entries   map[string]*list.Element
+	solutions int
+	// names is the hash last seen for every puzzle name, to drop the
+	// results of a puzzle once its file changes.
+	names map[string]string
+}
+
+func newSolveCache(maxEntries, maxSolutions int, dir string) *solveCache {
+	return &solveCache{
+		maxEntries:   maxEntries,
+		maxSolutions: maxSolutions,
+		dir:          dir,
+		lru:          list.New(),
+		entries:      map[string]*list.Element{},
+		names:        map[string]string{},
+	}
+}
+
+// get looks a puzzle up, only counting as a hit an entry with the
+// solutions when they are needed.
+func (this *solveCache) get(hash string, needSolutions bool) (*cacheEntry, bool) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	var entry *cacheEntry
+	if el, ok := this.entries[hash]; ok {
+		this.lru.MoveToFront(el)
+		entry = el.Value.(*cacheEntry)
+	} else if entry = this.load(hash); entry != nil {
+		this.add(entry)
+	}
+	if entry == nil || needSolutions && entry.Solutions == nil {
+		this.misses.Add(1)
+		return nil, false
+	}
+	this.hits.Add(1)
+	return entry, true
+}
+
+// put stores the result of a complete search. An entry with the solutions
+// is never replaced by one with the count only.
+func (this *solveCache) put(entry *cacheEntry) {
+	if len(entry.Solutions) > this.maxSolutions {
+		entry = &cacheEntry{Hash: entry.Hash, Count: entry.Count}
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if el, ok := this.entries[entry.Hash]; ok {
+		if entry.Solutions == nil && el.Value.(*cacheEntry).Solutions != nil {
+			this.lru.MoveToFront(el)
+			return
+		}
+		this.remove(el)
+	}
+	this.add(entry)
+	this.store(entry)
+}
+
+// seen records the hash of a named puzzle, dropping the results of its
+// previous content when no other name has it.
+func (this *solveCache) seen(name, hash string) {
+	name = strings.TrimSuffix(name, ".json")
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	previous, ok := this.names[name]
+	this.names[name] = hash
+	if ok && previous != hash {
+		this.drop(previous)
+	}
+}
+
+// forget drops the results of a named puzzle, when its file is replaced or
+// removed.
+func (this *solveCache) forget(name string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if hash, ok := this.names[name]; ok {
+		delete(this.names, name)
+		this.drop(hash)
+	}
+}
+
+// drop removes the entry of hash unless a known puzzle still has it. The
+// caller holds this.mu.
+func (this *solveCache) drop(hash string) {
+	for _, h := range this.names {
+		if h == hash {
+			return
+		}
+	}
+	if el, ok := this.entries[hash]; ok {
+		this.remove(el)
+	}
+	if this.dir != "" {
+		os.Remove(this.file(hash))
+	}
+}
+
+// add puts an entry in memory, evicting the oldest ones past the limits.
+// The caller holds this.mu.
+func (this *solveCache) add(entry *cacheEntry) {
+	this.entries[entry.Hash] = this.lru.PushFront(entry)
+	this.solutions += len(entry.Solutions)
+	for this.lru.Len() > 1 && (this.lru.Len() > this.maxEntries || this.solutions > this.maxSolutions) {
+		this.remove(this.lru.Back())
+	}
+}
+
+func (this *solveCache) remove(el *list.Element) {
+	entry := this.lru.Remove(el).(*cacheEntry)
+	delete(this.entries, entry.Hash)
+	this.solutions -= len(entry.Solutions)
+}
+
+func (this *solveCache) file(hash string) string {
+	return filepath.Join(this.dir, hash+".json")
+}
+
+// load reads an entry from the directory, nil when there is none.
+func (this *solveCache) load(hash string) *cacheEntry {
+	if this.dir == "" {
+		return nil
+	}
+	data, err := os.ReadFile(this.file(hash))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("cache: %v", err)
+		}
+		return nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Hash != hash {
+		log.Printf("cache: ignoring invalid %s", this.file(hash))
+		return nil
+	}
+	return &entry
+}
+
+// store writes an entry to the directory. Failing only costs a search
+// after a restart, so errors are logged.
+func (this *solveCache) store(entry *cacheEntry) {
+	if this.dir == "" {
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err == nil {
+		err = os.MkdirAll(this.dir, 0755)
+	}
+	if err == nil {
+		err = writeFileAtomic(this.file(entry.Hash), data)
+	}
+	if err != nil {
+		log.Printf("cache: %v", err)
+	}
+}
+
+// solutions returns the solutions of the puzzle of a request from the
+// cache, or solves it within the limits of the request and keeps them.
+func (this *Server) solutions(w http.ResponseWriter, r *http.Request, puzzle *solver.Puzzle) (solver.Solutions, error) {
+	limits, err := this.limits(r)
+	if err != nil {
+		return nil, err
+	}
+	hash := puzzle.CanonicalHash()
+	this.cache.seen(r.PathValue("filename"), hash)
+	if entry, ok := this.cache.get(hash, true); ok {
+		if err := limits.check(len(entry.Solutions)); err != nil {
+			return nil, this.limitError(err, limits)
+		}
+		return entry.Solutions, nil
+	}
+
+	if err := this.acquireSlot(w); err != nil {
+		return nil, err
+	}
+	defer this.slots.release()
+	progress := limits.apply(&solver.Progress{})
+	ctx, cancel := limits.context(r.Context())
+	defer cancel()
+	done := this.metrics.search(progress)
+	found, _, err := solver.SolveProgress(ctx, puzzle, progress)
+	done()
+	if err != nil {
+		return nil, this.limitError(err, limits)
+	}
+	solutions := *found
+	this.cache.put(&cacheEntry{Hash: hash, Count: len(solutions), Solutions: solutions})
+	return solutions, nil
+}
+
+// checkETag sets the ETag of a response and reports whether the client
+// already has it, in which case a 304 is sent and nothing else must be.
+func checkETag(w http.ResponseWriter, r *http.Request, etag string) bool {
+	etag = `"` + etag + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// imageETag identifies an image of a puzzle: unlike the canonical hash, it
+// changes with the labels and positions of the points, and with the query.
+func imageETag(puzzle *solver.Puzzle, query string) string {
+	data, _ := json.Marshal(puzzle)
+	sum := sha256.Sum256(append(append(data, 0), query...))
+	return hex.EncodeToString(sum[:16])
+}
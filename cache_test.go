@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+func entry(hash string, solutions int) *cacheEntry {
+	e := &cacheEntry{Hash: hash, Count: solutions}
+	for k := 0; k < solutions; k++ {
+		e.Solutions = append(e.Solutions, solver.Solution{1, 2})
+	}
+	return e
+}
+
+func TestCacheEviction(t *testing.T) {
+	c := newSolveCache(2, 10, "")
+	c.put(entry("a", 1))
+	c.put(entry("b", 1))
+	c.get("a", true)
+	c.put(entry("c", 1))
+	if _, ok := c.get("b", true); ok {
+		t.Error("Expected b, the least recently used, to be evicted")
+	}
+	if _, ok := c.get("a", true); !ok {
+		t.Error("Expected a to be kept")
+	}
+
+	c.put(entry("d", 9))
+	if _, ok := c.get("c", true); ok {
+		t.Error("Expected c to be evicted past 10 solutions")
+	}
+	if _, ok := c.get("d", true); !ok {
+		t.Error("Expected d to be kept")
+	}
+
+	// Too many solutions to keep, only the count is.
+	c.put(entry("e", 11))
+	if e, ok := c.get("e", false); !ok || e.Count != 11 || e.Solutions != nil {
+		t.Errorf("Expected the count only, got %+v", e)
+	}
+	if _, ok := c.get("e", true); ok {
+		t.Error("Expected a miss when the solutions are needed")
+	}
+	if c.hits.Load() != 4 || c.misses.Load() != 3 {
+		t.Errorf("Expected 4 hits and 3 misses, got %d and %d", c.hits.Load(), c.misses.Load())
+	}
+}
+
+func TestCacheKeepsSolutions(t *testing.T) {
+	c := newSolveCache(2, 10, "")
+	c.put(entry("a", 2))
+	c.put(&cacheEntry{Hash: "a", Count: 2})
+	if _, ok := c.get("a", true); !ok {
+		t.Error("Expected the solutions to be kept over a count")
+	}
+}
+
+func TestCacheDir(t *testing.T) {
+	dir := t.TempDir()
+	newSolveCache(2, 10, dir).put(entry("a", 2))
+	c := newSolveCache(2, 10, dir)
+	if e, ok := c.get("a", true); !ok || len(e.Solutions) != 2 {
+		t.Errorf("Expected the entry back from the directory, got %+v", e)
+	}
+
+	c.seen("house.json", "a")
+	c.seen("house", "b")
+	if _, ok := newSolveCache(2, 10, dir).get("a", false); ok {
+		t.Error("Expected the entry of the previous content to be removed")
+	}
+}
+
+func TestCacheSharedHash(t *testing.T) {
+	c := newSolveCache(2, 10, "")
+	c.put(entry("a", 1))
+	c.seen("house", "a")
+	c.seen("copy", "a")
+	c.forget("house")
+	if _, ok := c.get("a", true); !ok {
+		t.Error("Expected the entry to be kept for the copy")
+	}
+	c.forget("copy")
+	if _, ok := c.get("a", true); ok {
+		t.Error("Expected the entry to be dropped")
+	}
+}
+
+func TestSolveETag(t *testing.T) {
+	s := newStoreServer(t)
+	addByHand(t, s, "shed")
+	w := request(t, s, "GET", "/puzzle/solve/shed", "")
+	etag := w.Header().Get("ETag")
+	if w.Code != 200 || etag == "" {
+		t.Fatalf("Expected solutions with an ETag, got %d %q", w.Code, etag)
+	}
+	var solutions solver.Solutions
+	if err := json.Unmarshal(w.Body.Bytes(), &solutions); err != nil || len(solutions) != 88 {
+		t.Fatalf("Expected 88 solutions, got %d %v", len(solutions), err)
+	}
+	if request(t, s, "GET", "/puzzle/solve/shed", "").Body.String() != w.Body.String() {
+		t.Error("Expected the same solutions from the cache")
+	}
+	if s.cache.hits.Load() != 1 {
+		t.Errorf("Expected a cache hit, got %d", s.cache.hits.Load())
+	}
+
+	r := httptest.NewRequest("GET", "/puzzle/solve/shed", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != 304 || w.Body.Len() != 0 {
+		t.Errorf("Expected 304, got %d %q", w.Code, w.Body.String())
+	}
+
+	change(t, s, "PUT", "/puzzles/shed", `{"puzzle": "1-2-3"}`)
+	w = request(t, s, "GET", "/puzzle/solve/shed", "")
+	if w.Header().Get("ETag") == etag || strings.Count(w.Body.String(), "[") != 3 {
+		t.Errorf("Expected the solutions of the new puzzle, got %s", w.Body.String())
+	}
+}
+
+func TestJobFromCache(t *testing.T) {
+	s := newTestServer(t, ServerOptions{Workers: 1})
+	request(t, s, "GET", "/puzzle/solve/house", "")
+	job := waitForJob(t, s, decodeJob(t, request(t, s, "POST", "/jobs", `{"puzzle": "house"}`)).ID, "")
+	if job.Status != jobDone || job.Count != 88 || len(job.Solutions) != 88 || job.Progress.Nodes != 0 {
+		t.Errorf("Expected 88 solutions from the cache, got %+v", job)
+	}
+}
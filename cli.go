@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+const progName = "OneTDraw-Solver"
+
+// Exit codes of the commands. A puzzle without solutions is not an error,
+// but scripts need to tell it apart from success.
+const (
+	exitOK         = 0
+	exitError      = 1
+	exitUsage      = 2
+	exitNoSolution = 3
+)
+
+// errNoSolution is returned by commands that found no solution.
+var errNoSolution = errors.New("no solution")
+
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(args []string, stdin io.Reader, stdout io.Writer) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"solve", "solve [flags] puzzle", "Print every solution of a puzzle", solveCommand},
+		{"count", "count [flags] puzzle", "Print the number of solutions of a puzzle", countCommand},
+		{"validate", "validate [flags] puzzle...", "Check that puzzles can be read and are well formed", validateCommand},
+		{"render", "render [flags] puzzle", "Draw a puzzle or one of its solutions as SVG or GIF", renderCommand},
+		{"convert", "convert [flags] input output", "Convert a puzzle between file formats", convertCommand},
+		{"play", "play [flags] puzzle", "Play a puzzle in the terminal", playCommand},
+		{"repl", "repl [flags] puzzle", "Explore a puzzle step by step with commands", replCommand},
+		{"serve", "serve [flags]", "Start the web interface", serveCommand},
+		{"bench", "bench [flags] puzzle...", "Time the solver on puzzles", benchCommand},
+		{"batch", "batch [flags] directory|index.json", "Solve every puzzle of a directory or index and summarise", batchCommand},
+		{"help", "help [command]", "Show the help of a command", helpCommand},
+	}
+}
+
+func findCommand(name string) *command {
+	for k := range commands {
+		if commands[k].name == name {
+			return &commands[k]
+		}
+	}
+	return nil
+}
+
+// run executes the command named by args[0] and returns the exit code.
+// Puzzle arguments may be "-" to read stdin.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		printUsage(stderr)
+		return exitUsage
+	}
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintf(stderr, "%s: unknown command %q\n\n", progName, args[0])
+		printUsage(stderr)
+		return exitUsage
+	}
+
+	err := cmd.run(args[1:], stdin, stdout)
+	var usageErr usageError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.Is(err, errNoSolution):
+		fmt.Fprintf(stderr, "%s: %v\n", cmd.name, err)
+		return exitNoSolution
+	case errors.As(err, &usageErr):
+		if !usageErr.shown {
+			fmt.Fprintf(stderr, "%v\nusage: %s %s\n", err, progName, cmd.usage)
+		}
+		return exitUsage
+	default:
+		fmt.Fprintf(stderr, "%s: %v\n", cmd.name, err)
+		return exitError
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "usage: %s <command> [flags] [arguments]\n\ncommands:\n", progName)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-9s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "\nRun '%s help <command>' for the flags of a command.\n", progName)
+}
+
+func helpCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		printUsage(stdout)
+		return nil
+	}
+	cmd := findCommand(args[0])
+	if cmd == nil || cmd.name == "help" {
+		return usagef("unknown command %q", args[0])
+	}
+	// Every command prints its usage and flags on -h.
+	return cmd.run([]string{"-h"}, stdin, stdout)
+}
+
+// usageError reports a command called with wrong flags or arguments. shown
+// is set when the flag package already printed the problem and the usage.
+type usageError struct {
+	err   error
+	shown bool
+}
+
+func (this usageError) Error() string { return this.err.Error() }
+func (this usageError) Unwrap() error { return this.err }
+
+func usagef(format string, args ...interface{}) error {
+	return usageError{err: fmt.Errorf(format, args...)}
+}
+
+// newFlagSet returns the flag set of the named command, printing its usage
+// line, summary and flags on -h or bad flags.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		if cmd := findCommand(name); cmd != nil {
+			fmt.Fprintf(w, "usage: %s %s\n\n%s.\n", progName, cmd.usage, cmd.summary)
+		}
+		fmt.Fprintln(w, "\nflags:")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args, marking failures as usage errors.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return usageError{err: err, shown: true}
+	}
+	return nil
+}
+
+// loadPuzzle reads a puzzle from filename, or from stdin when filename is
+// "-". format names the codec, like the -from flag of convert; when empty
+// the file extension picks it, or else the content is sniffed.
+func loadPuzzle(filename, format string, stdin io.Reader) (*solver.Puzzle, error) {
+	var data []byte
+	var err error
+	if filename == "-" {
+		if stdin == nil {
+			stdin = os.Stdin
+		}
+		data, err = io.ReadAll(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stdin: %w", err)
+		}
+		filename = "stdin"
+	} else if data, err = os.ReadFile(filename); err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", filename, err)
+	}
+	return parsePuzzle(filename, format, data)
+}
+
+func parsePuzzle(filename, format string, data []byte) (*solver.Puzzle, error) {
+	var puzzle *solver.Puzzle
+	var err error
+	if format != "" {
+		codec, codecErr := solver.CodecForFile("puzzle." + strings.TrimPrefix(format, "."))
+		if codecErr != nil {
+			return nil, codecErr
+		}
+		puzzle, err = codec.Decode(data)
+	} else if codec, codecErr := solver.CodecForFile(filename); codecErr == nil {
+		// Known extensions pick their codec, anything else is sniffed.
+		puzzle, err = codec.Decode(data)
+	} else {
+		puzzle, err = solver.NewPuzzleFromBytes(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse puzzle %s: %w", filename, err)
+	}
+	if err := puzzle.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid puzzle %s: %w", filename, err)
+	}
+	return puzzle, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRunExitCodes(t *testing.T) {
+	tests := []struct {
+		args     []string
+		stdin    string
+		expected int
+	}{
+		{[]string{"count", "puzzles/house.json"}, "", exitOK},
+		{[]string{"count", "puzzles/jose.json"}, "", exitNoSolution},
+		{[]string{"solve", "puzzles/jose.json"}, "", exitNoSolution},
+		{[]string{"count", "-"}, "1-2\n2-3\n3-1\n", exitOK},
+		{[]string{"count", "puzzles/missing.json"}, "", exitError},
+		{[]string{"count"}, "", exitUsage},
+		{[]string{"count", "-bogus", "puzzles/house.json"}, "", exitUsage},
+		{[]string{"solve", "-output", "yaml", "puzzles/house.json"}, "", exitUsage},
+		{[]string{"nope"}, "", exitUsage},
+		{[]string{}, "", exitUsage},
+		{[]string{"help"}, "", exitOK},
+		{[]string{"help", "render"}, "", exitOK},
+		{[]string{"help", "nope"}, "", exitUsage},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(test.args, strings.NewReader(test.stdin), &stdout, &stderr)
+		if code != test.expected {
+			t.Errorf("%v: expected exit code %d, got %d\n%s", test.args, test.expected, code, stderr.String())
+		}
+	}
+}
+
+func TestRunSolveFromStdin(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"solve", "-from", "csv", "-output", "ndjson", "-"}, strings.NewReader("1,2\n2,3\n3,1,1,3\n"), &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("Expected success, got %d: %s", code, stderr.String())
+	}
+	if lines := strings.Count(stdout.String(), "\n"); lines != 3 {
+		t.Errorf("Expected 3 solutions, got %d:\n%s", lines, stdout.String())
+	}
+}
+
+func TestValidateCommand(t *testing.T) {
+	var out bytes.Buffer
+	if err := validateCommand([]string{"puzzles/house.json", "puzzles/square.json"}, nil, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Count(out.String(), "ok ") != 2 {
+		t.Errorf("Expected both puzzles reported ok, got %s", out.String())
+	}
+
+	out.Reset()
+	err := validateCommand([]string{"-", "puzzles/house.json"}, strings.NewReader("1-1\n"), &out)
+	if err == nil {
+		t.Fatal("Expected error for a puzzle with a loop")
+	}
+	if !strings.Contains(out.String(), "FAIL") {
+		t.Errorf("Expected the invalid puzzle reported, got %s", out.String())
+	}
+}
+
+func TestBenchCommand(t *testing.T) {
+	var out bytes.Buffer
+	if err := benchCommand([]string{"-runs", "1", "-count_only", "puzzles/regular_triangle.json"}, nil, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "puzzles/regular_triangle.json") || !strings.Contains(lines[1], " 6 ") {
+		t.Errorf("Unexpected bench output:\n%s", out.String())
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+// Statuses of a puzzle in a batch run.
+const (
+	batchOK         = "ok"
+	batchNoSolution = "no_solution"
+	batchTimeout    = "timeout"
+	batchError      = "error"
+)
+
+type batchEntry struct {
+	Name string
+	File string
+}
+
+type batchResult struct {
+	Name      string  `json:"name"`
+	File      string  `json:"file"`
+	Status    string  `json:"status"`
+	Solutions int     `json:"solutions"`
+	Nodes     int64   `json:"nodes"`
+	TimeMs    float64 `json:"time_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// batchCommand implements `batch [flags] directory|index.json`, solving
+// every puzzle of a directory or of a puzzles.json index and printing a
+// summary. It fails when a puzzle could not be loaded or timed out.
+func batchCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("batch")
+	countOnly := fs.Bool("count_only", false, "Count the solutions instead of collecting them")
+	timeout := fs.Duration("timeout", time.Minute, "Time limit for each puzzle")
+	parallel := fs.Int("parallel", runtime.NumCPU(), "Number of puzzles solved at the same time")
+	jsonFile := fs.String("json", "", "Also write the results as JSON to this file, - for stdout instead of the table")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("expected a directory or a puzzles.json index")
+	}
+	if *parallel < 1 {
+		return usagef("-parallel must be at least 1")
+	}
+
+	entries, err := listBatch(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	results := runBatch(entries, *countOnly, *timeout, *parallel)
+
+	if *jsonFile == "-" {
+		if err := writeBatchJson(stdout, results); err != nil {
+			return err
+		}
+	} else {
+		if err := writeBatchTable(stdout, results); err != nil {
+			return err
+		}
+		if *jsonFile != "" {
+			f, err := os.Create(*jsonFile)
+			if err != nil {
+				return err
+			}
+			if err := writeBatchJson(f, results); err != nil {
+				f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+		}
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Status == batchTimeout || r.Status == batchError {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d puzzles failed", failed, len(results))
+	}
+	return nil
+}
+
+// listBatch returns the puzzles of a directory, in name order, or the ones
+// listed in a puzzles.json index. Index entries are looked up in the
+// puzzles directory next to the index, like the web server does, and then
+// next to the index itself.
+func listBatch(path string) ([]batchEntry, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return readBatchIndex(path)
+	}
+
+	files, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []batchEntry
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		if _, err := solver.CodecForFile(f.Name()); err != nil {
+			continue
+		}
+		entries = append(entries, batchEntry{Name: f.Name(), File: filepath.Join(path, f.Name())})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no puzzles in %s", path)
+	}
+	return entries, nil
+}
+
+func readBatchIndex(path string) ([]batchEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var index []struct {
+		Name     string
+		JsonFile string
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("invalid index %s: %w", path, err)
+	}
+	dir := filepath.Dir(path)
+	entries := make([]batchEntry, 0, len(index))
+	for _, p := range index {
+		file := filepath.Join(dir, "puzzles", p.JsonFile)
+		if _, err := os.Stat(file); err != nil {
+			file = filepath.Join(dir, p.JsonFile)
+		}
+		entries = append(entries, batchEntry{Name: p.Name, File: file})
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no puzzles in %s", path)
+	}
+	return entries, nil
+}
+
+// runBatch solves the entries on parallel workers, returning the results in
+// the order of entries.
+func runBatch(entries []batchEntry, countOnly bool, timeout time.Duration, parallel int) []batchResult {
+	results := make([]batchResult, len(entries))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range jobs {
+				results[k] = solveBatchEntry(entries[k], countOnly, timeout)
+			}
+		}()
+	}
+	for k := range entries {
+		jobs <- k
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func solveBatchEntry(entry batchEntry, countOnly bool, timeout time.Duration) batchResult {
+	result := batchResult{Name: entry.Name, File: entry.File}
+	puzzle, err := loadPuzzle(entry.File, "", nil)
+	if err != nil {
+		result.Status = batchError
+		result.Error = err.Error()
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	var stats solver.Stats
+	if countOnly {
+		result.Solutions, stats, err = solver.CountContext(ctx, puzzle)
+	} else {
+		var solutions *solver.Solutions
+		solutions, stats, err = solver.SolveContext(ctx, puzzle)
+		result.Solutions = len(*solutions)
+	}
+	result.TimeMs = float64(time.Since(start).Microseconds()) / 1000
+	result.Nodes = stats.Nodes
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		result.Status = batchTimeout
+		result.Error = fmt.Sprintf("stopped after %v", timeout)
+	case err != nil:
+		result.Status = batchError
+		result.Error = err.Error()
+	case result.Solutions == 0:
+		result.Status = batchNoSolution
+	default:
+		result.Status = batchOK
+	}
+	return result
+}
+
+func writeBatchTable(w io.Writer, results []batchResult) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PUZZLE\tSOLUTIONS\tTIME\tNODES\tSTATUS")
+	counts := map[string]int{}
+	for _, r := range results {
+		counts[r.Status]++
+		status := r.Status
+		if r.Error != "" {
+			status += ": " + r.Error
+		}
+		elapsed := time.Duration(r.TimeMs * float64(time.Millisecond)).Round(time.Microsecond)
+		fmt.Fprintf(tw, "%s\t%d\t%v\t%d\t%s\n", r.Name, r.Solutions, elapsed, r.Nodes, status)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	var summary []string
+	for _, status := range []string{batchOK, batchNoSolution, batchTimeout, batchError} {
+		if counts[status] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[status], status))
+		}
+	}
+	_, err := fmt.Fprintf(w, "\n%d puzzles: %s\n", len(results), strings.Join(summary, ", "))
+	return err
+}
+
+func writeBatchJson(w io.Writer, results []batchResult) error {
+	b, err := json.MarshalIndent(results, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
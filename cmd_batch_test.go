@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBatchCommandDirectory(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"triangle.otd": "1-2\n2-3\n3-1\n",
+		"line.otd":     "1-2 x3\n",
+		"broken.json":  "{",
+		"notes.txt":    "not a puzzle",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	err := batchCommand([]string{"-json", "-", dir}, nil, &out)
+	if err == nil {
+		t.Error("Expected error for the broken puzzle")
+	}
+	var results []batchResult
+	if err := json.Unmarshal(out.Bytes(), &results); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, out.String())
+	}
+	expected := []struct {
+		name      string
+		status    string
+		solutions int
+	}{
+		{"broken.json", batchError, 0},
+		{"line.otd", batchOK, 2},
+		{"triangle.otd", batchOK, 6},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %+v", len(expected), results)
+	}
+	for k, e := range expected {
+		r := results[k]
+		if r.Name != e.name || r.Status != e.status || r.Solutions != e.solutions {
+			t.Errorf("Expected %s %s with %d solutions, got %+v", e.name, e.status, e.solutions, r)
+		}
+		if r.Status == batchOK && r.Nodes == 0 {
+			t.Errorf("%s: expected explored nodes to be counted", r.Name)
+		}
+	}
+}
+
+func TestBatchCommandIndex(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "puzzles"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "puzzles", "square.otd"), []byte("1-2-3-4-1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	index := filepath.Join(dir, "puzzles.json")
+	if err := os.WriteFile(index, []byte(`[{"Name": "Square", "JsonFile": "square.otd"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	results := filepath.Join(dir, "results.json")
+
+	var out bytes.Buffer
+	if err := batchCommand([]string{"-count_only", "-json", results, index}, nil, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "Square") || !strings.Contains(out.String(), "1 puzzles: 1 ok") {
+		t.Errorf("Unexpected summary:\n%s", out.String())
+	}
+	data, err := os.ReadFile(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"solutions": 8`) {
+		t.Errorf("Expected 8 solutions in the JSON results, got %s", data)
+	}
+}
+
+func TestBatchCommandTimeout(t *testing.T) {
+	dir := t.TempDir()
+	data, err := os.ReadFile("puzzles/level53.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "level53.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err = batchCommand([]string{"-count_only", "-timeout", "1ns", "-json", "-", dir}, nil, &out)
+	if err == nil {
+		t.Fatal("Expected error for a puzzle that timed out")
+	}
+	var results []batchResult
+	if err := json.Unmarshal(out.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Status != batchTimeout {
+		t.Errorf("Expected timeout, got %+v", results)
+	}
+}
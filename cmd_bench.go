@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+// benchCommand implements `bench [flags] puzzle...`, solving every puzzle
+// -runs times and printing the fastest and average time.
+func benchCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("bench")
+	runs := fs.Int("runs", 3, "Number of times each puzzle is solved")
+	countOnly := fs.Bool("count_only", false, "Time counting the solutions instead of collecting them")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usagef("expected at least one puzzle")
+	}
+	if *runs < 1 {
+		return usagef("-runs must be at least 1")
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PUZZLE\tSOLUTIONS\tRUNS\tMIN\tAVG")
+	for _, filename := range fs.Args() {
+		puzzle, err := loadPuzzle(filename, "", stdin)
+		if err != nil {
+			return err
+		}
+		var n int
+		var total, fastest time.Duration
+		for i := 0; i < *runs; i++ {
+			start := time.Now()
+			if *countOnly {
+				n = solver.GetNumberOfSolutions(puzzle)
+			} else {
+				n = len(*solver.Solve(puzzle))
+			}
+			elapsed := time.Since(start)
+			total += elapsed
+			if i == 0 || elapsed < fastest {
+				fastest = elapsed
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%v\t%v\n", filename, n, *runs, fastest.Round(time.Microsecond), (total / time.Duration(*runs)).Round(time.Microsecond))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+// convertCommand implements `convert [flags] input output`, translating a
+// puzzle between the formats known to solver.CodecForFile. Either file may be
+// "-" for stdin or stdout, in which case -from or -to names its format.
+// Flags go before the file names.
+func convertCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("convert")
+	from := fs.String("from", "", "Input format (file extension) when reading stdin, e.g. json")
+	to := fs.String("to", "", "Output format (file extension) when writing stdout, e.g. dot")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return usagef("expected input and output, got %d arguments", fs.NArg())
+	}
+	input, output := fs.Arg(0), fs.Arg(1)
+
+	decoder, err := solver.CodecForFile(formatName(input, *from))
+	if err != nil {
+		return err
+	}
+	encoder, err := solver.CodecForFile(formatName(output, *to))
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if input == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(input)
+	}
+	if err != nil {
+		return err
+	}
+	puzzle, err := decoder.Decode(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", input, err)
+	}
+
+	if output == "-" {
+		return encoder.Encode(stdout, puzzle)
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := encoder.Encode(f, puzzle); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// formatName returns the name whose extension selects the codec: the file
+// itself, or a dummy name built from the explicit format. Stdin and stdout
+// default to JSON.
+func formatName(filename, format string) string {
+	if format == "" && filename == "-" {
+		format = "json"
+	}
+	if format != "" {
+		return "puzzle." + strings.TrimPrefix(format, ".")
+	}
+	return filename
+}
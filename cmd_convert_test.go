@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConvertCommandRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	dot := filepath.Join(dir, "level53.dot")
+	json := filepath.Join(dir, "level53.json")
+
+	if err := convertCommand([]string{"puzzles/level53.json", dot}, nil, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := convertCommand([]string{dot, json}, nil, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	filename := "puzzles/level53.json"
+	original, err := createPuzzleByFilename(&filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	converted, err := createPuzzleByFilename(&json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(converted.Edges) != len(original.Edges) || len(converted.Points) != len(original.Points) {
+		t.Errorf("Expected %d edges and %d points, got %d and %d",
+			len(original.Edges), len(original.Points), len(converted.Edges), len(converted.Points))
+	}
+}
+
+func TestConvertCommandStdio(t *testing.T) {
+	var out bytes.Buffer
+	in := strings.NewReader("graph { 1 -- 2 -- 3 -- 1 }")
+	if err := convertCommand([]string{"-from", "dot", "-", "-"}, in, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), `"Edges"`) {
+		t.Errorf("Expected JSON on stdout, got %s", out.String())
+	}
+}
+
+func TestConvertCommandErrors(t *testing.T) {
+	if err := convertCommand([]string{"puzzles/house.json"}, nil, nil); err == nil {
+		t.Error("Expected usage error")
+	}
+	if err := convertCommand([]string{"puzzles/house.json", filepath.Join(t.TempDir(), "house.txt")}, nil, nil); err == nil {
+		t.Error("Expected error for unknown output format")
+	}
+}
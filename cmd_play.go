@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/wricardo/OneTDraw-Solver/render"
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+const playHelp = "Type a point number to move there, u to undo, h for a hint, r to restart, q to quit."
+
+// playCommand implements `play [flags] puzzle`, a game in the terminal.
+// Moves are typed as lines, so it needs no mouse nor raw terminal mode and
+// works over SSH or with moves piped in.
+func playCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("play")
+	columns := fs.Int("width", 60, "Width of the drawing in characters")
+	rows := fs.Int("height", 20, "Height of the drawing in characters")
+	ascii := fs.Bool("ascii", false, "Draw with ASCII characters only")
+	plain := fs.Bool("plain", !isTerminal(stdout), "No colours and no screen clearing, the default when not on a terminal")
+	from := fs.String("from", "", "Puzzle format (file extension), needed for CSV on stdin")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("expected one puzzle, got %d", fs.NArg())
+	}
+	if fs.Arg(0) == "-" {
+		return usagef("play reads moves from stdin, the puzzle must be a file")
+	}
+
+	puzzle, err := loadPuzzle(fs.Arg(0), *from, nil)
+	if err != nil {
+		return err
+	}
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+	game := solver.NewGameState(puzzle)
+	opts := render.TextOptions{Columns: *columns, Rows: *rows, ASCII: *ascii, Color: !*plain}
+	message := playHelp
+	input := bufio.NewScanner(stdin)
+	for {
+		if err := drawGame(stdout, puzzle, game, opts, message, !*plain); err != nil {
+			return err
+		}
+		if !input.Scan() {
+			fmt.Fprintln(stdout)
+			return input.Err()
+		}
+		command := strings.ToLower(strings.TrimSpace(input.Text()))
+		switch command {
+		case "":
+			message = ""
+		case "q", "quit", "exit":
+			return nil
+		case "?", "help":
+			message = playHelp
+		case "u", "undo":
+			message = ""
+			if !game.Undo() {
+				message = "Nothing to undo."
+			}
+		case "r", "restart":
+			game = solver.NewGameState(puzzle)
+			message = "Restarted."
+		case "h", "hint":
+			if next, ok := game.Hint(); ok {
+				message = fmt.Sprintf("Hint: go to %d.", next)
+			} else {
+				message = "No solution from here, undo some moves."
+			}
+		default:
+			point, err := strconv.ParseUint(command, 10, 16)
+			if err != nil {
+				message = fmt.Sprintf("Unknown command %q. %s", command, playHelp)
+				continue
+			}
+			message = ""
+			if err := game.Play(uint16(point)); err != nil {
+				message = "Illegal move: " + err.Error() + "."
+			}
+		}
+	}
+}
+
+// drawGame writes the puzzle with the path played so far, the moves left
+// and message.
+func drawGame(w io.Writer, puzzle *solver.Puzzle, game *solver.GameState, opts render.TextOptions, message string, clear bool) error {
+	var b strings.Builder
+	if clear {
+		b.WriteString("\x1b[H\x1b[2J")
+	}
+	opts.Solution = game.Path()
+	if err := render.Text(&b, puzzle, opts); err != nil {
+		return err
+	}
+	fmt.Fprintln(&b)
+
+	path := game.Path()
+	if len(path) == 0 {
+		b.WriteString("Path: (pick a starting point)\n")
+	} else {
+		fmt.Fprintf(&b, "Path: %s\n", formatPoints(path, " - "))
+	}
+	fmt.Fprintf(&b, "Edges left: %d\n", game.Remaining())
+	switch {
+	case game.IsSolved():
+		b.WriteString("Solved! u to undo, r to play again, q to quit.\n")
+	case game.IsStuck():
+		b.WriteString("Stuck: no moves left. u to undo, r to restart.\n")
+	default:
+		fmt.Fprintf(&b, "Moves: %s\n", formatPoints(game.Moves(), ", "))
+	}
+	if message != "" {
+		b.WriteString(message + "\n")
+	}
+	b.WriteString("> ")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatPoints(points []uint16, sep string) string {
+	s := make([]string, len(points))
+	for k, p := range points {
+		s[k] = strconv.Itoa(int(p))
+	}
+	return strings.Join(s, sep)
+}
+
+// isTerminal reports whether v, a reader or writer, is a terminal.
+func isTerminal(v interface{}) bool {
+	f, ok := v.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
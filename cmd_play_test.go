@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPlayCommand(t *testing.T) {
+	var out bytes.Buffer
+	moves := "4\n2\n9\n1\nu\nh\nq\n"
+	if err := playCommand([]string{"-plain", "puzzles/house.json"}, strings.NewReader(moves), &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, expected := range []string{
+		"Path: 4 - 2 - 1\n",
+		"Illegal move: no edge left from 2 to 9.",
+		"Hint: go to ",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in output", expected)
+		}
+	}
+	if strings.Contains(out.String(), "\x1b") {
+		t.Error("Expected no escape sequences with -plain")
+	}
+}
+
+func TestPlayCommandSolved(t *testing.T) {
+	var out bytes.Buffer
+	moves := "1\n2\n3\n1\n"
+	if err := playCommand([]string{"-plain", "puzzles/regular_triangle.json"}, strings.NewReader(moves), &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "Solved!") {
+		t.Errorf("Expected the puzzle solved, got\n%s", out.String())
+	}
+}
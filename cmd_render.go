@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/wricardo/OneTDraw-Solver/render"
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+// renderCommand implements `render [flags] puzzle`, writing the puzzle and
+// optionally one of its solutions as SVG, or a solution being drawn as an
+// animated GIF.
+func renderCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("render")
+	solutionIndex := fs.Int("solution", -1, "Index of the solution to draw, -1 for none (GIFs default to 0)")
+	width := fs.Float64("width", 500, "Width of the image")
+	height := fs.Float64("height", 500, "Height of the image")
+	themeName := fs.String("theme", "light", "Colour scheme. [light,dark]")
+	out := fs.String("o", "", "Output file, stdout when empty")
+	format := fs.String("format", "", "Image format. [svg,gif] Defaults to the -o extension, else svg")
+	delay := fs.Duration("delay", 700*time.Millisecond, "Time each step is shown in a GIF")
+	hold := fs.Duration("hold", 2*time.Second, "Time the finished GIF is shown before it loops")
+	from := fs.String("from", "", "Puzzle format (file extension), needed for CSV on stdin")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("expected one puzzle, got %d", fs.NArg())
+	}
+	if !(*width > 0 && *width <= maxRenderSize && *height > 0 && *height <= maxRenderSize) {
+		return usagef("the image must be at most %gx%g, got %gx%g", maxRenderSize, maxRenderSize, *width, *height)
+	}
+
+	puzzle, err := loadPuzzle(fs.Arg(0), *from, stdin)
+	if err != nil {
+		return err
+	}
+	theme, err := render.GetTheme(*themeName)
+	if err != nil {
+		return err
+	}
+	if *format == "" {
+		*format = "svg"
+		if strings.EqualFold(filepath.Ext(*out), ".gif") {
+			*format = "gif"
+		}
+	}
+	if *format != "svg" && *format != "gif" {
+		return usagef("unknown format %q", *format)
+	}
+	if *format == "gif" && *solutionIndex < 0 {
+		*solutionIndex = 0
+	}
+
+	opts := render.Options{Width: *width, Height: *height, Theme: theme}
+	if *solutionIndex >= 0 {
+		opts.Solution, err = solutionByIndex(puzzle, *solutionIndex)
+		if err != nil {
+			return err
+		}
+	}
+
+	w := stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if *format == "gif" {
+		return render.GIF(w, puzzle, render.GIFOptions{Options: opts, Delay: *delay, Hold: *hold})
+	}
+	return render.SVG(w, puzzle, opts)
+}
+
+// solutionByIndex solves the puzzle and returns the solution at index, in
+// the order Solve lists them.
+func solutionByIndex(puzzle *solver.Puzzle, index int) (solver.Solution, error) {
+	return pickSolution(*solver.Solve(puzzle), index)
+}
+
+func pickSolution(solutions solver.Solutions, index int) (solver.Solution, error) {
+	if index < 0 || index >= len(solutions) {
+		return nil, fmt.Errorf("solution %d out of range, puzzle has %d solutions", index, len(solutions))
+	}
+	return solutions[index], nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderCommand(t *testing.T) {
+	var out bytes.Buffer
+	err := renderCommand([]string{"-solution", "0", "puzzles/regular_triangle.json"}, nil, &out)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "<svg ") {
+		t.Errorf("Expected SVG output, got %s", out.String())
+	}
+	if !strings.Contains(out.String(), `class="solution"`) {
+		t.Error("Expected the solution overlay")
+	}
+}
+
+func TestRenderCommandErrors(t *testing.T) {
+	var out bytes.Buffer
+	if err := renderCommand([]string{}, nil, &out); err == nil {
+		t.Error("Expected usage error without a puzzle")
+	}
+	if err := renderCommand([]string{"-solution", "99", "puzzles/regular_triangle.json"}, nil, &out); err == nil {
+		t.Error("Expected error for out of range solution")
+	}
+	if err := renderCommand([]string{"-theme", "neon", "puzzles/regular_triangle.json"}, nil, &out); err == nil {
+		t.Error("Expected error for unknown theme")
+	}
+	for _, width := range []string{"100000", "NaN", "Inf", "-Inf"} {
+		args := []string{"-width", width, "-solution", "0", "-o", filepath.Join(t.TempDir(), "x.gif"), "puzzles/regular_triangle.json"}
+		if err := renderCommand(args, nil, &out); err == nil {
+			t.Errorf("Expected usage error for a width of %s", width)
+		}
+	}
+}
+
+func TestRenderCommandGIF(t *testing.T) {
+	var out bytes.Buffer
+	err := renderCommand([]string{"-format", "gif", "-width", "100", "-height", "100", "puzzles/regular_triangle.json"}, nil, &out)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "GIF89a") {
+		t.Errorf("Expected GIF output, got %q", out.String()[:6])
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/wricardo/OneTDraw-Solver/render"
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+const replHelp = `commands:
+  show            draw the puzzle and the path so far
+  moves           list the points the next move can go to
+  go N [N...]     move to the points in turn
+  back [N]        take back the last N moves, 1 by default
+  reset           start over
+  count           number of solutions from here
+  hint            a move that leads to a solution
+  degrees         edge ends left at every point
+  save FILE       write the edges left as a puzzle starting here
+  help            this list
+  quit            leave
+`
+
+// replCommand implements `repl [flags] puzzle`: commands that step through
+// a puzzle with solver.GameState. With -script, commands are read from a
+// file and echoed, and the first failing one stops the session, so sessions
+// can be replayed.
+func replCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("repl")
+	script := fs.String("script", "", "Read commands from this file instead of stdin")
+	width := fs.Int("width", 60, "Columns of the drawing")
+	height := fs.Int("height", 20, "Rows of the drawing")
+	ascii := fs.Bool("ascii", false, "Draw with ASCII characters only")
+	from := fs.String("from", "", "Puzzle format (file extension), needed for CSV on stdin")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("expected one puzzle, got %d", fs.NArg())
+	}
+
+	input := stdin
+	if *script != "" {
+		f, err := os.Open(*script)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		input = f
+	} else if fs.Arg(0) == "-" {
+		return usagef("the repl reads commands from stdin, use -script or a puzzle file")
+	}
+	if input == nil {
+		input = os.Stdin
+	}
+	puzzle, err := loadPuzzle(fs.Arg(0), *from, stdin)
+	if err != nil {
+		return err
+	}
+
+	r := &repl{
+		puzzle: puzzle,
+		game:   solver.NewGameState(puzzle),
+		opts:   render.TextOptions{Columns: *width, Rows: *height, ASCII: *ascii},
+		out:    stdout,
+	}
+	prompt := *script == "" && isTerminal(input)
+	scanner := bufio.NewScanner(input)
+	for line := 1; ; line++ {
+		if prompt {
+			fmt.Fprint(stdout, "otd> ")
+		}
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if *script != "" {
+			fmt.Fprintf(stdout, "> %s\n", text)
+		}
+		quit, err := r.execute(strings.Fields(text))
+		if quit {
+			return nil
+		}
+		if err != nil {
+			if *script != "" {
+				return fmt.Errorf("%s:%d: %w", *script, line, err)
+			}
+			fmt.Fprintf(stdout, "error: %v\n", err)
+		}
+	}
+}
+
+type repl struct {
+	puzzle *solver.Puzzle
+	game   *solver.GameState
+	opts   render.TextOptions
+	out    io.Writer
+}
+
+// execute runs one command, returning true when it asks to quit.
+func (this *repl) execute(fields []string) (bool, error) {
+	command, args := fields[0], fields[1:]
+	switch command {
+	case "quit", "exit", "q":
+		return true, nil
+	case "help", "?":
+		fmt.Fprint(this.out, replHelp)
+	case "show":
+		opts := this.opts
+		opts.Solution = this.game.Path()
+		if err := render.Text(this.out, this.puzzle, opts); err != nil {
+			return false, err
+		}
+		this.status()
+	case "moves":
+		if moves := this.game.Moves(); len(moves) > 0 {
+			fmt.Fprintln(this.out, formatPoints(moves, ", "))
+		} else {
+			fmt.Fprintln(this.out, "none")
+		}
+	case "go":
+		if len(args) == 0 {
+			return false, fmt.Errorf("usage: go N [N...]")
+		}
+		for _, arg := range args {
+			point, err := strconv.ParseUint(arg, 10, 16)
+			if err != nil {
+				return false, fmt.Errorf("invalid point %q", arg)
+			}
+			if err := this.game.Play(uint16(point)); err != nil {
+				return false, err
+			}
+		}
+		this.status()
+	case "back":
+		n := 1
+		if len(args) > 0 {
+			var err error
+			if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+				return false, fmt.Errorf("invalid number of moves %q", args[0])
+			}
+		}
+		for i := 0; i < n; i++ {
+			if !this.game.Undo() {
+				return false, fmt.Errorf("nothing to take back")
+			}
+		}
+		this.status()
+	case "reset":
+		this.game = solver.NewGameState(this.puzzle)
+		this.status()
+	case "count":
+		fmt.Fprintf(this.out, "%d solutions\n", this.game.CountSolutions())
+	case "hint":
+		if next, ok := this.game.Hint(); ok {
+			fmt.Fprintf(this.out, "go %d\n", next)
+		} else {
+			fmt.Fprintln(this.out, "no solution from here")
+		}
+	case "degrees":
+		tw := tabwriter.NewWriter(this.out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "POINT\tUNDIRECTED\tIN\tOUT")
+		for _, d := range this.game.Degrees() {
+			fmt.Fprintf(tw, "%d\t%d\t%d\t%d\n", d.Point, d.Undirected, d.In, d.Out)
+		}
+		tw.Flush()
+	case "save":
+		if len(args) != 1 {
+			return false, fmt.Errorf("usage: save FILE")
+		}
+		return false, this.save(args[0])
+	default:
+		return false, fmt.Errorf("unknown command %q, try help", command)
+	}
+	return false, nil
+}
+
+// status prints where the game stands after a move.
+func (this *repl) status() {
+	path := this.game.Path()
+	switch {
+	case len(path) == 0:
+		fmt.Fprintln(this.out, "no moves yet")
+	case this.game.IsSolved():
+		fmt.Fprintf(this.out, "solved: %s\n", formatPoints(path, " - "))
+	case this.game.IsStuck():
+		fmt.Fprintf(this.out, "stuck at %d after %s, %d edges left\n", path[len(path)-1], formatPoints(path, " - "), this.game.Remaining())
+	default:
+		fmt.Fprintf(this.out, "at %d after %s, %d edges left\n", path[len(path)-1], formatPoints(path, " - "), this.game.Remaining())
+	}
+}
+
+func (this *repl) save(filename string) error {
+	codec, err := solver.CodecForFile(filename)
+	if err != nil {
+		return err
+	}
+	position := this.game.Position()
+	if len(position.Edges) == 0 {
+		return fmt.Errorf("nothing left to draw")
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := codec.Encode(f, position); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(this.out, "saved %s\n", filename)
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+func TestReplScript(t *testing.T) {
+	dir := t.TempDir()
+	saved := filepath.Join(dir, "position.json")
+	script := filepath.Join(dir, "session.txt")
+	session := "# replayed session\nmoves\ngo 4 2\ncount\ndegrees\nback\nhint\ngo 2\nsave " + saved + "\nquit\ngo 9\n"
+	if err := os.WriteFile(script, []byte(session), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := replCommand([]string{"-script", script, "puzzles/house.json"}, nil, &out); err != nil {
+		t.Fatalf("Unexpected error: %v\n%s", err, out.String())
+	}
+	for _, expected := range []string{
+		"> moves\n1, 2, 3, 4, 5\n",
+		"at 2 after 4 - 2, 7 edges left\n",
+		"16 solutions\n",
+		"POINT  UNDIRECTED  IN  OUT\n",
+		"at 4 after 4, 8 edges left\n",
+		"saved " + saved,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in output\n%s", expected, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "go 9") {
+		t.Error("Expected the session to stop at quit")
+	}
+
+	puzzle, err := loadPuzzle(saved, "", nil)
+	if err != nil {
+		t.Fatalf("Could not load the saved position: %v", err)
+	}
+	if n := solver.GetNumberOfSolutions(puzzle); n != 16 {
+		t.Errorf("Expected the saved position to have 16 solutions, got %d", n)
+	}
+}
+
+func TestReplScriptStopsOnError(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "session.txt")
+	if err := os.WriteFile(script, []byte("go 4\ngo 9\nshow\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	err := replCommand([]string{"-script", script, "puzzles/house.json"}, nil, &out)
+	if err == nil || !strings.Contains(err.Error(), "session.txt:2: no edge left from 4 to 9") {
+		t.Errorf("Expected an error on line 2, got %v", err)
+	}
+}
+
+func TestReplInteractive(t *testing.T) {
+	var out bytes.Buffer
+	if err := replCommand([]string{"puzzles/regular_triangle.json"}, strings.NewReader("go 1 2 3 1\nbogus\n"), &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "solved: 1 - 2 - 3 - 1\n") {
+		t.Errorf("Expected the puzzle solved, got\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), `error: unknown command "bogus"`) {
+		t.Errorf("Expected the unknown command reported, got\n%s", out.String())
+	}
+}
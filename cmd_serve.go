@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAddress = ":8090"
+	// envPrefix starts the environment variables naming serve flags, as in
+	// ONETDRAW_ADDR for -addr.
+	envPrefix = "ONETDRAW_"
+)
+
+// serveCommand implements `serve [flags]`, starting the web interface.
+// Every flag can also be set in the JSON config file given with -config,
+// or as an environment variable; flags take precedence over the
+// environment, which takes precedence over the file.
+func serveCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	ws, err := parseServeConfig(args, os.LookupEnv)
+	if err != nil {
+		return err
+	}
+	return setupWebServer(ws)
+}
+
+// parseServeConfig builds the configuration of serve from its flags, the
+// environment as seen through lookupEnv and the config file.
+func parseServeConfig(args []string, lookupEnv func(string) (string, bool)) (*Webserver, error) {
+	ws := defaultWebserver()
+	fs := newFlagSet("serve")
+	config := fs.String("config", "", "JSON file with settings, keyed by flag name (env "+envPrefix+"CONFIG)")
+	fs.StringVar(&ws.Address, "addr", ws.Address, "Address to listen on, host:port")
+	fs.StringVar(&ws.PuzzleDir, "puzzles", ws.PuzzleDir, "Directory of the puzzle files")
+	fs.StringVar(&ws.Index, "index", ws.Index, "Index of the puzzles listed in the web interface")
+	fs.StringVar(&ws.UserPuzzleDir, "user_puzzles", ws.UserPuzzleDir, "Directory of the puzzles added through the API, with their index")
+	fs.StringVar(&ws.Options.PuzzleToken, "puzzle_token", "", "Bearer token allowing to add, update and delete puzzles through the API, none when empty")
+	fs.StringVar(&ws.StaticDir, "static", ws.StaticDir, "Directory of the web interface files")
+	fs.DurationVar(&ws.ReadTimeout, "read_timeout", ws.ReadTimeout, "Time allowed to read a request")
+	fs.DurationVar(&ws.WriteTimeout, "write_timeout", ws.WriteTimeout, "Time allowed to write a response, streams aside")
+	fs.DurationVar(&ws.IdleTimeout, "idle_timeout", ws.IdleTimeout, "How long idle keep-alive connections are kept")
+	fs.DurationVar(&ws.ShutdownTimeout, "shutdown_timeout", ws.ShutdownTimeout, "How long jobs and requests in flight are waited for on shutdown")
+	fs.StringVar(&ws.TLSCert, "tls_cert", "", "Certificate file, to serve HTTPS with -tls_key")
+	fs.StringVar(&ws.TLSKey, "tls_key", "", "Private key file of -tls_cert")
+	fs.IntVar(&ws.Options.Workers, "workers", ws.Options.Workers, "Number of solve jobs run at once")
+	fs.IntVar(&ws.Options.QueueSize, "queue", ws.Options.QueueSize, "Number of solve jobs that can wait for a worker")
+	fs.IntVar(&ws.Options.CacheEntries, "cache_entries", ws.Options.CacheEntries, "Number of puzzles whose results are cached in memory")
+	fs.IntVar(&ws.Options.CacheSolutions, "cache_solutions", ws.Options.CacheSolutions, "Number of solutions cached in memory")
+	fs.StringVar(&ws.Options.CacheDir, "cache_dir", "", "Directory to also cache results in, across restarts")
+	fs.StringVar(&ws.Options.SessionDir, "sessions", ws.Options.SessionDir, "Directory the games played in the browser are saved to")
+	fs.DurationVar(&ws.Options.SessionIdle, "session_idle", ws.Options.SessionIdle, "How long a game no one plays is kept in memory rather than only on disk")
+	fs.DurationVar(&ws.Options.SessionExpiry, "session_expiry", ws.Options.SessionExpiry, "How long the file of a game no one plays is kept")
+	fs.DurationVar(&ws.Options.JobRetention, "retention", ws.Options.JobRetention, "How long the results of finished jobs are kept")
+	fs.IntVar(&ws.Options.MaxSolves, "max_solves", ws.Options.MaxSolves, "Number of searches run at once by requests and jobs")
+	fs.Int64Var(&ws.Options.MaxNodes, "max_nodes", ws.Options.MaxNodes, "Nodes a search run for a request can explore, 0 for no limit")
+	fs.Int64Var(&ws.Options.MaxSolutions, "max_solutions", ws.Options.MaxSolutions, "Solutions a request can return, 0 for no limit")
+	fs.Int64Var(&ws.Options.MaxJobNodes, "max_job_nodes", ws.Options.MaxJobNodes, "Nodes a search run by a job can explore, 0 for no limit")
+	fs.Int64Var(&ws.Options.MaxJobSolutions, "max_job_solutions", ws.Options.MaxJobSolutions, "Solutions a job can find, 0 for no limit")
+	fs.Float64Var(&ws.Options.RateLimit, "rate", ws.Options.RateLimit, "Solve requests per second allowed to each client, 0 for no limit")
+	fs.IntVar(&ws.Options.RateBurst, "burst", ws.Options.RateBurst, "Solve requests a client can send at once before -rate applies")
+	if err := parseFlags(fs, args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != 0 {
+		return nil, usagef("unexpected argument %q", fs.Arg(0))
+	}
+
+	// The flags given are set again once the file and the environment are
+	// read, to win over them.
+	given := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = f.Value.String()
+	})
+	if path, ok := lookupEnv(envPrefix + "CONFIG"); ok && *config == "" {
+		*config = path
+	}
+	if *config != "" {
+		if err := readServeConfig(fs, *config); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		name := envPrefix + strings.ToUpper(f.Name)
+		if value, ok := lookupEnv(name); ok && err == nil && f.Name != "config" {
+			if e := fs.Set(f.Name, value); e != nil {
+				err = fmt.Errorf("%s: %v", name, e)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range given {
+		fs.Set(name, value)
+	}
+	return ws, nil
+}
+
+// readServeConfig sets the flags of fs from a JSON object keyed by flag
+// name, like {"addr": ":80", "workers": 4, "read_timeout": "30s"}.
+func readServeConfig(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var settings map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&settings); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	for name, value := range settings {
+		if name == "config" || fs.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown setting %q", path, name)
+		}
+		switch value.(type) {
+		case string, json.Number, bool:
+		default:
+			return fmt.Errorf("%s: setting %q must be a string, a number or a boolean", path, name)
+		}
+		if err := fs.Set(name, fmt.Sprint(value)); err != nil {
+			return fmt.Errorf("%s: %s: %v", path, name, err)
+		}
+	}
+	return nil
+}
+
+// defaultWebserver is the configuration of serve without flags, the web
+// interface on :8090 over the files of the repository.
+func defaultWebserver() *Webserver {
+	return &Webserver{
+		Address:         defaultAddress,
+		PuzzleDir:       "puzzles",
+		Index:           "puzzles.json",
+		UserPuzzleDir:   defaultUserPuzzleDir,
+		StaticDir:       "static",
+		ReadTimeout:     10 * time.Second,
+		WriteTimeout:    10 * time.Second,
+		IdleTimeout:     2 * time.Minute,
+		ShutdownTimeout: 30 * time.Second,
+		Options: ServerOptions{
+			Workers:         runtime.NumCPU(),
+			QueueSize:       defaultJobQueue,
+			JobRetention:    defaultJobRetention,
+			CacheEntries:    defaultCacheEntries,
+			CacheSolutions:  defaultCacheSolutions,
+			SessionDir:      defaultSessionDir,
+			SessionIdle:     defaultSessionIdle,
+			SessionExpiry:   defaultSessionExpiry,
+			MaxSolves:       runtime.NumCPU(),
+			MaxNodes:        defaultMaxNodes,
+			MaxSolutions:    defaultMaxSolutions,
+			MaxJobNodes:     defaultMaxJobNodes,
+			MaxJobSolutions: defaultMaxJobSolutions,
+			RateLimit:       defaultRateLimit,
+			RateBurst:       defaultRateBurst,
+		},
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeConfig(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "serve.json")
+	os.WriteFile(config, []byte(`{"addr": ":1", "workers": 3, "read_timeout": "1m", "tls_cert": "cert.pem", "puzzles": "file"}`), 0644)
+	env := map[string]string{
+		"ONETDRAW_CONFIG":  config,
+		"ONETDRAW_ADDR":    ":2",
+		"ONETDRAW_PUZZLES": "env",
+	}
+	lookupEnv := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	ws, err := parseServeConfig([]string{"-addr", ":3"}, lookupEnv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws.Address != ":3" || ws.PuzzleDir != "env" || ws.Options.Workers != 3 || ws.ReadTimeout != time.Minute || ws.TLSCert != "cert.pem" {
+		t.Errorf("Expected flags over env over file, got %+v", ws)
+	}
+	if ws.Index != "puzzles.json" || ws.ShutdownTimeout != 30*time.Second {
+		t.Errorf("Expected the defaults for the rest, got %+v", ws)
+	}
+
+	env["ONETDRAW_WORKERS"] = "many"
+	if _, err := parseServeConfig(nil, lookupEnv); err == nil || !strings.Contains(err.Error(), "ONETDRAW_WORKERS") {
+		t.Errorf("Expected an invalid variable error, got %v", err)
+	}
+	delete(env, "ONETDRAW_WORKERS")
+
+	os.WriteFile(config, []byte(`{"port": 80}`), 0644)
+	if _, err := parseServeConfig(nil, lookupEnv); err == nil || !strings.Contains(err.Error(), `unknown setting "port"`) {
+		t.Errorf("Expected an unknown setting error, got %v", err)
+	}
+}
+
+func TestWebserverShutdown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := defaultWebserver()
+	ws.Options.SessionDir = t.TempDir()
+	ws.ShutdownTimeout = 5 * time.Second
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- ws.Serve(ctx, listener)
+	}()
+
+	url := "http://" + listener.Addr().String()
+	res, err := http.Get(url + "/readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected ready, got %d", res.StatusCode)
+	}
+
+	cancel()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Server did not stop")
+	}
+	if _, err := http.Get(url + "/healthz"); err == nil {
+		t.Error("Expected the server to be gone")
+	}
+}
+
+func TestWebserverSolveTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := defaultWebserver()
+	ws.Options.SessionDir = t.TempDir()
+	ws.WriteTimeout = 2 * time.Second
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ws.Serve(ctx, listener)
+
+	// level54 takes minutes to solve, the search must give up in time for
+	// the 503 to be written.
+	res, err := http.Get("http://" + listener.Addr().String() + "/puzzle/solve/level54")
+	if err != nil {
+		t.Fatalf("Expected an answer within the write timeout, got %v", err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != 503 || !strings.Contains(string(body), "search stopped after 1.6s") {
+		t.Errorf("Expected a 503 after 1.6s, got %d %s", res.StatusCode, body)
+	}
+}
+
+func TestWebserverTLSConfig(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := defaultWebserver()
+	ws.TLSCert = "cert.pem"
+	if err := ws.Serve(context.Background(), listener); err == nil || !strings.Contains(err.Error(), "key") {
+		t.Errorf("Expected a missing key error, got %v", err)
+	}
+}
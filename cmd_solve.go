@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+// solveCommand implements `solve [flags] puzzle`, printing every solution
+// in the -output format.
+func solveCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("solve")
+	outputName := fs.String("output", "clean", "Format of the output. ["+strings.Join(solver.PrinterNames(), ",")+"]")
+	from := fs.String("from", "", "Puzzle format (file extension), needed for CSV on stdin")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("expected one puzzle, got %d", fs.NArg())
+	}
+
+	puzzle, err := loadPuzzle(fs.Arg(0), *from, stdin)
+	if err != nil {
+		return err
+	}
+	printer, err := solver.NewPrinter(*outputName, puzzle)
+	if err != nil {
+		return usageError{err: err}
+	}
+	solutions := solver.Solve(puzzle)
+	if err := printer.Print(stdout, solutions); err != nil {
+		return err
+	}
+	if len(*solutions) == 0 {
+		return errNoSolution
+	}
+	return nil
+}
+
+// countCommand implements `count [flags] puzzle`.
+func countCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("count")
+	from := fs.String("from", "", "Puzzle format (file extension), needed for CSV on stdin")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("expected one puzzle, got %d", fs.NArg())
+	}
+
+	puzzle, err := loadPuzzle(fs.Arg(0), *from, stdin)
+	if err != nil {
+		return err
+	}
+	n := solver.GetNumberOfSolutions(puzzle)
+	fmt.Fprintln(stdout, n)
+	if n == 0 {
+		return errNoSolution
+	}
+	return nil
+}
+
+// validateCommand implements `validate [flags] puzzle...`, reporting every
+// puzzle and failing when any of them is invalid.
+func validateCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("validate")
+	from := fs.String("from", "", "Puzzle format (file extension), needed for CSV on stdin")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usagef("expected at least one puzzle")
+	}
+
+	invalid := 0
+	for _, filename := range fs.Args() {
+		puzzle, err := loadPuzzle(filename, *from, stdin)
+		if err != nil {
+			invalid++
+			fmt.Fprintf(stdout, "FAIL %v\n", err)
+			continue
+		}
+		fmt.Fprintf(stdout, "ok   %s (%d edges, %d points)\n", filename, len(puzzle.Edges), len(puzzle.Points))
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d puzzles invalid", invalid, fs.NArg())
+	}
+	return nil
+}
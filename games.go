@@ -0,0 +1,348 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+const (
+	defaultSessionDir    = "sessions"
+	defaultSessionIdle   = 30 * time.Minute
+	defaultSessionExpiry = 30 * 24 * time.Hour
+)
+
+var gameIDRe = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+var errGameNotFound = errors.New("game not found")
+
+// gameSession is a game played through the API. Every move is saved to
+// disk so that the game can be resumed after a reload or a restart.
+type gameSession struct {
+	mu     sync.Mutex
+	ID     string            `json:"id"`
+	Puzzle string            `json:"puzzle"`
+	Game   *solver.GameState `json:"game"`
+	// used is when the session was last asked for, under the store lock.
+	used time.Time
+	// deleted is set under mu once the game is deleted, so that a request
+	// still holding it does not save it again.
+	deleted bool
+}
+
+// gameStore keeps the sessions of a directory, one JSON file each, along
+// with the ones in use in memory. Those left idle for longer than idle are
+// dropped from memory, to be loaded again when asked for, and their files
+// are deleted once not played for expiry.
+type gameStore struct {
+	dir    string
+	idle   time.Duration
+	expiry time.Duration
+	now    func() time.Time
+
+	mu         sync.Mutex
+	sessions   map[string]*gameSession
+	lastExpire time.Time
+}
+
+func newGameStore(dir string, idle, expiry time.Duration) *gameStore {
+	return &gameStore{dir: dir, idle: idle, expiry: expiry, now: time.Now, sessions: map[string]*gameSession{}}
+}
+
+func (this *gameStore) file(id string) string {
+	return filepath.Join(this.dir, id+".json")
+}
+
+// create starts a game and saves it.
+func (this *gameStore) create(name string, puzzle *solver.Puzzle) (*gameSession, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	s := &gameSession{ID: id, Puzzle: name, Game: solver.NewGameState(puzzle)}
+	if err := this.save(s); err != nil {
+		return nil, err
+	}
+	this.mu.Lock()
+	this.prune()
+	this.expire()
+	s.used = this.now()
+	this.sessions[id] = s
+	this.mu.Unlock()
+	return s, nil
+}
+
+// get returns a session, loading it from disk when it is not in memory.
+func (this *gameStore) get(id string) (*gameSession, error) {
+	if !gameIDRe.MatchString(id) {
+		return nil, errGameNotFound
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.prune()
+	if s, ok := this.sessions[id]; ok {
+		s.used = this.now()
+		return s, nil
+	}
+	data, err := os.ReadFile(this.file(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errGameNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &gameSession{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("could not read game %s: %v", id, err)
+	}
+	s.used = this.now()
+	this.sessions[id] = s
+	return s, nil
+}
+
+// prune drops the sessions idle for too long from memory, their moves being
+// on disk already. A session still locked is in use by a request, which
+// would otherwise play on a copy no longer in the store. The caller holds
+// this.mu.
+func (this *gameStore) prune() {
+	now := this.now()
+	for id, s := range this.sessions {
+		if now.Sub(s.used) <= this.idle || !s.mu.TryLock() {
+			continue
+		}
+		delete(this.sessions, id)
+		s.mu.Unlock()
+	}
+}
+
+// expire deletes, once an hour, the files of the games not played for
+// longer than the expiry. The caller holds this.mu.
+func (this *gameStore) expire() {
+	now := this.now()
+	if now.Sub(this.lastExpire) < time.Hour {
+		return
+	}
+	this.lastExpire = now
+	entries, err := os.ReadDir(this.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if _, inUse := this.sessions[id]; !ok || inUse || !gameIDRe.MatchString(id) {
+			continue
+		}
+		if info, err := entry.Info(); err == nil && now.Sub(info.ModTime()) > this.expiry {
+			os.Remove(this.file(id))
+		}
+	}
+}
+
+// save writes the session to disk. The caller holds s.mu, or is the only
+// one to know s. A deleted session is not written again.
+func (this *gameStore) save(s *gameSession) error {
+	if s.deleted {
+		return errGameNotFound
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(this.dir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(this.file(s.ID), data)
+}
+
+func (this *gameStore) remove(id string) error {
+	if !gameIDRe.MatchString(id) {
+		return errGameNotFound
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	// The requests holding the session are waited for, and those to come
+	// find it deleted.
+	if s, ok := this.sessions[id]; ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.deleted = true
+		delete(this.sessions, id)
+	}
+	err := os.Remove(this.file(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return errGameNotFound
+	}
+	return err
+}
+
+// gameRequest is the body of POST /games, Puzzle naming the puzzle to
+// play as in /puzzle/solve/{filename}.
+type gameRequest struct {
+	Puzzle string `json:"puzzle"`
+}
+
+// moveRequest is the body of POST /games/{id}/moves.
+type moveRequest struct {
+	Point uint16 `json:"point"`
+}
+
+// hintResponse answers GET /games/{id}/hint, Point being null when no
+// solution starts with the moves played.
+type hintResponse struct {
+	Point *uint16 `json:"point"`
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return httpErrorf(http.StatusBadRequest, "invalid request: %v", err)
+	}
+	return nil
+}
+
+func (this *Server) createGame(w http.ResponseWriter, r *http.Request) error {
+	var req gameRequest
+	if err := decodeBody(w, r, &req); err != nil {
+		return err
+	}
+	req.Puzzle = puzzleName(req.Puzzle)
+	puzzle, err := this.loadPuzzle(req.Puzzle)
+	if err != nil {
+		return err
+	}
+	s, err := this.games.create(req.Puzzle, puzzle)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Location", "/games/"+s.ID)
+	return writeJson(w, http.StatusCreated, s)
+}
+
+// session returns the session of the id path parameter.
+func (this *Server) session(r *http.Request) (*gameSession, error) {
+	s, err := this.games.get(r.PathValue("id"))
+	if err == errGameNotFound {
+		return nil, httpErrorf(http.StatusNotFound, "game %s not found", r.PathValue("id"))
+	}
+	return s, err
+}
+
+// withGame runs f on the session of the id path parameter, holding its
+// lock, and answers with the session. f returns whether it changed the
+// game, which is then saved.
+func (this *Server) withGame(w http.ResponseWriter, r *http.Request, f func(s *gameSession) (bool, error)) error {
+	s, err := this.session(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.deleted {
+		return httpErrorf(http.StatusNotFound, "game %s not found", s.ID)
+	}
+	changed, err := f(s)
+	if err != nil {
+		return err
+	}
+	if changed {
+		if err := this.games.save(s); err == errGameNotFound {
+			return httpErrorf(http.StatusNotFound, "game %s not found", s.ID)
+		} else if err != nil {
+			return err
+		}
+	}
+	return writeJson(w, http.StatusOK, s)
+}
+
+func (this *Server) getGame(w http.ResponseWriter, r *http.Request) error {
+	return this.withGame(w, r, func(s *gameSession) (bool, error) {
+		return false, nil
+	})
+}
+
+// playMove checks a move against the edges left and plays it, answering
+// 409 for an illegal move.
+func (this *Server) playMove(w http.ResponseWriter, r *http.Request) error {
+	var req moveRequest
+	if err := decodeBody(w, r, &req); err != nil {
+		return err
+	}
+	return this.withGame(w, r, func(s *gameSession) (bool, error) {
+		if err := s.Game.Play(req.Point); err != nil {
+			return false, &httpError{Status: http.StatusConflict, Err: err}
+		}
+		return true, nil
+	})
+}
+
+func (this *Server) undoMove(w http.ResponseWriter, r *http.Request) error {
+	return this.withGame(w, r, func(s *gameSession) (bool, error) {
+		if !s.Game.Undo() {
+			return false, httpErrorf(http.StatusConflict, "nothing to undo")
+		}
+		return true, nil
+	})
+}
+
+func (this *Server) restartGame(w http.ResponseWriter, r *http.Request) error {
+	return this.withGame(w, r, func(s *gameSession) (bool, error) {
+		for s.Game.Undo() {
+		}
+		return true, nil
+	})
+}
+
+func (this *Server) gameHint(w http.ResponseWriter, r *http.Request) error {
+	s, err := this.session(r)
+	if err != nil {
+		return err
+	}
+	limits, err := this.limits(r)
+	if err != nil {
+		return err
+	}
+	if err := this.acquireSlot(w); err != nil {
+		return err
+	}
+	defer this.slots.release()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.deleted {
+		return httpErrorf(http.StatusNotFound, "game %s not found", s.ID)
+	}
+	progress := limits.apply(&solver.Progress{})
+	ctx, cancel := limits.context(r.Context())
+	defer cancel()
+	done := this.metrics.search(progress)
+	next, ok, err := s.Game.HintContext(ctx, progress)
+	done()
+	if err != nil {
+		return this.limitError(err, limits)
+	}
+	var hint hintResponse
+	if ok {
+		hint.Point = &next
+	}
+	return writeJson(w, http.StatusOK, hint)
+}
+
+func (this *Server) deleteGame(w http.ResponseWriter, r *http.Request) error {
+	if err := this.games.remove(r.PathValue("id")); err == errGameNotFound {
+		return httpErrorf(http.StatusNotFound, "game %s not found", r.PathValue("id"))
+	} else if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
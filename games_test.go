@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+// gameView is the part of a game response the tests look at.
+type gameView struct {
+	ID     string
+	Puzzle string
+	Game   struct {
+		Path      []uint16
+		Moves     []uint16
+		Remaining int
+		Solved    bool
+		Stuck     bool
+	}
+}
+
+func decodeGame(t *testing.T, s *Server, method, target, body string, status int) gameView {
+	t.Helper()
+	w := request(t, s, method, target, body)
+	if w.Code != status {
+		t.Fatalf("%s %s: expected %d, got %d %s", method, target, status, w.Code, w.Body.String())
+	}
+	var g gameView
+	if err := json.Unmarshal(w.Body.Bytes(), &g); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func move(t *testing.T, s *Server, id string, point uint16) gameView {
+	t.Helper()
+	return decodeGame(t, s, "POST", "/games/"+id+"/moves", fmt.Sprintf(`{"point": %d}`, point), 200)
+}
+
+func TestGamePlay(t *testing.T) {
+	s := newTestServer(t, ServerOptions{Workers: 1})
+	g := decodeGame(t, s, "POST", "/games", `{"puzzle": "regular_triangle"}`, 201)
+	if g.Puzzle != "regular_triangle" || g.Game.Remaining != 3 || len(g.Game.Moves) != 3 {
+		t.Fatalf("Unexpected new game %+v", g)
+	}
+
+	move(t, s, g.ID, 1)
+	move(t, s, g.ID, 2)
+	assertJsonError(t, request(t, s, "POST", "/games/"+g.ID+"/moves", `{"point": 1}`), 409, "no edge left from 2 to 1")
+	g = decodeGame(t, s, "POST", "/games/"+g.ID+"/undo", "", 200)
+	if !reflect.DeepEqual(g.Game.Path, []uint16{1}) {
+		t.Errorf("Expected path [1] after undo, got %v", g.Game.Path)
+	}
+
+	var hint hintResponse
+	json.Unmarshal(request(t, s, "GET", "/games/"+g.ID+"/hint", "").Body.Bytes(), &hint)
+	if hint.Point == nil {
+		t.Fatal("Expected a hint")
+	}
+	move(t, s, g.ID, *hint.Point)
+	for !g.Game.Solved {
+		json.Unmarshal(request(t, s, "GET", "/games/"+g.ID+"/hint", "").Body.Bytes(), &hint)
+		g = move(t, s, g.ID, *hint.Point)
+	}
+	if g.Game.Remaining != 0 || len(g.Game.Path) != 4 {
+		t.Errorf("Unexpected solved game %+v", g)
+	}
+
+	g = decodeGame(t, s, "POST", "/games/"+g.ID+"/restart", "", 200)
+	if len(g.Game.Path) != 0 || g.Game.Remaining != 3 {
+		t.Errorf("Expected a fresh game after restart, got %+v", g)
+	}
+	assertJsonError(t, request(t, s, "POST", "/games/"+g.ID+"/undo", ""), 409, "nothing to undo")
+}
+
+func TestGameResumedFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestServer(t, ServerOptions{Workers: 1, SessionDir: dir})
+	g := decodeGame(t, s, "POST", "/games", `{"puzzle": "house"}`, 201)
+	move(t, s, g.ID, 4)
+	move(t, s, g.ID, 2)
+
+	// A new server knows nothing of the game but what was saved.
+	restarted := newTestServer(t, ServerOptions{Workers: 1, SessionDir: dir})
+	resumed := decodeGame(t, restarted, "GET", "/games/"+g.ID, "", 200)
+	if !reflect.DeepEqual(resumed.Game.Path, []uint16{4, 2}) || resumed.Game.Remaining != 7 {
+		t.Errorf("Expected the game resumed after 4-2, got %+v", resumed.Game)
+	}
+	move(t, restarted, g.ID, 1)
+
+	if w := request(t, restarted, "DELETE", "/games/"+g.ID, ""); w.Code != 204 {
+		t.Errorf("Expected 204, got %d", w.Code)
+	}
+	assertJsonError(t, request(t, restarted, "GET", "/games/"+g.ID, ""), 404, "not found")
+}
+
+func TestGameSessionsEvicted(t *testing.T) {
+	now := time.Unix(0, 0)
+	store := newGameStore(t.TempDir(), time.Minute, time.Hour)
+	store.now = func() time.Time { return now }
+	puzzle, err := loadPuzzle("puzzles/house.json", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := store.create("house", puzzle)
+	a.Game.Play(4)
+	store.save(a)
+	b, _ := store.create("house", puzzle)
+
+	now = now.Add(50 * time.Second)
+	store.get(b.ID)
+	now = now.Add(50 * time.Second)
+	store.get(b.ID)
+	if _, ok := store.sessions[a.ID]; ok || len(store.sessions) != 1 {
+		t.Fatalf("Expected only the idle game to be evicted, got %d in memory", len(store.sessions))
+	}
+
+	now = now.Add(time.Hour)
+	b.mu.Lock()
+	reloaded, err := store.get(a.ID)
+	b.mu.Unlock()
+	if _, ok := store.sessions[b.ID]; !ok {
+		t.Error("Expected a game in use to stay in memory")
+	}
+	if err != nil || reloaded == a || !reflect.DeepEqual(reloaded.Game.Path(), solver.Solution{4}) {
+		t.Errorf("Expected the evicted game read back from disk, got %v %v", reloaded, err)
+	}
+}
+
+func TestGameSessionsExpired(t *testing.T) {
+	now := time.Now()
+	dir := t.TempDir()
+	store := newGameStore(dir, time.Minute, 24*time.Hour)
+	store.now = func() time.Time { return now }
+	puzzle, err := loadPuzzle("puzzles/house.json", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, _ := store.create("house", puzzle)
+	recent, _ := store.create("house", puzzle)
+	os.Chtimes(store.file(old.ID), now, now.Add(-48*time.Hour))
+
+	// Files are looked at once an hour, when a game is created.
+	now = now.Add(2 * time.Hour)
+	store.create("house", puzzle)
+	if _, err := os.Stat(store.file(old.ID)); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected the game not played for 2 days to be deleted, got %v", err)
+	}
+	if _, err := os.Stat(store.file(recent.ID)); err != nil {
+		t.Errorf("Expected the recent game to be kept, got %v", err)
+	}
+}
+
+func TestGameDeletedWhilePlayed(t *testing.T) {
+	s := newTestServer(t, ServerOptions{Workers: 1, SessionDir: t.TempDir()})
+	g := decodeGame(t, s, "POST", "/games", `{"puzzle": "house"}`, 201)
+	session, _ := s.games.get(g.ID)
+	session.mu.Lock()
+	removed := make(chan error)
+	go func() {
+		removed <- s.games.remove(g.ID)
+	}()
+	// A move saved while the game is being deleted must not bring it back.
+	session.Game.Play(4)
+	err := s.games.save(session)
+	session.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-removed; err != nil {
+		t.Fatal(err)
+	}
+	if err := s.games.save(session); err != errGameNotFound {
+		t.Errorf("Expected a deleted game not to be saved, got %v", err)
+	}
+	assertJsonError(t, request(t, s, "POST", "/games/"+g.ID+"/moves", `{"point": 2}`), 404, "not found")
+	if _, err := os.Stat(s.games.file(g.ID)); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected the file to stay deleted, got %v", err)
+	}
+}
+
+func TestGameBadRequests(t *testing.T) {
+	s := newTestServer(t, ServerOptions{Workers: 1})
+	assertJsonError(t, request(t, s, "POST", "/games", `{"puzzle": "nope"}`), 404, "puzzle nope not found")
+	assertJsonError(t, request(t, s, "GET", "/games/..%2Fpuzzles", ""), 404, "not found")
+	assertJsonError(t, request(t, s, "GET", "/games/0123456789abcdef", ""), 404, "game 0123456789abcdef not found")
+	g := decodeGame(t, s, "POST", "/games", `{"puzzle": "house"}`, 201)
+	assertJsonError(t, request(t, s, "POST", "/games/"+g.ID+"/moves", `{"point": "one"}`), 400, "invalid request")
+}
@@ -1,17 +1,3 @@
 module github.com/wricardo/OneTDraw-Solver
 
 go 1.24.4
-
-require (
-	github.com/clbanning/x2j v0.0.0-20191024224557-825249438eec // indirect
-	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/stretchr/codecs v0.0.0-20170403063245-04a5b1e1910d // indirect
-	github.com/stretchr/goweb v0.0.0-20140611034857-d7518e7a1cf8 // indirect
-	github.com/stretchr/objx v0.5.2 // indirect
-	github.com/stretchr/stew v0.0.0-20130812190256-80ef0842b48b // indirect
-	github.com/stretchr/testify v1.11.1 // indirect
-	github.com/ugorji/go/codec v1.3.0 // indirect
-	gopkg.in/mgo.v2 v2.0.0-20190816093944-a6b53ec6cb22 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
-)
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+// Status of a job.
+const (
+	jobQueued   = "queued"
+	jobRunning  = "running"
+	jobDone     = "done"
+	jobFailed   = "failed"
+	jobCanceled = "canceled"
+)
+
+const (
+	defaultJobQueue     = 64
+	defaultJobRetention = time.Hour
+)
+
+var (
+	errQueueFull   = errors.New("too many jobs waiting, try again later")
+	errQueueClosed = errors.New("the server is shutting down")
+)
+
+// job is a solve or a count of one puzzle, run in the background by a
+// jobQueue.
+type job struct {
+	id         string
+	puzzleName string
+	puzzle     *solver.Puzzle
+	countOnly  bool
+	timeout    time.Duration
+	limits     searchLimits
+	progress   solver.Progress
+	ctx        context.Context
+	cancel     context.CancelFunc
+
+	mu        sync.Mutex
+	status    string
+	canceled  bool
+	created   time.Time
+	started   time.Time
+	finished  time.Time
+	solutions solver.Solutions
+	count     int
+	err       string
+}
+
+func (this *job) isFinished() bool {
+	return this.status == jobDone || this.status == jobFailed || this.status == jobCanceled
+}
+
+// jobQueue runs jobs on a fixed number of workers, keeping finished ones
+// around for retention so their results can be fetched.
+type jobQueue struct {
+	cache     *solveCache
+	metrics   *serverMetrics
+	slots     solveSlots
+	retention time.Duration
+	now       func() time.Time
+	pending   chan *job
+	wg        sync.WaitGroup
+
+	mu     sync.Mutex
+	jobs   map[string]*job
+	closed bool
+}
+
+func newJobQueue(workers, size int, retention time.Duration, cache *solveCache, metrics *serverMetrics, slots solveSlots) *jobQueue {
+	q := &jobQueue{
+		cache:     cache,
+		metrics:   metrics,
+		slots:     slots,
+		retention: retention,
+		now:       time.Now,
+		pending:   make(chan *job, size),
+		jobs:      map[string]*job{},
+	}
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			for j := range q.pending {
+				q.run(j)
+			}
+		}()
+	}
+	return q
+}
+
+// submit queues a new job, failing with errQueueFull when every slot of
+// the queue is taken and with errQueueClosed once the queue is closed.
+func (this *jobQueue) submit(puzzleName string, puzzle *solver.Puzzle, countOnly bool, timeout time.Duration, limits searchLimits) (*job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	j := &job{
+		id:         id,
+		puzzleName: puzzleName,
+		puzzle:     puzzle,
+		countOnly:  countOnly,
+		timeout:    timeout,
+		limits:     limits,
+		status:     jobQueued,
+		created:    this.now(),
+	}
+	j.ctx, j.cancel = context.WithCancel(context.Background())
+
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.closed {
+		j.cancel()
+		return nil, errQueueClosed
+	}
+	this.prune()
+	select {
+	case this.pending <- j:
+	default:
+		j.cancel()
+		return nil, errQueueFull
+	}
+	this.jobs[id] = j
+	return j, nil
+}
+
+func (this *jobQueue) get(id string) *job {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.prune()
+	return this.jobs[id]
+}
+
+// cancel stops a queued or running job. A finished job is forgotten
+// instead, and false is returned for both when there is no such job.
+func (this *jobQueue) cancel(id string) (*job, bool) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	j, ok := this.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	switch j.status {
+	case jobQueued:
+		j.status = jobCanceled
+		j.finished = this.now()
+		j.cancel()
+	case jobRunning:
+		j.canceled = true
+		j.cancel()
+	default:
+		delete(this.jobs, id)
+	}
+	return j, true
+}
+
+// close stops taking jobs and lets the queued and running ones finish
+// until ctx is done, when they are canceled. It returns once the workers
+// have stopped; the jobs can still be fetched afterwards.
+func (this *jobQueue) close(ctx context.Context) {
+	this.mu.Lock()
+	if !this.closed {
+		this.closed = true
+		close(this.pending)
+	}
+	this.mu.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		this.wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return
+	case <-ctx.Done():
+	}
+	this.mu.Lock()
+	for _, j := range this.jobs {
+		j.cancel()
+	}
+	this.mu.Unlock()
+	<-stopped
+}
+
+// prune forgets the jobs finished for longer than the retention period.
+// The caller holds this.mu.
+func (this *jobQueue) prune() {
+	now := this.now()
+	for id, j := range this.jobs {
+		j.mu.Lock()
+		expired := j.isFinished() && now.Sub(j.finished) > this.retention
+		j.mu.Unlock()
+		if expired {
+			delete(this.jobs, id)
+		}
+	}
+}
+
+func (this *jobQueue) run(j *job) {
+	j.mu.Lock()
+	if j.status != jobQueued || j.ctx.Err() != nil {
+		if !j.isFinished() {
+			j.status = jobCanceled
+			j.finished = this.now()
+		}
+		j.mu.Unlock()
+		return
+	}
+	j.status = jobRunning
+	j.started = this.now()
+	j.mu.Unlock()
+
+	hash := j.puzzle.CanonicalHash()
+	this.cache.seen(j.puzzleName, hash)
+	if entry, ok := this.cache.get(hash, !j.countOnly); ok && j.limits.check(entry.Count) == nil {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.count, j.solutions = entry.Count, entry.Solutions
+		if j.countOnly {
+			j.solutions = nil
+		}
+		j.status = jobDone
+		j.finished = this.now()
+		j.cancel()
+		return
+	}
+
+	ctx := j.ctx
+	if j.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.timeout)
+		defer cancel()
+	}
+	// The job keeps running while it waits for a solve slot.
+	err := this.slots.acquire(ctx)
+	if err == nil {
+		err = this.search(ctx, j, hash)
+		this.slots.release()
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.finished = this.now()
+	switch {
+	case err == nil:
+		j.status = jobDone
+	case j.canceled || j.ctx.Err() != nil:
+		j.status = jobCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		j.status = jobFailed
+		j.err = fmt.Sprintf("timed out after %s", j.timeout)
+	default:
+		j.status = jobFailed
+		j.err = j.limits.describe(err).Error()
+	}
+	j.cancel()
+}
+
+// search runs the search of a job within its limits, caching the result
+// when it completes.
+func (this *jobQueue) search(ctx context.Context, j *job, hash string) error {
+	j.limits.apply(&j.progress)
+	done := this.metrics.search(&j.progress)
+	defer done()
+	var err error
+	if j.countOnly {
+		var n int
+		n, _, err = solver.CountProgress(ctx, j.puzzle, &j.progress)
+		j.mu.Lock()
+		j.count = n
+		j.mu.Unlock()
+		if err == nil {
+			this.cache.put(&cacheEntry{Hash: hash, Count: n})
+		}
+	} else {
+		j.progress.OnSolution = func(s solver.Solution) {
+			j.mu.Lock()
+			j.solutions = append(j.solutions, s)
+			j.count = len(j.solutions)
+			j.mu.Unlock()
+		}
+		var solutions *solver.Solutions
+		solutions, _, err = solver.SolveProgress(ctx, j.puzzle, &j.progress)
+		if err == nil {
+			this.cache.put(&cacheEntry{Hash: hash, Count: len(*solutions), Solutions: *solutions})
+		}
+	}
+	return err
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+const (
+	defaultSolutionsLimit = 1000
+	maxRequestBody        = 1 << 20
+)
+
+// jobRequest is the body of POST /jobs.
+type jobRequest struct {
+	// Puzzle is the name of a puzzle, as in /puzzle/solve/{filename}.
+	Puzzle string `json:"puzzle"`
+	// Kind is "solve" to keep the solutions or "count" to only count them.
+	Kind string `json:"kind"`
+	// Timeout, a duration such as "30s", stops the job when it runs for
+	// longer, waiting for a solve slot included. There is none by default.
+	Timeout string `json:"timeout"`
+	// MaxNodes and MaxSolutions fail the job once its search explored more
+	// nodes or found more solutions. They default to the most the server
+	// allows to jobs, and cannot be higher.
+	MaxNodes     int64 `json:"max_nodes"`
+	MaxSolutions int64 `json:"max_solutions"`
+}
+
+// jobResponse describes a job. Solutions holds a page of the solutions
+// found so far, starting at Offset, and Count the number found so far.
+type jobResponse struct {
+	ID        string           `json:"id"`
+	Puzzle    string           `json:"puzzle"`
+	Kind      string           `json:"kind"`
+	Status    string           `json:"status"`
+	Created   time.Time        `json:"created"`
+	Started   *time.Time       `json:"started,omitempty"`
+	Finished  *time.Time       `json:"finished,omitempty"`
+	Progress  jobProgress      `json:"progress"`
+	Count     int              `json:"count"`
+	Offset    int              `json:"offset,omitempty"`
+	Solutions solver.Solutions `json:"solutions,omitempty"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// jobProgress tells how far a job got: the search nodes explored and the
+// starting points whose search is over, out of Starts.
+type jobProgress struct {
+	Nodes      int64 `json:"nodes"`
+	Starts     int64 `json:"starts"`
+	StartsDone int64 `json:"starts_done"`
+}
+
+// response describes the job with the solutions from offset, limit of them
+// at most.
+func (this *job) response(offset, limit int) jobResponse {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	r := jobResponse{
+		ID:      this.id,
+		Puzzle:  this.puzzleName,
+		Kind:    "solve",
+		Status:  this.status,
+		Created: this.created,
+		Progress: jobProgress{
+			Nodes:      this.progress.Nodes.Load(),
+			Starts:     this.progress.Starts.Load(),
+			StartsDone: this.progress.StartsDone.Load(),
+		},
+		Count: this.count,
+		Error: this.err,
+	}
+	if this.countOnly {
+		r.Kind = "count"
+		// A count only returns its total at the end, the walkers count as
+		// they go. The solution over the limit, stopping the search, is not
+		// one found.
+		if this.status == jobRunning {
+			n := this.progress.Solutions.Load()
+			if max := this.limits.MaxSolutions; max > 0 && n > max {
+				n = max
+			}
+			r.Count = int(n)
+		}
+	}
+	if !this.started.IsZero() {
+		r.Started = &this.started
+	}
+	if this.isFinished() {
+		r.Finished = &this.finished
+	}
+	if offset < len(this.solutions) {
+		end := len(this.solutions)
+		if end-offset > limit {
+			end = offset + limit
+		}
+		r.Offset = offset
+		r.Solutions = append(solver.Solutions(nil), this.solutions[offset:end]...)
+	}
+	return r
+}
+
+// createJob queues a solve or count, answering 202 with the job to poll.
+func (this *Server) createJob(w http.ResponseWriter, r *http.Request) error {
+	var req jobRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		return httpErrorf(http.StatusBadRequest, "invalid job: %v", err)
+	}
+	if req.Kind != "" && req.Kind != "solve" && req.Kind != "count" {
+		return httpErrorf(http.StatusBadRequest, "unknown kind %q, expected solve or count", req.Kind)
+	}
+	var timeout time.Duration
+	if req.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(req.Timeout); err != nil || timeout <= 0 {
+			return httpErrorf(http.StatusBadRequest, "invalid timeout %q", req.Timeout)
+		}
+	}
+	limits, err := this.jobLimits(req.MaxNodes, req.MaxSolutions)
+	if err != nil {
+		return err
+	}
+	req.Puzzle = puzzleName(req.Puzzle)
+	puzzle, err := this.loadPuzzle(req.Puzzle)
+	if err != nil {
+		return err
+	}
+
+	j, err := this.jobs.submit(req.Puzzle, puzzle, req.Kind == "count", timeout, limits)
+	if err == errQueueFull || err == errQueueClosed {
+		return &httpError{Status: http.StatusServiceUnavailable, Err: err}
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Location", "/jobs/"+j.id)
+	return writeJson(w, http.StatusAccepted, j.response(0, 0))
+}
+
+// getJob reports the status of a job, with the solutions found so far
+// paged by the offset and limit query parameters.
+func (this *Server) getJob(w http.ResponseWriter, r *http.Request) error {
+	j := this.jobs.get(r.PathValue("id"))
+	if j == nil {
+		return httpErrorf(http.StatusNotFound, "job %s not found", r.PathValue("id"))
+	}
+	offset, limit := 0, defaultSolutionsLimit
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{{"offset", &offset}, {"limit", &limit}} {
+		if v := r.URL.Query().Get(param.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return httpErrorf(http.StatusBadRequest, "invalid %s %q", param.name, v)
+			}
+			*param.value = n
+		}
+	}
+	return writeJson(w, http.StatusOK, j.response(offset, limit))
+}
+
+// deleteJob cancels a job that has not finished, or forgets a finished one.
+func (this *Server) deleteJob(w http.ResponseWriter, r *http.Request) error {
+	j, ok := this.jobs.cancel(r.PathValue("id"))
+	if !ok {
+		return httpErrorf(http.StatusNotFound, "job %s not found", r.PathValue("id"))
+	}
+	return writeJson(w, http.StatusOK, j.response(0, 0))
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func request(t *testing.T, s *Server, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+func decodeJob(t *testing.T, w *httptest.ResponseRecorder) jobResponse {
+	t.Helper()
+	var job jobResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+		t.Fatalf("Expected a job, got %d %q", w.Code, w.Body.String())
+	}
+	return job
+}
+
+// waitForJob polls the job until it is finished.
+func waitForJob(t *testing.T, s *Server, id, query string) jobResponse {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		job := decodeJob(t, request(t, s, "GET", "/jobs/"+id+query, ""))
+		if job.Finished != nil {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Job %s did not finish", id)
+	return jobResponse{}
+}
+
+func TestJobCount(t *testing.T) {
+	s := newTestServer(t, ServerOptions{Workers: 1})
+	w := request(t, s, "POST", "/jobs", `{"puzzle": "house", "kind": "count"}`)
+	if w.Code != 202 {
+		t.Fatalf("Expected 202, got %d %s", w.Code, w.Body.String())
+	}
+	job := decodeJob(t, w)
+	if w.Header().Get("Location") != "/jobs/"+job.ID || job.Kind != "count" {
+		t.Errorf("Unexpected job %+v at %q", job, w.Header().Get("Location"))
+	}
+
+	job = waitForJob(t, s, job.ID, "")
+	if job.Status != jobDone || job.Count != 88 || job.Solutions != nil {
+		t.Errorf("Expected 88 solutions counted, got %+v", job)
+	}
+	if job.Progress.Nodes == 0 || job.Progress.StartsDone != job.Progress.Starts {
+		t.Errorf("Expected the progress of a finished search, got %+v", job.Progress)
+	}
+}
+
+func TestJobCountProgress(t *testing.T) {
+	s := newTestServer(t, ServerOptions{Workers: 1})
+	job := decodeJob(t, request(t, s, "POST", "/jobs", `{"puzzle": "level54", "kind": "count"}`))
+	defer request(t, s, "DELETE", "/jobs/"+job.ID, "")
+	deadline := time.Now().Add(10 * time.Second)
+	for job.Status != jobRunning || job.Count == 0 {
+		if time.Now().After(deadline) || job.Finished != nil {
+			t.Fatalf("Expected the solutions counted so far, got %+v", job)
+		}
+		time.Sleep(5 * time.Millisecond)
+		job = decodeJob(t, request(t, s, "GET", "/jobs/"+job.ID, ""))
+	}
+}
+
+func TestJobSolvePaging(t *testing.T) {
+	s := newTestServer(t, ServerOptions{Workers: 1})
+	job := decodeJob(t, request(t, s, "POST", "/jobs", `{"puzzle": "regular_triangle.json"}`))
+	job = waitForJob(t, s, job.ID, "?offset=4&limit=10")
+	if job.Puzzle != "regular_triangle" {
+		t.Errorf("Expected the puzzle named without its extension, got %q", job.Puzzle)
+	}
+	if job.Status != jobDone || job.Count != 6 || job.Offset != 4 || len(job.Solutions) != 2 {
+		t.Errorf("Expected the last 2 of 6 solutions, got %+v", job)
+	}
+	job = decodeJob(t, request(t, s, "GET", "/jobs/"+job.ID+"?limit=1", ""))
+	if len(job.Solutions) != 1 {
+		t.Errorf("Expected 1 solution, got %d", len(job.Solutions))
+	}
+	assertJsonError(t, request(t, s, "GET", "/jobs/"+job.ID+"?limit=-1", ""), 400, "invalid limit")
+}
+
+func TestJobCancel(t *testing.T) {
+	s := newTestServer(t, ServerOptions{Workers: 1, QueueSize: 1})
+	running := decodeJob(t, request(t, s, "POST", "/jobs", `{"puzzle": "level54", "kind": "count"}`))
+	for decodeJob(t, request(t, s, "GET", "/jobs/"+running.ID, "")).Status != jobRunning {
+		time.Sleep(time.Millisecond)
+	}
+	queued := decodeJob(t, request(t, s, "POST", "/jobs", `{"puzzle": "level54"}`))
+	assertJsonError(t, request(t, s, "POST", "/jobs", `{"puzzle": "house"}`), 503, "too many jobs")
+
+	if job := decodeJob(t, request(t, s, "DELETE", "/jobs/"+queued.ID, "")); job.Status != jobCanceled {
+		t.Errorf("Expected the queued job canceled at once, got %s", job.Status)
+	}
+	request(t, s, "DELETE", "/jobs/"+running.ID, "")
+	if job := waitForJob(t, s, running.ID, ""); job.Status != jobCanceled {
+		t.Errorf("Expected the running job canceled, got %s", job.Status)
+	}
+
+	if w := request(t, s, "DELETE", "/jobs/"+running.ID, ""); w.Code != 200 {
+		t.Errorf("Expected the finished job deleted, got %d", w.Code)
+	}
+	assertJsonError(t, request(t, s, "GET", "/jobs/"+running.ID, ""), 404, "not found")
+}
+
+func TestJobTimeout(t *testing.T) {
+	s := newTestServer(t, ServerOptions{Workers: 1})
+	job := decodeJob(t, request(t, s, "POST", "/jobs", `{"puzzle": "level54", "kind": "count", "timeout": "50ms"}`))
+	job = waitForJob(t, s, job.ID, "")
+	if job.Status != jobFailed || job.Error != "timed out after 50ms" {
+		t.Errorf("Expected the job to time out, got %+v", job)
+	}
+}
+
+func TestJobRetention(t *testing.T) {
+	s := newTestServer(t, ServerOptions{Workers: 1, JobRetention: time.Minute})
+	now := time.Now()
+	s.jobs.now = func() time.Time { return now }
+	job := decodeJob(t, request(t, s, "POST", "/jobs", `{"puzzle": "regular_triangle", "kind": "count"}`))
+	waitForJob(t, s, job.ID, "")
+
+	now = now.Add(2 * time.Minute)
+	assertJsonError(t, request(t, s, "GET", "/jobs/"+job.ID, ""), 404, "not found")
+}
+
+func TestJobBadRequests(t *testing.T) {
+	s := newTestServer(t, ServerOptions{Workers: 1})
+	assertJsonError(t, request(t, s, "POST", "/jobs", `{"puzzle": "nope"}`), 404, "puzzle nope not found")
+	assertJsonError(t, request(t, s, "POST", "/jobs", `{"puzzle": "house", "kind": "draw"}`), 400, `unknown kind "draw"`)
+	assertJsonError(t, request(t, s, "POST", "/jobs", `{"puzzle": "house", "timeout": "soon"}`), 400, `invalid timeout "soon"`)
+	assertJsonError(t, request(t, s, "POST", "/jobs", `{"puzle": "house"}`), 400, "invalid job")
+	assertJsonError(t, request(t, s, "DELETE", "/jobs/0000", ""), 404, "job 0000 not found")
+}
+
+func TestJobsShutdown(t *testing.T) {
+	s := newTestServer(t, ServerOptions{Workers: 1})
+	counted := decodeJob(t, request(t, s, "POST", "/jobs", `{"puzzle": "house", "kind": "count"}`))
+	queued := decodeJob(t, request(t, s, "POST", "/jobs", `{"puzzle": "house"}`))
+	s.Shutdown(context.Background())
+	for _, id := range []string{counted.ID, queued.ID} {
+		if job := decodeJob(t, request(t, s, "GET", "/jobs/"+id, "")); job.Status != jobDone || job.Count != 88 {
+			t.Errorf("Expected the job to finish, got %+v", job)
+		}
+	}
+	assertJsonError(t, request(t, s, "POST", "/jobs", `{"puzzle": "house"}`), 503, "shutting down")
+}
+
+func TestJobsShutdownTimeout(t *testing.T) {
+	s := newTestServer(t, ServerOptions{Workers: 1})
+	job := decodeJob(t, request(t, s, "POST", "/jobs", `{"puzzle": "level54", "kind": "count"}`))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	s.Shutdown(ctx)
+	if job = decodeJob(t, request(t, s, "GET", "/jobs/"+job.ID, "")); job.Status != jobCanceled {
+		t.Errorf("Expected the job to be canceled, got %+v", job)
+	}
+}
@@ -0,0 +1,339 @@
+// Package layout computes 2D positions for the points of a puzzle, so every
+// renderer (web UI, SVG, raster) draws the same picture. Coordinates follow
+// screen conventions: the origin is the top-left corner and Y grows downwards.
+package layout
+
+import (
+	"math"
+	"sort"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+type Strategy int
+
+const (
+	// Auto uses explicit coordinates when every point has them, the layered
+	// layout when every point has a level and the force-directed one otherwise.
+	Auto Strategy = iota
+	Explicit
+	Layered
+	ForceDirected
+)
+
+// Margin is the fraction of the smaller side kept free around explicit and
+// force-directed layouts.
+const Margin = 0.1
+
+const forceIterations = 300
+
+type Node struct {
+	ID     int
+	X      float64
+	Y      float64
+	Hidden bool
+}
+
+type Layout struct {
+	Width  float64
+	Height float64
+	Nodes  []Node
+	index  map[int]int
+}
+
+// Compute lays the puzzle out in a width x height box using the Auto strategy.
+func Compute(puzzle *solver.Puzzle, width, height float64) *Layout {
+	return ComputeWith(puzzle, width, height, Auto)
+}
+
+// ComputeWith lays the puzzle out with the given strategy. Explicit falls back
+// to the origin for points without coordinates.
+func ComputeWith(puzzle *solver.Puzzle, width, height float64, strategy Strategy) *Layout {
+	points := collectPoints(puzzle)
+	if strategy == Auto {
+		strategy = pickStrategy(points)
+	}
+
+	l := &Layout{Width: width, Height: height, Nodes: make([]Node, len(points)), index: make(map[int]int, len(points))}
+	for k, p := range points {
+		l.Nodes[k] = Node{ID: p.ID, Hidden: p.Hide}
+		l.index[p.ID] = k
+	}
+
+	switch strategy {
+	case Explicit:
+		l.explicit(points)
+	case Layered:
+		l.layered(points)
+	default:
+		l.forceDirected(points, puzzle.Edges)
+	}
+	return l
+}
+
+// Position returns the coordinates of the point with the given ID.
+func (this *Layout) Position(id int) (x, y float64, ok bool) {
+	k, ok := this.index[id]
+	if !ok {
+		return 0, 0, false
+	}
+	return this.Nodes[k].X, this.Nodes[k].Y, true
+}
+
+// Apply returns a copy of the puzzle whose points all carry the layout's
+// coordinates, including points that were only referenced by edges.
+func (this *Layout) Apply(puzzle *solver.Puzzle) solver.Puzzle {
+	c := puzzle.Copy()
+	for _, n := range this.Nodes {
+		x, y := n.X, n.Y
+		if p := c.GetPoint(n.ID); p != nil {
+			p.X, p.Y = &x, &y
+		} else {
+			c.Points = append(c.Points, solver.Point{ID: n.ID, X: &x, Y: &y})
+		}
+	}
+	return c
+}
+
+// collectPoints returns the declared points followed by any point that is
+// only referenced by an edge, in ascending ID order.
+// Count returns the number of points Compute lays out for the puzzle.
+func Count(puzzle *solver.Puzzle) int {
+	return len(collectPoints(puzzle))
+}
+
+func collectPoints(puzzle *solver.Puzzle) []solver.Point {
+	points := make([]solver.Point, 0, len(puzzle.Points))
+	seen := make(map[int]struct{}, len(puzzle.Points))
+	for _, p := range puzzle.Points {
+		if _, exists := seen[p.ID]; exists {
+			continue
+		}
+		seen[p.ID] = struct{}{}
+		points = append(points, p)
+	}
+
+	var missing []int
+	for _, e := range puzzle.Edges {
+		for _, id := range []int{int(e.PointA), int(e.PointB)} {
+			if _, exists := seen[id]; !exists {
+				seen[id] = struct{}{}
+				missing = append(missing, id)
+			}
+		}
+	}
+	sort.Ints(missing)
+	for _, id := range missing {
+		points = append(points, solver.Point{ID: id})
+	}
+	return points
+}
+
+func pickStrategy(points []solver.Point) Strategy {
+	if len(points) == 0 {
+		return ForceDirected
+	}
+	allPositioned, allLevelled := true, true
+	for k := range points {
+		if !points[k].HasPosition() {
+			allPositioned = false
+		}
+		if points[k].Level == 0 {
+			allLevelled = false
+		}
+	}
+	if allPositioned {
+		return Explicit
+	}
+	if allLevelled {
+		return Layered
+	}
+	return ForceDirected
+}
+
+func (this *Layout) explicit(points []solver.Point) {
+	for k := range points {
+		if points[k].HasPosition() {
+			this.Nodes[k].X, this.Nodes[k].Y = *points[k].X, *points[k].Y
+		}
+	}
+	this.fit()
+}
+
+// layered spreads the points of each level evenly on one row, levels from
+// top to bottom in ascending order, keeping the order the points were
+// declared in. Each point sits in the middle of its cell.
+func (this *Layout) layered(points []solver.Point) {
+	rows := make(map[int][]int)
+	var levels []int
+	for k, p := range points {
+		if _, exists := rows[p.Level]; !exists {
+			levels = append(levels, p.Level)
+		}
+		rows[p.Level] = append(rows[p.Level], k)
+	}
+	sort.Ints(levels)
+
+	rowHeight := this.Height / float64(len(levels))
+	for r, level := range levels {
+		row := rows[level]
+		cellWidth := this.Width / float64(len(row))
+		for c, k := range row {
+			this.Nodes[k].X = float64(c)*cellWidth + cellWidth/2
+			this.Nodes[k].Y = float64(r)*rowHeight + rowHeight/2
+		}
+	}
+}
+
+// forceDirected runs a deterministic Fruchterman-Reingold simulation. Points
+// with explicit coordinates stay where they are and the others settle around
+// them; without any, the simulation starts from a circle.
+func (this *Layout) forceDirected(points []solver.Point, edges []solver.Edge) {
+	n := len(points)
+	if n == 0 {
+		return
+	}
+
+	pinned := make([]bool, n)
+	var minX, minY, maxX, maxY float64
+	anchors := 0
+	for k := range points {
+		if !points[k].HasPosition() {
+			continue
+		}
+		pinned[k] = true
+		x, y := *points[k].X, *points[k].Y
+		this.Nodes[k].X, this.Nodes[k].Y = x, y
+		if anchors == 0 || x < minX {
+			minX = x
+		}
+		if anchors == 0 || x > maxX {
+			maxX = x
+		}
+		if anchors == 0 || y < minY {
+			minY = y
+		}
+		if anchors == 0 || y > maxY {
+			maxY = y
+		}
+		anchors++
+	}
+
+	size := math.Max(maxX-minX, maxY-minY)
+	if size == 0 {
+		size = 1
+	}
+	cx, cy := (minX+maxX)/2, (minY+maxY)/2
+	free := 0
+	for k := range points {
+		if pinned[k] {
+			continue
+		}
+		angle := 2*math.Pi*float64(free)/float64(n) - math.Pi/2
+		this.Nodes[k].X = cx + size/2*math.Cos(angle)
+		this.Nodes[k].Y = cy + size/2*math.Sin(angle)
+		free++
+	}
+
+	type pair struct{ a, b int }
+	var links []pair
+	linked := make(map[pair]struct{})
+	for _, e := range edges {
+		a, b := this.index[int(e.PointA)], this.index[int(e.PointB)]
+		if a > b {
+			a, b = b, a
+		}
+		if _, exists := linked[pair{a, b}]; exists || a == b {
+			continue
+		}
+		linked[pair{a, b}] = struct{}{}
+		links = append(links, pair{a, b})
+	}
+
+	k := size / math.Sqrt(float64(n))
+	temperature := size / 10
+	dx := make([]float64, n)
+	dy := make([]float64, n)
+	for i := 0; i < forceIterations && free > 0; i++ {
+		for v := range dx {
+			dx[v], dy[v] = 0, 0
+		}
+		for v := 0; v < n; v++ {
+			for u := v + 1; u < n; u++ {
+				ddx, ddy, dist := this.delta(v, u, k)
+				force := k * k / dist
+				dx[v] += ddx / dist * force
+				dy[v] += ddy / dist * force
+				dx[u] -= ddx / dist * force
+				dy[u] -= ddy / dist * force
+			}
+		}
+		for _, l := range links {
+			ddx, ddy, dist := this.delta(l.a, l.b, k)
+			force := dist * dist / k
+			dx[l.a] -= ddx / dist * force
+			dy[l.a] -= ddy / dist * force
+			dx[l.b] += ddx / dist * force
+			dy[l.b] += ddy / dist * force
+		}
+		for v := 0; v < n; v++ {
+			if pinned[v] {
+				continue
+			}
+			length := math.Hypot(dx[v], dy[v])
+			if length == 0 {
+				continue
+			}
+			step := math.Min(length, temperature)
+			this.Nodes[v].X += dx[v] / length * step
+			this.Nodes[v].Y += dy[v] / length * step
+		}
+		temperature *= 0.98
+	}
+	this.fit()
+}
+
+// delta returns the vector from u to v and its length, nudging coincident
+// points apart deterministically so forces stay finite.
+func (this *Layout) delta(v, u int, k float64) (dx, dy, dist float64) {
+	dx = this.Nodes[v].X - this.Nodes[u].X
+	dy = this.Nodes[v].Y - this.Nodes[u].Y
+	dist = math.Hypot(dx, dy)
+	if dist < 1e-9 {
+		dx, dy, dist = k*1e-3, k*1e-3*float64(v-u), k*1e-3*math.Hypot(1, float64(v-u))
+	}
+	return
+}
+
+// fit scales and centres the nodes into the layout box, keeping the aspect
+// ratio and leaving Margin free on every side.
+func (this *Layout) fit() {
+	if len(this.Nodes) == 0 {
+		return
+	}
+	minX, minY := this.Nodes[0].X, this.Nodes[0].Y
+	maxX, maxY := minX, minY
+	for _, n := range this.Nodes {
+		minX, maxX = math.Min(minX, n.X), math.Max(maxX, n.X)
+		minY, maxY = math.Min(minY, n.Y), math.Max(maxY, n.Y)
+	}
+
+	pad := Margin * math.Min(this.Width, this.Height)
+	innerW, innerH := this.Width-2*pad, this.Height-2*pad
+	scale := 0.0
+	if maxX > minX {
+		scale = innerW / (maxX - minX)
+	}
+	if maxY > minY {
+		sy := innerH / (maxY - minY)
+		if scale == 0 || sy < scale {
+			scale = sy
+		}
+	}
+
+	cx, cy := (minX+maxX)/2, (minY+maxY)/2
+	for k := range this.Nodes {
+		this.Nodes[k].X = this.Width/2 + (this.Nodes[k].X-cx)*scale
+		this.Nodes[k].Y = this.Height/2 + (this.Nodes[k].Y-cy)*scale
+	}
+}
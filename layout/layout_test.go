@@ -0,0 +1,138 @@
+package layout
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+func loadPuzzle(t *testing.T, filename string) *solver.Puzzle {
+	content, err := os.ReadFile("../puzzles/" + filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := solver.NewPuzzleFromBytes(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func assertPosition(t *testing.T, l *Layout, id int, x, y float64) {
+	t.Helper()
+	gx, gy, ok := l.Position(id)
+	if !ok {
+		t.Fatalf("Expected point %d to be laid out", id)
+	}
+	if math.Abs(gx-x) > 1e-6 || math.Abs(gy-y) > 1e-6 {
+		t.Errorf("Point %d: expected (%v, %v), got (%v, %v)", id, x, y, gx, gy)
+	}
+}
+
+func TestLayeredMatchesLevels(t *testing.T) {
+	// Same math the canvas UI used: one row per level, points centred in
+	// equal cells.
+	p := loadPuzzle(t, "jamaican_flag.json")
+	l := Compute(p, 500, 500)
+
+	assertPosition(t, l, 1, 125, 125)
+	assertPosition(t, l, 2, 375, 125)
+	assertPosition(t, l, 3, 125, 375)
+	assertPosition(t, l, 4, 375, 375)
+}
+
+func TestLayeredKeepsHiddenSpacers(t *testing.T) {
+	p := loadPuzzle(t, "level54.json")
+	l := Compute(p, 500, 500)
+
+	// Level 2 holds the hidden point -2 followed by point 4.
+	assertPosition(t, l, -2, 125, 150)
+	assertPosition(t, l, 4, 375, 150)
+	for _, n := range l.Nodes {
+		if n.ID < 0 && !n.Hidden {
+			t.Errorf("Expected spacer %d to be hidden", n.ID)
+		}
+	}
+}
+
+func TestExplicitCoordinatesAreFitted(t *testing.T) {
+	x1, y1, x2, y2 := 0.0, 0.0, 10.0, 5.0
+	p := &solver.Puzzle{
+		Points: []solver.Point{{ID: 1, X: &x1, Y: &y1}, {ID: 2, X: &x2, Y: &y2}},
+		Edges:  []solver.Edge{{PointA: 1, PointB: 2, Count: 1}},
+	}
+	l := Compute(p, 100, 100)
+
+	// The 10x5 box is scaled to the 80px inner width and centred.
+	assertPosition(t, l, 1, 10, 30)
+	assertPosition(t, l, 2, 90, 70)
+}
+
+func TestForceDirectedWithoutPoints(t *testing.T) {
+	p := loadPuzzle(t, "directional_triangle.json")
+	l := Compute(p, 500, 500)
+
+	if len(l.Nodes) != 3 {
+		t.Fatalf("Expected 3 nodes, got %d", len(l.Nodes))
+	}
+	for _, n := range l.Nodes {
+		if n.X < 0 || n.X > 500 || n.Y < 0 || n.Y > 500 {
+			t.Errorf("Node %d outside the box: (%v, %v)", n.ID, n.X, n.Y)
+		}
+	}
+	// A triangle settles into three distinct corners.
+	for i := 0; i < 3; i++ {
+		for j := i + 1; j < 3; j++ {
+			a, b := l.Nodes[i], l.Nodes[j]
+			if math.Hypot(a.X-b.X, a.Y-b.Y) < 50 {
+				t.Errorf("Nodes %d and %d are too close", a.ID, b.ID)
+			}
+		}
+	}
+
+	again := Compute(p, 500, 500)
+	for k := range l.Nodes {
+		if l.Nodes[k] != again.Nodes[k] {
+			t.Errorf("Expected deterministic layout, got %+v and %+v", l.Nodes[k], again.Nodes[k])
+		}
+	}
+}
+
+func TestForceDirectedKeepsPinnedPoints(t *testing.T) {
+	x1, y1, x2, y2 := 0.0, 0.0, 4.0, 0.0
+	p := &solver.Puzzle{
+		Points: []solver.Point{{ID: 1, X: &x1, Y: &y1}, {ID: 2, X: &x2, Y: &y2}, {ID: 3}},
+		Edges: []solver.Edge{
+			{PointA: 1, PointB: 2, Count: 1},
+			{PointA: 2, PointB: 3, Count: 1},
+			{PointA: 3, PointB: 1, Count: 1},
+		},
+	}
+	l := Compute(p, 500, 500)
+
+	ax, ay, _ := l.Position(1)
+	bx, by, _ := l.Position(2)
+	if ay != by || ax >= bx {
+		t.Errorf("Expected pinned points to stay on one row, got (%v, %v) and (%v, %v)", ax, ay, bx, by)
+	}
+}
+
+func TestApplyPositionsEveryPoint(t *testing.T) {
+	p := loadPuzzle(t, "directional_triangle.json")
+	l := Compute(p, 500, 500)
+	positioned := l.Apply(p)
+
+	if len(p.Points) != 0 {
+		t.Error("Original puzzle was modified")
+	}
+	if len(positioned.Points) != 3 {
+		t.Fatalf("Expected 3 points, got %d", len(positioned.Points))
+	}
+	for _, point := range positioned.Points {
+		if !point.HasPosition() {
+			t.Errorf("Point %d has no position", point.ID)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+// Limits of serve, which NewServer leaves off.
+const (
+	defaultMaxNodes     = 50000000
+	defaultMaxSolutions = 100000
+	// Jobs can ask for more, up to these.
+	defaultMaxJobNodes     = 10000000000
+	defaultMaxJobSolutions = 10000000
+	defaultRateLimit       = 5
+	defaultRateBurst       = 20
+)
+
+// errSearchTimeout stops a search run for a request past its timeout.
+var errSearchTimeout = errors.New("search timed out")
+
+// solveSlots bounds the searches running at once, for requests and jobs
+// alike, as every search runs one goroutine per starting point.
+type solveSlots chan struct{}
+
+func newSolveSlots(n int) solveSlots {
+	return make(solveSlots, n)
+}
+
+// tryAcquire takes a slot if one is free.
+func (this solveSlots) tryAcquire() bool {
+	select {
+	case this <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// acquire waits for a slot until ctx is done.
+func (this solveSlots) acquire(ctx context.Context) error {
+	select {
+	case this <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (this solveSlots) release() {
+	<-this
+}
+
+// rateLimiter is a token bucket per client: each holds up to burst
+// tokens, refilled at rate per second, and a request takes one.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns nil, which allows everything, for a rate of zero.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rate: rate, burst: float64(burst), now: time.Now, buckets: map[string]*tokenBucket{}}
+}
+
+// allow takes a token of client, or tells how long until there is one.
+func (this *rateLimiter) allow(client string) (bool, time.Duration) {
+	if this == nil {
+		return true, 0
+	}
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	now := this.now()
+	this.prune(now)
+	b, ok := this.buckets[client]
+	if !ok {
+		b = &tokenBucket{tokens: this.burst, last: now}
+		this.buckets[client] = b
+	}
+	b.tokens = math.Min(this.burst, b.tokens+now.Sub(b.last).Seconds()*this.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / this.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// prune forgets, once a minute, the clients whose bucket is full again,
+// as a new bucket would be the same. The caller holds this.mu.
+func (this *rateLimiter) prune(now time.Time) {
+	if now.Sub(this.lastPrune) < time.Minute {
+		return
+	}
+	this.lastPrune = now
+	for client, b := range this.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*this.rate >= this.burst {
+			delete(this.buckets, client)
+		}
+	}
+}
+
+// clientAddress identifies the client of a request by its IP address.
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimited answers 429 to the clients sending requests to h faster than
+// the rate limit allows.
+func (this *Server) rateLimited(h handlerFunc) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if ok, wait := this.limiter.allow(clientAddress(r)); !ok {
+			this.metrics.reject("rate_limit")
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			return httpErrorf(http.StatusTooManyRequests, "too many requests, retry in %s", wait.Round(time.Millisecond))
+		}
+		return h(w, r)
+	}
+}
+
+// acquireSlot takes a solve slot for a request, answering 503 when they
+// are all taken rather than making the client wait.
+func (this *Server) acquireSlot(w http.ResponseWriter) error {
+	if this.slots.tryAcquire() {
+		return nil
+	}
+	this.metrics.reject("busy")
+	w.Header().Set("Retry-After", "1")
+	return httpErrorf(http.StatusServiceUnavailable, "the server is solving %d puzzles already, retry later or submit a job", cap(this.slots))
+}
+
+// searchLimits caps the search run for a request. Zero is no limit.
+type searchLimits struct {
+	MaxNodes     int64
+	MaxSolutions int64
+	Timeout      time.Duration
+}
+
+// limits returns the caps of the search of a request: those of the server,
+// lowered by the max_nodes and max_solutions query parameters.
+func (this *Server) limits(r *http.Request) (searchLimits, error) {
+	limits := searchLimits{MaxNodes: this.maxNodes, MaxSolutions: this.maxSolutions, Timeout: this.solveTimeout}
+	for _, param := range []struct {
+		name  string
+		limit *int64
+	}{{"max_nodes", &limits.MaxNodes}, {"max_solutions", &limits.MaxSolutions}} {
+		value := r.URL.Query().Get(param.name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n <= 0 {
+			return limits, httpErrorf(http.StatusBadRequest, "invalid %s %q", param.name, value)
+		}
+		if *param.limit == 0 || n < *param.limit {
+			*param.limit = n
+		}
+	}
+	return limits, nil
+}
+
+// jobLimits returns the caps of the search of a job: the most the server
+// allows to jobs, unless the job asks for lower ones.
+func (this *Server) jobLimits(maxNodes, maxSolutions int64) (searchLimits, error) {
+	limits := searchLimits{MaxNodes: this.maxJobNodes, MaxSolutions: this.maxJobSolutions}
+	for _, param := range []struct {
+		name  string
+		value int64
+		max   int64
+		limit *int64
+	}{
+		{"max_nodes", maxNodes, this.maxJobNodes, &limits.MaxNodes},
+		{"max_solutions", maxSolutions, this.maxJobSolutions, &limits.MaxSolutions},
+	} {
+		if param.value < 0 {
+			return limits, httpErrorf(http.StatusBadRequest, "invalid %s %d", param.name, param.value)
+		}
+		if param.max > 0 && param.value > param.max {
+			return limits, httpErrorf(http.StatusBadRequest, "%s %d is above the %d allowed to a job", param.name, param.value, param.max)
+		}
+		if param.value > 0 {
+			*param.limit = param.value
+		}
+	}
+	return limits, nil
+}
+
+// apply sets the limits on the progress of a search.
+func (this searchLimits) apply(progress *solver.Progress) *solver.Progress {
+	progress.MaxNodes, progress.MaxSolutions = this.MaxNodes, this.MaxSolutions
+	return progress
+}
+
+// context returns the context of a search run for the request of ctx,
+// stopped with errSearchTimeout past the timeout.
+func (this searchLimits) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if this.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, this.Timeout, errSearchTimeout)
+}
+
+// check fails like a search stopped by the limits when a cached result
+// has more solutions than allowed.
+func (this searchLimits) check(count int) error {
+	if this.MaxSolutions > 0 && int64(count) > this.MaxSolutions {
+		return solver.ErrSolutionLimit
+	}
+	return nil
+}
+
+// describe tells which limit stopped a search, err for other errors.
+func (this searchLimits) describe(err error) error {
+	switch {
+	case errors.Is(err, solver.ErrNodeLimit):
+		return fmt.Errorf("search stopped after exploring %d nodes", this.MaxNodes)
+	case errors.Is(err, solver.ErrSolutionLimit):
+		return fmt.Errorf("puzzle has more than %d solutions", this.MaxSolutions)
+	case errors.Is(err, errSearchTimeout):
+		return fmt.Errorf("search stopped after %s", this.Timeout)
+	}
+	return err
+}
+
+// limitReason names the limit that stopped a search in the metrics, ""
+// when none did.
+func limitReason(err error) string {
+	switch {
+	case errors.Is(err, solver.ErrNodeLimit):
+		return "node_limit"
+	case errors.Is(err, solver.ErrSolutionLimit):
+		return "solution_limit"
+	case errors.Is(err, errSearchTimeout):
+		return "timeout"
+	}
+	return ""
+}
+
+// limitError turns a search stopped by its limits into a 503, suggesting
+// a job for the puzzles too big to solve within a request.
+func (this *Server) limitError(err error, limits searchLimits) error {
+	reason := limitReason(err)
+	if reason == "" {
+		return err
+	}
+	this.metrics.reject(reason)
+	return httpErrorf(http.StatusServiceUnavailable, "%v, the limit of a request: solve it with a job instead", limits.describe(err))
+}
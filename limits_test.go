@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newRateLimiter(1, 2)
+	l.now = func() time.Time { return now }
+	for k := 0; k < 2; k++ {
+		if ok, _ := l.allow("a"); !ok {
+			t.Fatalf("Expected request %d within the burst", k)
+		}
+	}
+	if ok, wait := l.allow("a"); ok || wait != time.Second {
+		t.Errorf("Expected to wait 1s, got %v %s", ok, wait)
+	}
+	if ok, _ := l.allow("b"); !ok {
+		t.Error("Expected another client to have its own bucket")
+	}
+	now = now.Add(500 * time.Millisecond)
+	if ok, wait := l.allow("a"); ok || wait != 500*time.Millisecond {
+		t.Errorf("Expected to wait 500ms, got %v %s", ok, wait)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := l.allow("a"); !ok {
+		t.Error("Expected a token after 1s")
+	}
+
+	now = now.Add(time.Hour)
+	l.allow("c")
+	if len(l.buckets) != 1 {
+		t.Errorf("Expected the full buckets to be pruned, got %d", len(l.buckets))
+	}
+
+	if ok, _ := newRateLimiter(0, 0).allow("a"); !ok {
+		t.Error("Expected no limit with a rate of 0")
+	}
+}
+
+func TestRateLimited(t *testing.T) {
+	s := newTestServer(t, ServerOptions{Workers: 1, RateLimit: 0.1, RateBurst: 1})
+	if w := request(t, s, "GET", "/puzzle/solve/house", ""); w.Code != 200 {
+		t.Fatalf("Expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	w := request(t, s, "GET", "/puzzle/solve/house", "")
+	assertJsonError(t, w, 429, "too many requests")
+	if w.Header().Get("Retry-After") != "10" {
+		t.Errorf("Expected to retry after 10s, got %q", w.Header().Get("Retry-After"))
+	}
+	if w := request(t, s, "GET", "/puzzles", ""); w.Code != 200 {
+		t.Errorf("Expected the other routes not to be limited, got %d", w.Code)
+	}
+}
+
+func TestSolveSlots(t *testing.T) {
+	s := newTestServer(t, ServerOptions{Workers: 1, MaxSolves: 1})
+	request(t, s, "GET", "/puzzle/solve/house", "")
+	s.slots.tryAcquire()
+
+	if w := request(t, s, "GET", "/puzzle/solve/house", ""); w.Code != 200 {
+		t.Errorf("Expected a cached puzzle to need no slot, got %d", w.Code)
+	}
+	for _, target := range []string{"/puzzle/solve/square", "/puzzle/stream/square", "/puzzle/svg/square?solution=0", "/puzzle/gif/house", "/puzzle/svg/house", "/puzzle/get_points/house"} {
+		w := request(t, s, "GET", target, "")
+		assertJsonError(t, w, 503, "solving 1 puzzles already")
+		if w.Header().Get("Retry-After") == "" {
+			t.Errorf("%s: expected a Retry-After header", target)
+		}
+	}
+	assertJsonError(t, request(t, s, "POST", "/puzzle/analyze", `{"puzzle": "1-2"}`), 503, "already")
+
+	// Jobs wait for a slot instead.
+	job := decodeJob(t, request(t, s, "POST", "/jobs", `{"puzzle": "square", "kind": "count"}`))
+	time.Sleep(20 * time.Millisecond)
+	if job = decodeJob(t, request(t, s, "GET", "/jobs/"+job.ID, "")); job.Finished != nil || job.Progress.Starts != 0 {
+		t.Errorf("Expected the job to wait, got %+v", job)
+	}
+	s.slots.release()
+	if job = waitForJob(t, s, job.ID, ""); job.Status != jobDone {
+		t.Errorf("Expected the job to run once the slot is free, got %+v", job)
+	}
+}
+
+func TestCheckLayout(t *testing.T) {
+	var edges []solver.Edge
+	for k := uint16(1); k <= maxLayoutPoints; k++ {
+		edges = append(edges, solver.Edge{PointA: k, PointB: k + 1, Count: 1})
+	}
+	if err, ok := checkLayout(solver.NewPuzzle(edges)).(*httpError); !ok || err.Status != http.StatusBadRequest {
+		t.Errorf("Expected a 400 for %d points, got %v", maxLayoutPoints+1, err)
+	}
+	if err := checkLayout(solver.NewPuzzle(edges[1:])); err != nil {
+		t.Errorf("Expected %d points to be laid out, got %v", maxLayoutPoints, err)
+	}
+}
+
+func TestSearchLimits(t *testing.T) {
+	s := newTestServer(t, ServerOptions{Workers: 1, MaxSolutions: 50, MaxJobSolutions: 100})
+	assertJsonError(t, request(t, s, "GET", "/puzzle/solve/house", ""), 503, "more than 50 solutions")
+	assertJsonError(t, request(t, s, "GET", "/puzzle/solve/house?max_solutions=100", ""), 503, "more than 50 solutions")
+	assertJsonError(t, request(t, s, "GET", "/puzzle/solve/house?max_solutions=10", ""), 503, "more than 10 solutions")
+	assertJsonError(t, request(t, s, "GET", "/puzzle/solve/house?max_nodes=10", ""), 503, "after exploring 10 nodes")
+	assertJsonError(t, request(t, s, "GET", "/puzzle/solve/house?max_nodes=x", ""), 400, "invalid max_nodes")
+	if w := request(t, s, "GET", "/puzzle/svg/house", ""); w.Code != 200 {
+		t.Errorf("Expected no search without a solution, got %d", w.Code)
+	}
+	assertJsonError(t, request(t, s, "GET", "/puzzle/svg/house?solution=0", ""), 503, "more than 50 solutions")
+
+	events := readEvents(t, request(t, s, "GET", "/puzzle/stream/house?max_solutions=5", "").Body.String())
+	var stats streamStats
+	json.Unmarshal([]byte(events[len(events)-1].data), &stats)
+	if stats.Status != jobFailed || !strings.Contains(stats.Error, "more than 5 solutions") || len(events) > 7 {
+		t.Errorf("Expected the stream to fail after 5 solutions, got %d events and %+v", len(events), stats)
+	}
+
+	// Jobs have their own limits, or lower ones they ask for, cached results
+	// included.
+	job := waitForJob(t, s, decodeJob(t, request(t, s, "POST", "/jobs", `{"puzzle": "house"}`)).ID, "")
+	if job.Status != jobDone || job.Count != 88 {
+		t.Errorf("Expected the job to find the 88 solutions, got %+v", job)
+	}
+	job = waitForJob(t, s, decodeJob(t, request(t, s, "POST", "/jobs", `{"puzzle": "house", "max_solutions": 10}`)).ID, "")
+	if job.Status != jobFailed || job.Error != "puzzle has more than 10 solutions" {
+		t.Errorf("Expected the job to fail, got %+v", job)
+	}
+	assertJsonError(t, request(t, s, "POST", "/jobs", `{"puzzle": "house", "max_solutions": 101}`), 400, "above the 100 allowed to a job")
+	assertJsonError(t, request(t, s, "POST", "/jobs", `{"puzzle": "house", "max_nodes": -1}`), 400, "invalid max_nodes -1")
+
+	// From 1, no solution is found before the limit.
+	g := decodeGame(t, s, "POST", "/games", `{"puzzle": "house"}`, 201)
+	move(t, s, g.ID, 1)
+	assertJsonError(t, request(t, s, "GET", "/games/"+g.ID+"/hint?max_nodes=1", ""), 503, "after exploring 1 nodes")
+
+	body := request(t, s, "GET", "/metrics", "").Body.String()
+	for _, line := range []string{
+		`onetdraw_rejected_total{reason="solution_limit"} 5`,
+		`onetdraw_rejected_total{reason="node_limit"} 2`,
+		"onetdraw_solve_slots_in_use 0",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected %q in the metrics", line)
+		}
+	}
+}
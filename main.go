@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"runtime"
+	"strings"
+	"syscall"
 
 	"github.com/wricardo/OneTDraw-Solver/solver"
 )
@@ -14,11 +18,29 @@ var count_only *bool
 var output *string
 
 func main() {
+	args := os.Args[1:]
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") && !isHelpFlag(args[0]) {
+		legacyMain(args)
+		return
+	}
+	if isHelpFlag(args[0]) {
+		args = []string{"help"}
+	}
+	os.Exit(run(args, os.Stdin, os.Stdout, os.Stderr))
+}
+
+func isHelpFlag(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help"
+}
+
+// legacyMain keeps the flags used before there were subcommands working:
+// -solve runs the solver and no arguments start the web server.
+func legacyMain(args []string) {
 	var maxprocs = flag.Bool("maxprocs", true, "Pass false to NOT use all CPU cores available")
-	var puzzle_file_path = flag.String("solve", "", "File path to puzzle to solve")
+	var puzzle_file_path = flag.String("solve", "", "File path to puzzle to solve (JSON, DOT, GraphML, CSV or .otd)")
 	count_only = flag.Bool("count_only", false, "Pass true to display only the count of possible solutions")
-	output = flag.String("output", "clean", "Format of the output. [clean,json]")
-	flag.Parse()
+	output = flag.String("output", "clean", "Format of the output. ["+strings.Join(solver.PrinterNames(), ",")+"]")
+	flag.CommandLine.Parse(args)
 
 	if *maxprocs == true {
 		runtime.GOMAXPROCS(runtime.NumCPU())
@@ -27,24 +49,25 @@ func main() {
 	if len(*puzzle_file_path) > 0 {
 		solveFile(*puzzle_file_path)
 	} else {
-		setupWebServer()
+		if err := setupWebServer(defaultWebserver()); err != nil {
+			log.Fatal(err)
+		}
 	}
 }
 
 func createPuzzleByFilename(puzzle_file_path *string) (*solver.Puzzle, error) {
-	file_content, err := os.ReadFile(*puzzle_file_path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read file %s: %w", *puzzle_file_path, err)
-	}
-	return solver.NewPuzzleFromBytes(file_content)
+	return loadPuzzle(*puzzle_file_path, "", nil)
 }
 
-func getPrinter() solver.SolutionPrinter {
-	if *output == "json" {
-		return solver.JsonPrinter{}
-	} else {
+// getPrinter returns the printer selected with -output, falling back to
+// the clean one for unknown names.
+func getPrinter(puzzle *solver.Puzzle) solver.SolutionPrinter {
+	printer, err := solver.NewPrinter(*output, puzzle)
+	if err != nil {
+		log.Printf("%v, using clean", err)
 		return solver.CleanPrinter{}
 	}
+	return printer
 }
 
 func solveFile(puzzle_file_path string) {
@@ -56,11 +79,16 @@ func solveFile(puzzle_file_path string) {
 		fmt.Println(solver.GetNumberOfSolutions(puzzle))
 	} else {
 		solutions := solver.Solve(puzzle)
-		solutions.Print(getPrinter())
+		if err := solutions.Print(getPrinter(puzzle)); err != nil {
+			log.Fatalf("Error printing solutions: %v", err)
+		}
 	}
 }
 
-func setupWebServer() {
-	ws := Webserver{Address: ":8090"}
-	ws.init()
+// setupWebServer runs the web server until SIGINT or SIGTERM, then shuts
+// it down gracefully.
+func setupWebServer(ws *Webserver) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return ws.Run(ctx)
 }
@@ -74,6 +74,6 @@ func BenchmarkGetPrinter(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_ = getPrinter()
+		_ = getPrinter(nil)
 	}
 }
\ No newline at end of file
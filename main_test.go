@@ -57,7 +57,7 @@ func TestGetPrinter(t *testing.T) {
 	// Test JSON output
 	output = new(string)
 	*output = "json"
-	printer := getPrinter()
+	printer := getPrinter(nil)
 
 	if _, ok := printer.(solver.JsonPrinter); !ok {
 		t.Error("Expected JsonPrinter for 'json' output")
@@ -65,7 +65,7 @@ func TestGetPrinter(t *testing.T) {
 
 	// Test clean output (default)
 	*output = "clean"
-	printer = getPrinter()
+	printer = getPrinter(nil)
 
 	if _, ok := printer.(solver.CleanPrinter); !ok {
 		t.Error("Expected CleanPrinter for 'clean' output")
@@ -73,11 +73,18 @@ func TestGetPrinter(t *testing.T) {
 
 	// Test unknown output (should default to clean)
 	*output = "unknown"
-	printer = getPrinter()
+	printer = getPrinter(nil)
 
 	if _, ok := printer.(solver.CleanPrinter); !ok {
 		t.Error("Expected CleanPrinter for unknown output type")
 	}
+
+	*output = "arrow"
+	printer = getPrinter(nil)
+
+	if _, ok := printer.(solver.ArrowPrinter); !ok {
+		t.Error("Expected ArrowPrinter for 'arrow' output")
+	}
 }
 
 func TestSolveFile(t *testing.T) {
@@ -197,3 +204,71 @@ func createTestPuzzleFile(content string) (string, func()) {
 
 	return tmpfile.Name(), cleanup
 }
+
+func TestCreatePuzzleByFilenameBundledPuzzles(t *testing.T) {
+	files := []string{"puzzles/house.json", "puzzles/level53.json", "puzzles/directional_triangle.json"}
+	for _, filename := range files {
+		puzzle, err := createPuzzleByFilename(&filename)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", filename, err)
+			continue
+		}
+		if filename == "puzzles/level53.json" {
+			spacer := puzzle.GetPoint(-1)
+			if spacer == nil || !spacer.Hide {
+				t.Errorf("%s: expected hidden spacer point -1, got %+v", filename, spacer)
+			}
+		}
+	}
+}
+
+func TestCreatePuzzleByFilenameRejectsInvalidPuzzle(t *testing.T) {
+	filename, cleanup := createTestPuzzleFile(`{"Points": [{"Point": 1}], "Edges": [{"PointA": 1, "PointB": 2, "Count": 1}]}`)
+	defer cleanup()
+
+	if _, err := createPuzzleByFilename(&filename); err == nil {
+		t.Error("Expected error for edge on undeclared point")
+	}
+}
+
+func TestSolveFileTextFormat(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "puzzle*.otd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	if _, err := tmpfile.Write([]byte("1-2\n2-3\n3-1\n")); err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+
+	filename := tmpfile.Name()
+	puzzle, err := createPuzzleByFilename(&filename)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if n := solver.GetNumberOfSolutions(puzzle); n != 6 {
+		t.Errorf("Expected 6 solutions, got %d", n)
+	}
+}
+
+func TestSolveFileCsvFormat(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "puzzle*.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	if _, err := tmpfile.Write([]byte("source,target\n1,2\n2,3\n3,1\n")); err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+
+	filename := tmpfile.Name()
+	puzzle, err := createPuzzleByFilename(&filename)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if n := solver.GetNumberOfSolutions(puzzle); n != 6 {
+		t.Errorf("Expected 6 solutions, got %d", n)
+	}
+}
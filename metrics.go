@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the request duration
+// histogram, the usual Prometheus ones.
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type requestKey struct {
+	route  string
+	method string
+	code   int
+}
+
+type histogram struct {
+	counts []int64 // per bucket, not cumulative; the last one is +Inf
+	sum    float64
+	count  int64
+}
+
+func (this *histogram) observe(v float64) {
+	k := sort.SearchFloat64s(latencyBuckets, v)
+	if this.counts == nil {
+		this.counts = make([]int64, len(latencyBuckets)+1)
+	}
+	this.counts[k]++
+	this.sum += v
+	this.count++
+}
+
+// serverMetrics counts the requests served and the searches run, for
+// GET /metrics.
+type serverMetrics struct {
+	mu        sync.Mutex
+	requests  map[requestKey]int64
+	latencies map[string]*histogram
+	// searches are the progress of the searches in flight, whose nodes
+	// and solutions are added to the totals once they finish.
+	searches map[*solver.Progress]struct{}
+	// rejected counts the requests turned down by the limits, by reason.
+	rejected  map[string]int64
+	solves    int64
+	nodes     int64
+	solutions int64
+}
+
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{
+		requests:  map[requestKey]int64{},
+		latencies: map[string]*histogram{},
+		searches:  map[*solver.Progress]struct{}{},
+		rejected:  map[string]int64{},
+	}
+}
+
+func (this *serverMetrics) reject(reason string) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.rejected[reason]++
+}
+
+// observe records a request served by route, the pattern it matched.
+func (this *serverMetrics) observe(route, method string, code int, d time.Duration) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	this.requests[requestKey{route, method, code}]++
+	h, ok := this.latencies[route]
+	if !ok {
+		h = &histogram{}
+		this.latencies[route] = h
+	}
+	h.observe(d.Seconds())
+}
+
+// search counts a search reporting to progress as in flight until the
+// returned function is called.
+func (this *serverMetrics) search(progress *solver.Progress) (done func()) {
+	this.mu.Lock()
+	this.searches[progress] = struct{}{}
+	this.mu.Unlock()
+	return func() {
+		this.mu.Lock()
+		defer this.mu.Unlock()
+		delete(this.searches, progress)
+		this.solves++
+		this.nodes += progress.Nodes.Load()
+		this.solutions += progress.Solutions.Load()
+	}
+}
+
+// statusRecorder keeps the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (this *statusRecorder) WriteHeader(status int) {
+	if this.status == 0 {
+		this.status = status
+	}
+	this.ResponseWriter.WriteHeader(status)
+}
+
+func (this *statusRecorder) Write(b []byte) (int, error) {
+	if this.status == 0 {
+		this.status = http.StatusOK
+	}
+	return this.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController flush the streams through it.
+func (this *statusRecorder) Unwrap() http.ResponseWriter {
+	return this.ResponseWriter
+}
+
+// instrument serves a request with the mux, recording it by the pattern it
+// matched, so that /puzzle/solve/{filename} is one route whatever the
+// puzzle.
+func (this *Server) instrument(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w}
+	this.mux.ServeHTTP(rec, r)
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	route := r.Pattern
+	if _, path, ok := strings.Cut(route, " "); ok {
+		route = path
+	}
+	if route == "" {
+		// Rejected by the mux, as with a method not allowed.
+		route = "unmatched"
+	}
+	this.metrics.observe(route, r.Method, rec.status, time.Since(start))
+}
+
+// health is the body of /healthz and /readyz.
+type health struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// healthz tells the server is up, it answers as long as it can serve.
+func (this *Server) healthz(w http.ResponseWriter, r *http.Request) error {
+	return writeJson(w, http.StatusOK, health{Status: "ok"})
+}
+
+// readyz tells whether the server can take requests: not shutting down
+// and with its puzzles at hand.
+func (this *Server) readyz(w http.ResponseWriter, r *http.Request) error {
+	err := this.ready()
+	if err != nil {
+		return writeJson(w, http.StatusServiceUnavailable, health{Status: "unavailable", Error: err.Error()})
+	}
+	return writeJson(w, http.StatusOK, health{Status: "ok"})
+}
+
+func (this *Server) ready() error {
+	if this.closing.Load() {
+		return fmt.Errorf("shutting down")
+	}
+	if _, err := os.Stat(this.Index); err != nil {
+		return fmt.Errorf("puzzle index: %v", err)
+	}
+	if info, err := os.Stat(this.PuzzleDir); err != nil || !info.IsDir() {
+		return fmt.Errorf("puzzle directory %s is missing", this.PuzzleDir)
+	}
+	return nil
+}
+
+// serveMetrics writes the metrics in the Prometheus text format.
+func (this *Server) serveMetrics(w http.ResponseWriter, r *http.Request) error {
+	var b strings.Builder
+	this.metrics.write(&b)
+
+	hits, misses := this.cache.hits.Load(), this.cache.misses.Load()
+	ratio := 0.0
+	if hits+misses > 0 {
+		ratio = float64(hits) / float64(hits+misses)
+	}
+	this.cache.mu.Lock()
+	entries := this.cache.lru.Len()
+	this.cache.mu.Unlock()
+	writeMetric(&b, "onetdraw_cache_hits_total", "counter", "Searches answered from the cache.", hits)
+	writeMetric(&b, "onetdraw_cache_misses_total", "counter", "Searches not found in the cache.", misses)
+	writeMetric(&b, "onetdraw_cache_hit_ratio", "gauge", "Share of the lookups answered from the cache.", ratio)
+	writeMetric(&b, "onetdraw_cache_entries", "gauge", "Puzzles whose results are cached in memory.", entries)
+	writeMetric(&b, "onetdraw_solve_slots_in_use", "gauge", "Searches holding one of the solve slots.", len(this.slots))
+	writeMetric(&b, "onetdraw_solve_slots", "gauge", "Searches allowed to run at once.", cap(this.slots))
+	writeMetric(&b, "go_goroutines", "gauge", "Number of goroutines that currently exist.", runtime.NumGoroutine())
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (this *serverMetrics) write(b *strings.Builder) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	keys := make([]requestKey, 0, len(this.requests))
+	for k := range this.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.code < b.code
+	})
+	writeHeader(b, "onetdraw_http_requests_total", "counter", "Requests served, by route, method and status code.")
+	for _, k := range keys {
+		fmt.Fprintf(b, "onetdraw_http_requests_total{route=%s,method=%s,code=\"%d\"} %d\n",
+			quoteLabel(k.route), quoteLabel(k.method), k.code, this.requests[k])
+	}
+
+	routes := make([]string, 0, len(this.latencies))
+	for route := range this.latencies {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	writeHeader(b, "onetdraw_http_request_duration_seconds", "histogram", "Time taken to serve requests, by route.")
+	for _, route := range routes {
+		h := this.latencies[route]
+		var cumulative int64
+		for k, le := range latencyBuckets {
+			cumulative += h.counts[k]
+			fmt.Fprintf(b, "onetdraw_http_request_duration_seconds_bucket{route=%s,le=\"%s\"} %d\n",
+				quoteLabel(route), strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(b, "onetdraw_http_request_duration_seconds_bucket{route=%s,le=\"+Inf\"} %d\n", quoteLabel(route), h.count)
+		fmt.Fprintf(b, "onetdraw_http_request_duration_seconds_sum{route=%s} %s\n", quoteLabel(route), strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(b, "onetdraw_http_request_duration_seconds_count{route=%s} %d\n", quoteLabel(route), h.count)
+	}
+
+	// The searches in flight count with what they explored so far.
+	nodes, solutions := this.nodes, this.solutions
+	for progress := range this.searches {
+		nodes += progress.Nodes.Load()
+		solutions += progress.Solutions.Load()
+	}
+	writeMetric(b, "onetdraw_solves_in_flight", "gauge", "Searches running.", len(this.searches))
+	writeMetric(b, "onetdraw_solves_total", "counter", "Searches finished, complete or not.", this.solves)
+	writeMetric(b, "onetdraw_search_nodes_total", "counter", "Nodes explored by the searches.", nodes)
+	writeMetric(b, "onetdraw_solutions_found_total", "counter", "Solutions found by the searches.", solutions)
+
+	reasons := make([]string, 0, len(this.rejected))
+	for reason := range this.rejected {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	writeHeader(b, "onetdraw_rejected_total", "counter", "Requests and searches turned down by the limits, by reason.")
+	for _, reason := range reasons {
+		fmt.Fprintf(b, "onetdraw_rejected_total{reason=%s} %d\n", quoteLabel(reason), this.rejected[reason])
+	}
+}
+
+func writeHeader(b *strings.Builder, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeMetric(b *strings.Builder, name, kind, help string, value interface{}) {
+	writeHeader(b, name, kind, help)
+	fmt.Fprintf(b, "%s %v\n", name, value)
+}
+
+// quoteLabel quotes a label value, escaping what the text format asks for.
+func quoteLabel(v string) string {
+	v = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+	return `"` + v + `"`
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHealth(t *testing.T) {
+	s := newTestServer(t, ServerOptions{Workers: 1})
+	if w := request(t, s, "GET", "/healthz", ""); w.Code != 200 {
+		t.Errorf("Expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	if w := request(t, s, "GET", "/readyz", ""); w.Code != 200 {
+		t.Errorf("Expected ready, got %d %s", w.Code, w.Body.String())
+	}
+
+	s.PuzzleDir = t.TempDir() + "/none"
+	assertJsonError(t, request(t, s, "GET", "/readyz", ""), 503, "")
+	s.PuzzleDir = "puzzles"
+	s.Close()
+	w := request(t, s, "GET", "/readyz", "")
+	if w.Code != 503 || !strings.Contains(w.Body.String(), "shutting down") {
+		t.Errorf("Expected not ready once closing, got %d %s", w.Code, w.Body.String())
+	}
+	if w := request(t, s, "GET", "/healthz", ""); w.Code != 200 {
+		t.Errorf("Expected to stay healthy, got %d", w.Code)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	s := newTestServer(t, ServerOptions{Workers: 1})
+	request(t, s, "GET", "/puzzle/solve/house", "")
+	request(t, s, "GET", "/puzzle/solve/house.json", "")
+	request(t, s, "GET", "/puzzle/solve/nope", "")
+	request(t, s, "POST", "/healthz", "")
+
+	w := request(t, s, "GET", "/metrics", "")
+	if w.Code != 200 || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("Expected the text format, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	body := w.Body.String()
+	for _, line := range []string{
+		`onetdraw_http_requests_total{route="/puzzle/solve/{filename}",method="GET",code="200"} 2`,
+		`onetdraw_http_requests_total{route="/puzzle/solve/{filename}",method="GET",code="404"} 1`,
+		`onetdraw_http_requests_total{route="/",method="POST",code="404"} 1`,
+		`onetdraw_http_request_duration_seconds_bucket{route="/puzzle/solve/{filename}",le="+Inf"} 3`,
+		`onetdraw_http_request_duration_seconds_count{route="/puzzle/solve/{filename}"} 3`,
+		"# TYPE onetdraw_http_request_duration_seconds histogram",
+		"onetdraw_solves_in_flight 0",
+		"onetdraw_solves_total 1",
+		"onetdraw_solutions_found_total 88",
+		"onetdraw_cache_hits_total 1",
+		"onetdraw_cache_misses_total 1",
+		"onetdraw_cache_hit_ratio 0.5",
+		"onetdraw_cache_entries 1",
+		"# TYPE go_goroutines gauge",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected %q in\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, "onetdraw_search_nodes_total 0\n") {
+		t.Error("Expected the nodes explored to be counted")
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+// revisionsDir is the directory of PuzzleDir keeping every revision of
+// every puzzle, as revisionsDir/{name}/{version}.json.
+const revisionsDir = ".revisions"
+
+var puzzleNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+const (
+	defaultUserPuzzleDir = "user_puzzles"
+	// userIndex is the index of the puzzles of UserPuzzleDir, kept in it.
+	userIndex = "puzzles.json"
+)
+
+var (
+	errPuzzleExists   = errors.New("puzzle already exists")
+	errPuzzleNotFound = errors.New("puzzle not found")
+	errPuzzleBundled  = errors.New("puzzle is bundled")
+)
+
+// puzzleStore keeps the puzzle files of a directory, their earlier
+// revisions and the index listing them in sync. Puzzles are named by their
+// file name without the .json extension.
+type puzzleStore struct {
+	dir   string
+	index string
+	// bundled, when set, holds puzzles served along with those of dir but
+	// never changed, whose names dir cannot take.
+	bundled *puzzleStore
+	mu      *sync.Mutex
+}
+
+// puzzleRevision is one saved version of a puzzle.
+type puzzleRevision struct {
+	Version int       `json:"version"`
+	Saved   time.Time `json:"saved"`
+}
+
+// store returns the puzzles added through the API, in UserPuzzleDir, on top
+// of the bundled ones of PuzzleDir.
+func (this *Server) store() puzzleStore {
+	bundled := &puzzleStore{dir: this.PuzzleDir, index: this.Index, mu: &this.storeMu}
+	return puzzleStore{dir: this.UserPuzzleDir, index: filepath.Join(this.UserPuzzleDir, userIndex), bundled: bundled, mu: &this.storeMu}
+}
+
+func validatePuzzleName(name string) error {
+	if !puzzleNameRe.MatchString(name) {
+		return fmt.Errorf("invalid puzzle name %q, use letters, digits, - and _", name)
+	}
+	return nil
+}
+
+func (this puzzleStore) file(name string) string {
+	return filepath.Join(this.dir, name+".json")
+}
+
+func (this puzzleStore) revisionFile(name string, version int) string {
+	return filepath.Join(this.dir, revisionsDir, name, strconv.Itoa(version)+".json")
+}
+
+// list reads the index, after the one of the bundled puzzles. The index
+// of dir is only written with its first puzzle.
+func (this puzzleStore) list() ([]puzzleEntry, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.bundled == nil {
+		return this.readIndex()
+	}
+	puzzles, err := this.bundled.readIndex()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(this.index); errors.Is(err, fs.ErrNotExist) {
+		return puzzles, nil
+	}
+	own, err := this.readIndex()
+	return append(puzzles, own...), err
+}
+
+// isBundled tells whether name is a bundled puzzle. The caller holds
+// this.mu.
+func (this puzzleStore) isBundled(name string) bool {
+	if this.bundled == nil {
+		return false
+	}
+	_, err := os.Stat(this.bundled.file(name))
+	return err == nil
+}
+
+func (this puzzleStore) readIndex() ([]puzzleEntry, error) {
+	data, err := os.ReadFile(this.index)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %v", filepath.Base(this.index), err)
+	}
+	var puzzles []puzzleEntry
+	if err := json.Unmarshal(data, &puzzles); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", filepath.Base(this.index), err)
+	}
+	return puzzles, nil
+}
+
+func (this puzzleStore) writeIndex(puzzles []puzzleEntry) error {
+	data, err := json.MarshalIndent(puzzles, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(this.index, append(data, '\n'))
+}
+
+// revisions lists the saved versions of a puzzle, oldest first. A puzzle
+// added by hand to the directory has none until it is first updated.
+func (this puzzleStore) revisions(name string) ([]puzzleRevision, error) {
+	entries, err := os.ReadDir(filepath.Join(this.dir, revisionsDir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var revisions []puzzleRevision
+	for _, e := range entries {
+		version, err := strconv.Atoi(strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, puzzleRevision{Version: version, Saved: info.ModTime().UTC()})
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Version < revisions[j].Version })
+	return revisions, nil
+}
+
+// versions lists the versions of a current puzzle, the hand-added file
+// counting as version 1.
+func (this puzzleStore) versions(name string) ([]puzzleRevision, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	revisions, err := this.currentVersions(name)
+	if err == errPuzzleNotFound && this.bundled != nil {
+		return this.bundled.currentVersions(name)
+	}
+	return revisions, err
+}
+
+func (this puzzleStore) currentVersions(name string) ([]puzzleRevision, error) {
+	info, err := os.Stat(this.file(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errPuzzleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := this.revisions(name)
+	if err != nil || len(revisions) > 0 {
+		return revisions, err
+	}
+	return []puzzleRevision{{Version: 1, Saved: info.ModTime().UTC()}}, nil
+}
+
+// get reads a version of a puzzle, the current one when version is 0.
+// Earlier versions can still be read once the puzzle is removed.
+func (this puzzleStore) get(name string, version int) (*solver.Puzzle, error) {
+	puzzle, err := this.getOwn(name, version)
+	if err == errPuzzleNotFound && this.bundled != nil {
+		return this.bundled.get(name, version)
+	}
+	return puzzle, err
+}
+
+func (this puzzleStore) getOwn(name string, version int) (*solver.Puzzle, error) {
+	path := this.file(name)
+	if version > 0 {
+		this.mu.Lock()
+		revisions, err := this.revisions(name)
+		this.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		// A hand-added file is version 1 until it is first updated.
+		if len(revisions) > 0 || version > 1 {
+			path = this.revisionFile(name, version)
+		}
+	}
+	puzzle, err := loadPuzzle(path, "", nil)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errPuzzleNotFound
+	}
+	return puzzle, err
+}
+
+// save writes a new version of a puzzle, creating it when create is set
+// or updating it otherwise, and lists it in the index under title, keeping
+// the current title when it is empty. It returns the version written.
+// Bundled puzzles are neither created again nor updated.
+func (this puzzleStore) save(name, title string, puzzle *solver.Puzzle, create bool) (int, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.isBundled(name) {
+		if create {
+			return 0, errPuzzleExists
+		}
+		return 0, errPuzzleBundled
+	}
+	if err := os.MkdirAll(this.dir, 0755); err != nil {
+		return 0, err
+	}
+	current, err := os.ReadFile(this.file(name))
+	exists := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+	if create && exists {
+		return 0, errPuzzleExists
+	}
+	if !create && !exists {
+		return 0, errPuzzleNotFound
+	}
+
+	revisions, err := this.revisions(name)
+	if err != nil {
+		return 0, err
+	}
+	version := 1
+	if len(revisions) > 0 {
+		version = revisions[len(revisions)-1].Version + 1
+	} else if exists {
+		// Keep the hand-added file as the first revision.
+		if err := this.writeRevision(name, 1, current); err != nil {
+			return 0, err
+		}
+		version = 2
+	}
+
+	var data strings.Builder
+	if err := (solver.JsonCodec{}).Encode(&data, puzzle); err != nil {
+		return 0, err
+	}
+	if err := this.writeRevision(name, version, []byte(data.String())); err != nil {
+		return 0, err
+	}
+	if err := writeFileAtomic(this.file(name), []byte(data.String())); err != nil {
+		return 0, err
+	}
+	return version, this.updateIndex(name, title)
+}
+
+func (this puzzleStore) writeRevision(name string, version int, data []byte) error {
+	path := this.revisionFile(name, version)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// updateIndex lists the puzzle in the index, or renames it when title is
+// set and it is already listed.
+func (this puzzleStore) updateIndex(name, title string) error {
+	var puzzles []puzzleEntry
+	if _, err := os.Stat(this.index); err == nil {
+		if puzzles, err = this.readIndex(); err != nil {
+			return err
+		}
+	}
+	file := name + ".json"
+	for k := range puzzles {
+		if puzzles[k].JsonFile == file {
+			if title == "" || title == puzzles[k].Name {
+				return nil
+			}
+			puzzles[k].Name = title
+			return this.writeIndex(puzzles)
+		}
+	}
+	if title == "" {
+		title = name
+	}
+	return this.writeIndex(append(puzzles, puzzleEntry{Name: title, JsonFile: file}))
+}
+
+// remove deletes the current version of a puzzle and its index entry. Its
+// revisions are kept, so that it can be restored. Bundled puzzles are not
+// removed.
+func (this puzzleStore) remove(name string) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+	if this.isBundled(name) {
+		return errPuzzleBundled
+	}
+	if err := os.Remove(this.file(name)); errors.Is(err, fs.ErrNotExist) {
+		return errPuzzleNotFound
+	} else if err != nil {
+		return err
+	}
+	puzzles, err := this.readIndex()
+	if err != nil {
+		return err
+	}
+	kept := puzzles[:0]
+	for _, p := range puzzles {
+		if p.JsonFile != name+".json" {
+			kept = append(kept, p)
+		}
+	}
+	return this.writeIndex(kept)
+}
+
+// writeFileAtomic writes data to a temporary file renamed over path, so
+// that readers never see a partly written file.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+const testPuzzleToken = "secret"
+
+// newStoreServer returns a server over a copy of the house puzzle, listed
+// in its own index, taking user puzzles in an empty directory.
+func newStoreServer(t *testing.T) *Server {
+	dir := t.TempDir()
+	data, err := os.ReadFile("puzzles/house.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "house.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	index := filepath.Join(dir, "puzzles.json")
+	if err := os.WriteFile(index, []byte(`[{"Name": "House", "JsonFile": "house.json"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t, ServerOptions{Workers: 1, PuzzleToken: testPuzzleToken})
+	s.PuzzleDir, s.Index, s.UserPuzzleDir = dir, index, filepath.Join(t.TempDir(), "user")
+	return s
+}
+
+// addByHand copies the house puzzle to the user puzzles as name, the way
+// it would be added without the API.
+func addByHand(t *testing.T, s *Server, name string) {
+	data, err := os.ReadFile("puzzles/house.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(s.UserPuzzleDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(s.UserPuzzleDir, name+".json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// change sends a request changing puzzles, with the puzzle token.
+func change(t *testing.T, s *Server, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+testPuzzleToken)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func decodeIndex(t *testing.T, s *Server) []puzzleEntry {
+	t.Helper()
+	var puzzles []puzzleEntry
+	if err := json.Unmarshal(request(t, s, "GET", "/puzzles", "").Body.Bytes(), &puzzles); err != nil {
+		t.Fatal(err)
+	}
+	return puzzles
+}
+
+func countEdges(t *testing.T, s *Server, target string) int {
+	t.Helper()
+	w := request(t, s, "GET", target, "")
+	if w.Code != 200 {
+		t.Fatalf("GET %s: %d %s", target, w.Code, w.Body.String())
+	}
+	var puzzle solver.Puzzle
+	if err := json.Unmarshal(w.Body.Bytes(), &puzzle); err != nil {
+		t.Fatal(err)
+	}
+	return len(puzzle.Edges)
+}
+
+func TestPuzzleCreate(t *testing.T) {
+	s := newStoreServer(t)
+	w := change(t, s, "POST", "/puzzles", `{"name": "tri", "title": "Triangle", "puzzle": "1-2-3-1"}`)
+	if w.Code != 201 || w.Header().Get("Location") != "/puzzles/tri" {
+		t.Fatalf("Expected the puzzle created, got %d %s", w.Code, w.Body.String())
+	}
+	if puzzles := decodeIndex(t, s); len(puzzles) != 2 || puzzles[1] != (puzzleEntry{Name: "Triangle", JsonFile: "tri.json"}) {
+		t.Errorf("Expected the puzzle added to the index, got %v", puzzles)
+	}
+	if _, err := os.Stat(filepath.Join(s.UserPuzzleDir, "tri.json")); err != nil {
+		t.Errorf("Expected the puzzle written to the user puzzles: %v", err)
+	}
+	if n := countEdges(t, s, "/puzzles/tri"); n != 3 {
+		t.Errorf("Expected 3 edges, got %d", n)
+	}
+	if w := request(t, s, "GET", "/puzzle/solve/tri", ""); w.Code != 200 {
+		t.Errorf("Expected the new puzzle solvable, got %d", w.Code)
+	}
+
+	assertJsonError(t, change(t, s, "POST", "/puzzles", `{"name": "tri", "puzzle": "1-2"}`), 409, "already exists")
+	assertJsonError(t, change(t, s, "POST", "/puzzles", `{"name": "house", "puzzle": "1-2"}`), 409, "already exists")
+	assertJsonError(t, change(t, s, "POST", "/puzzles", `{"name": "../x", "puzzle": "1-2"}`), 400, "invalid puzzle name")
+	assertJsonError(t, change(t, s, "POST", "/puzzles", `{"name": "bad", "puzzle": {"Edges": [{"PointA": 1, "PointB": 1, "Count": 1}]}}`), 422, "invalid puzzle")
+	assertJsonError(t, change(t, s, "POST", "/puzzles", `{"name": "empty"}`), 400, "no puzzle")
+}
+
+func TestPuzzleVersions(t *testing.T) {
+	s := newStoreServer(t)
+	addByHand(t, s, "shed")
+	assertJsonError(t, change(t, s, "PUT", "/puzzles/nope", `{"puzzle": "1-2"}`), 404, "not found")
+
+	w := change(t, s, "PUT", "/puzzles/shed", `{"puzzle": {"Edges": [{"PointA": 1, "PointB": 2, "Count": 1}]}}`)
+	var saved puzzleSaved
+	if err := json.Unmarshal(w.Body.Bytes(), &saved); err != nil || saved.Version != 2 {
+		t.Fatalf("Expected version 2, got %d %s", w.Code, w.Body.String())
+	}
+	change(t, s, "PUT", "/puzzles/shed", `{"title": "Shed", "puzzle": "1-2-3"}`)
+
+	var versions []puzzleRevision
+	json.Unmarshal(request(t, s, "GET", "/puzzles/shed/versions", "").Body.Bytes(), &versions)
+	if len(versions) != 3 || versions[2].Version != 3 {
+		t.Errorf("Expected 3 versions, got %v", versions)
+	}
+	for target, edges := range map[string]int{
+		"/puzzles/shed":           2,
+		"/puzzles/shed?version=1": 8,
+		"/puzzles/shed?version=2": 1,
+		"/puzzles/house":          8,
+	} {
+		if n := countEdges(t, s, target); n != edges {
+			t.Errorf("Expected %d edges at %s, got %d", edges, target, n)
+		}
+	}
+	assertJsonError(t, request(t, s, "GET", "/puzzles/shed?version=9", ""), 404, "version 9 of puzzle shed not found")
+	if puzzles := decodeIndex(t, s); len(puzzles) != 2 || puzzles[1].Name != "Shed" {
+		t.Errorf("Expected the puzzle listed under its title, got %v", puzzles)
+	}
+
+	// Bundled puzzles have their file as only version.
+	json.Unmarshal(request(t, s, "GET", "/puzzles/house/versions", "").Body.Bytes(), &versions)
+	if len(versions) != 1 || versions[0].Version != 1 {
+		t.Errorf("Expected 1 version of the bundled puzzle, got %v", versions)
+	}
+	assertJsonError(t, change(t, s, "PUT", "/puzzles/house", `{"puzzle": "1-2"}`), 403, "cannot be changed")
+}
+
+func TestPuzzleDelete(t *testing.T) {
+	s := newStoreServer(t)
+	change(t, s, "POST", "/puzzles", `{"name": "tri", "puzzle": "1-2-3-1"}`)
+	change(t, s, "PUT", "/puzzles/tri", `{"puzzle": "1-2"}`)
+	if w := change(t, s, "DELETE", "/puzzles/tri", ""); w.Code != 204 {
+		t.Fatalf("Expected 204, got %d %s", w.Code, w.Body.String())
+	}
+	if puzzles := decodeIndex(t, s); len(puzzles) != 1 || puzzles[0].Name != "House" {
+		t.Errorf("Expected the puzzle removed from the index, got %v", puzzles)
+	}
+	assertJsonError(t, request(t, s, "GET", "/puzzles/tri", ""), 404, "puzzle tri not found")
+	assertJsonError(t, change(t, s, "DELETE", "/puzzles/tri", ""), 404, "not found")
+	if n := countEdges(t, s, "/puzzles/tri?version=1"); n != 3 {
+		t.Errorf("Expected the revisions kept, got %d edges in version 1", n)
+	}
+
+	// Created again, it carries on from the last revision.
+	w := change(t, s, "POST", "/puzzles", `{"name": "tri", "puzzle": "1-2"}`)
+	var saved puzzleSaved
+	if json.Unmarshal(w.Body.Bytes(), &saved); saved.Version != 3 {
+		t.Errorf("Expected version 3, got %s", w.Body.String())
+	}
+
+	assertJsonError(t, change(t, s, "DELETE", "/puzzles/house", ""), 403, "cannot be changed")
+	if n := countEdges(t, s, "/puzzles/house"); n != 8 {
+		t.Errorf("Expected the bundled puzzle kept, got %d edges", n)
+	}
+}
+
+func TestPuzzleChangesNeedTheToken(t *testing.T) {
+	s := newStoreServer(t)
+	body := `{"name": "tri", "puzzle": "1-2-3-1"}`
+	w := request(t, s, "POST", "/puzzles", body)
+	assertJsonError(t, w, 401, "needs the puzzle token")
+	if w.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Errorf("Expected a bearer challenge, got %q", w.Header().Get("WWW-Authenticate"))
+	}
+	r := httptest.NewRequest("DELETE", "/puzzles/house", nil)
+	r.Header.Set("Authorization", "Bearer wrong")
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	assertJsonError(t, w, 401, "needs the puzzle token")
+
+	// Without a token, puzzles cannot be changed at all.
+	s.puzzleToken = ""
+	assertJsonError(t, change(t, s, "POST", "/puzzles", body), 403, "turned off")
+	if puzzles := decodeIndex(t, s); len(puzzles) != 1 {
+		t.Errorf("Expected no puzzle added, got %v", puzzles)
+	}
+}
+
+func TestPuzzleChangesRateLimited(t *testing.T) {
+	s := newStoreServer(t)
+	s.limiter = newRateLimiter(1, 1)
+	change(t, s, "POST", "/puzzles", `{"name": "tri", "puzzle": "1-2-3-1"}`)
+	assertJsonError(t, change(t, s, "DELETE", "/puzzles/tri", ""), 429, "too many requests")
+}
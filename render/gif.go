@@ -0,0 +1,139 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"time"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+// maxStepColors bounds the palette entries used for the solution gradient,
+// leaving room for the theme's fixed colours in the 256 colour GIF palette.
+const maxStepColors = 240
+
+// stepWidth is the width of the strokes of the solution.
+const stepWidth = 4.0
+
+// defaultMaxGIFPixels is the MaxPixels of DefaultGIFOptions, 64 MB of frames.
+const defaultMaxGIFPixels = 1 << 26
+
+// ErrGIFTooLarge is returned when the frames of an animation would hold
+// more pixels than allowed.
+var ErrGIFTooLarge = errors.New("animation too large")
+
+type GIFOptions struct {
+	Options
+	// Delay is the time each step stays on screen.
+	Delay time.Duration
+	// Hold is how long the finished drawing stays before the loop restarts.
+	Hold time.Duration
+	// MaxPixels bounds the pixels held by the frames, which are all in
+	// memory until the GIF is written. Zero is no limit.
+	MaxPixels int
+}
+
+func DefaultGIFOptions() GIFOptions {
+	return GIFOptions{Options: DefaultOptions(), Delay: 700 * time.Millisecond, Hold: 2 * time.Second, MaxPixels: defaultMaxGIFPixels}
+}
+
+const (
+	colorBackground uint8 = iota
+	colorEdge
+	colorPoint
+	colorLabel
+	colorStart
+	colorEnd
+	colorFirstStep
+)
+
+// GIF writes an animated GIF that draws the solution stroke by stroke: the
+// first frame shows the bare puzzle and every following frame adds one step,
+// covering only the rectangle the step changes.
+func GIF(w io.Writer, puzzle *solver.Puzzle, opts GIFOptions) error {
+	if len(opts.Solution) < 2 {
+		return errors.New("an animation needs a solution with at least one step")
+	}
+	s, err := newScene(puzzle, opts.Solution, opts.Width, opts.Height)
+	if err != nil {
+		return err
+	}
+
+	theme := opts.Theme
+	palette := color.Palette{theme.Background, theme.Edge, theme.Point, theme.Label, theme.Start, theme.End}
+	stepColors := len(s.steps)
+	if stepColors > maxStepColors {
+		stepColors = maxStepColors
+	}
+	for k := 0; k < stepColors; k++ {
+		palette = append(palette, theme.StepColor(k, stepColors))
+	}
+	stepColor := func(k int) uint8 {
+		return colorFirstStep + uint8(k*stepColors/len(s.steps))
+	}
+
+	bounds := image.Rect(0, 0, int(opts.Width+0.5), int(opts.Height+0.5))
+	strokes := make([]segment, len(s.steps))
+	changed := make([]image.Rectangle, len(s.steps))
+	// The strokes and the first frame cover the whole image.
+	pixels := 2 * bounds.Dx() * bounds.Dy()
+	for k, step := range s.steps {
+		strokes[k] = step.lane.shortened(pointRadius)
+		changed[k] = strokes[k].bounds(stepWidth/2 + 1).Intersect(bounds)
+		if changed[k].Empty() {
+			// A frame needs a pixel, even when the stroke is out of sight.
+			changed[k] = image.Rect(0, 0, 1, 1)
+		}
+		pixels += changed[k].Dx() * changed[k].Dy()
+	}
+	if opts.MaxPixels > 0 && pixels > opts.MaxPixels {
+		return fmt.Errorf("%w: %d steps of %dx%d need %d pixels, more than %d", ErrGIFTooLarge, len(s.steps), bounds.Dx(), bounds.Dy(), pixels, opts.MaxPixels)
+	}
+
+	drawn := canvas{image.NewPaletted(bounds, palette)}
+	for _, e := range s.edges {
+		for _, lane := range e.lanes {
+			drawn.line(lane, 2, colorEdge)
+			if e.directed {
+				drawn.arrow(lane, colorEdge)
+			}
+		}
+	}
+
+	anim := &gif.GIF{Config: image.Config{ColorModel: palette, Width: bounds.Dx(), Height: bounds.Dy()}}
+	// addFrame shows the strokes drawn so far within r, the points on top.
+	// The frames before are left in place, so r is all a step changes.
+	addFrame := func(r image.Rectangle, delay time.Duration) {
+		frame := canvas{image.NewPaletted(r, palette)}
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			copy(frame.img.Pix[frame.img.PixOffset(r.Min.X, y):], drawn.img.Pix[drawn.img.PixOffset(r.Min.X, y):drawn.img.PixOffset(r.Max.X, y)])
+		}
+		for _, p := range s.points {
+			if p.start {
+				frame.ring(p.x, p.y, pointRadius*2, 2, colorStart)
+			}
+			if p.end {
+				frame.ring(p.x, p.y, pointRadius*2+3, 2, colorEnd)
+			}
+			frame.disk(p.x, p.y, pointRadius, colorPoint)
+			frame.text(p.x+pointRadius, p.y-pointRadius-12, p.label, 2, colorLabel)
+		}
+		anim.Image = append(anim.Image, frame.img)
+		anim.Delay = append(anim.Delay, int(delay/(10*time.Millisecond)))
+	}
+
+	addFrame(bounds, opts.Delay)
+	for k := range s.steps {
+		drawn.line(strokes[k], stepWidth, stepColor(k))
+		if k == len(s.steps)-1 {
+			addFrame(changed[k], opts.Hold)
+		} else {
+			addFrame(changed[k], opts.Delay)
+		}
+	}
+	return gif.EncodeAll(w, anim)
+}
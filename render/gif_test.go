@@ -0,0 +1,93 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+	"time"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+func TestGIFAddsOneFramePerStep(t *testing.T) {
+	opts := DefaultGIFOptions()
+	opts.Width, opts.Height = 120, 80
+	opts.Delay = 250 * time.Millisecond
+	opts.Hold = time.Second
+	opts.Solution = solver.Solution{3, 1, 2, 3, 2}
+
+	var b bytes.Buffer
+	if err := GIF(&b, triangle(), opts); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	anim, err := gif.DecodeAll(&b)
+	if err != nil {
+		t.Fatalf("Invalid GIF: %v", err)
+	}
+
+	if len(anim.Image) != 5 {
+		t.Fatalf("Expected 5 frames, got %d", len(anim.Image))
+	}
+	if anim.Delay[0] != 25 || anim.Delay[4] != 100 {
+		t.Errorf("Expected delays 25 and 100, got %v", anim.Delay)
+	}
+	if size := anim.Image[0].Bounds().Size(); size.X != 120 || size.Y != 80 {
+		t.Errorf("Expected 120x80 frames, got %v", size)
+	}
+
+	// Each frame adds pixels of a new step colour on top of the previous one.
+	first, last := anim.Image[0].ColorModel().Convert(DefaultTheme.SolutionFirst), anim.Image[0].ColorModel().Convert(DefaultTheme.SolutionLast)
+	if countColor(anim.Image[0], first) != 0 {
+		t.Error("Expected the first frame to show the bare puzzle")
+	}
+	if countColor(anim.Image[1], first) == 0 {
+		t.Error("Expected the second frame to show the first step")
+	}
+	if countColor(anim.Image[4], last) == 0 {
+		t.Error("Expected the last frame to show the last step")
+	}
+	for k, img := range anim.Image[1:] {
+		if size := img.Bounds().Size(); size.X*size.Y >= 120*80 {
+			t.Errorf("Expected frame %d to cover the step alone, got %v", k+1, img.Bounds())
+		}
+	}
+}
+
+func TestGIFMaxPixels(t *testing.T) {
+	opts := DefaultGIFOptions()
+	opts.Width, opts.Height = 120, 80
+	opts.Solution = solver.Solution{3, 1, 2, 3, 2}
+	opts.MaxPixels = 2 * 120 * 80
+	var b bytes.Buffer
+	if err := GIF(&b, triangle(), opts); !errors.Is(err, ErrGIFTooLarge) {
+		t.Errorf("Expected the animation to be too large, got %v", err)
+	}
+}
+
+func TestGIFRequiresASolution(t *testing.T) {
+	var b bytes.Buffer
+	if err := GIF(&b, triangle(), DefaultGIFOptions()); err == nil {
+		t.Error("Expected error without a solution")
+	}
+}
+
+func countColor(img interface {
+	Bounds() image.Rectangle
+	At(x, y int) color.Color
+}, c color.Color) int {
+	n := 0
+	r, g, b, _ := c.RGBA()
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r2, g2, b2, _ := img.At(x, y).RGBA()
+			if r == r2 && g == g2 && b == b2 {
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,108 @@
+package render
+
+import (
+	"image"
+	"math"
+)
+
+// canvas draws on a paletted image with the few primitives the raster
+// renderer needs, using only the standard image packages.
+type canvas struct {
+	img *image.Paletted
+}
+
+func (this canvas) set(x, y int, c uint8) {
+	if image.Pt(x, y).In(this.img.Rect) {
+		this.img.SetColorIndex(x, y, c)
+	}
+}
+
+func (this canvas) disk(cx, cy, r float64, c uint8) {
+	for y := int(math.Floor(cy - r)); y <= int(math.Ceil(cy+r)); y++ {
+		for x := int(math.Floor(cx - r)); x <= int(math.Ceil(cx+r)); x++ {
+			dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+			if dx*dx+dy*dy <= r*r {
+				this.set(x, y, c)
+			}
+		}
+	}
+}
+
+func (this canvas) ring(cx, cy, r, width float64, c uint8) {
+	inner, outer := r-width/2, r+width/2
+	for y := int(math.Floor(cy - outer)); y <= int(math.Ceil(cy+outer)); y++ {
+		for x := int(math.Floor(cx - outer)); x <= int(math.Ceil(cx+outer)); x++ {
+			dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+			d := dx*dx + dy*dy
+			if d >= inner*inner && d <= outer*outer {
+				this.set(x, y, c)
+			}
+		}
+	}
+}
+
+// line strokes seg by stamping disks of the given width along it.
+func (this canvas) line(seg segment, width float64, c uint8) {
+	length := math.Hypot(seg.x2-seg.x1, seg.y2-seg.y1)
+	steps := int(math.Ceil(length))
+	for i := 0; i <= steps; i++ {
+		t := 0.0
+		if steps > 0 {
+			t = float64(i) / float64(steps)
+		}
+		this.disk(seg.x1+(seg.x2-seg.x1)*t, seg.y1+(seg.y2-seg.y1)*t, width/2, c)
+	}
+}
+
+// arrow fills a triangle at the middle of lane pointing along it.
+func (this canvas) arrow(lane segment, c uint8) {
+	x, y := lane.mid()
+	angle := math.Atan2(lane.y2-lane.y1, lane.x2-lane.x1)
+	cos, sin := math.Cos(angle), math.Sin(angle)
+	h := arrowSize / 2
+	for v := -h; v <= h; v += 0.5 {
+		// v runs from the base to the tip; the half width shrinks linearly.
+		half := h * (h - v) / (2 * h)
+		for u := -half; u <= half; u += 0.5 {
+			this.set(int(x+cos*v-sin*u), int(y+sin*v+cos*u), c)
+		}
+	}
+}
+
+// text draws s with a 3x5 pixel font scaled by scale. Only digits and '-'
+// have glyphs; other characters leave a blank cell.
+func (this canvas) text(x, y float64, s string, scale int, c uint8) {
+	for k, r := range s {
+		glyph, ok := glyphs[r]
+		if !ok {
+			continue
+		}
+		ox := int(x) + k*4*scale
+		for row, bits := range glyph {
+			for col := 0; col < 3; col++ {
+				if bits&(4>>col) == 0 {
+					continue
+				}
+				for dy := 0; dy < scale; dy++ {
+					for dx := 0; dx < scale; dx++ {
+						this.set(ox+col*scale+dx, int(y)+row*scale+dy, c)
+					}
+				}
+			}
+		}
+	}
+}
+
+var glyphs = map[rune][5]uint8{
+	'0': {7, 5, 5, 5, 7},
+	'1': {2, 6, 2, 2, 7},
+	'2': {7, 1, 7, 4, 7},
+	'3': {7, 1, 7, 1, 7},
+	'4': {5, 5, 7, 1, 1},
+	'5': {7, 4, 7, 1, 7},
+	'6': {7, 4, 7, 5, 7},
+	'7': {7, 1, 1, 1, 1},
+	'8': {7, 5, 7, 5, 7},
+	'9': {7, 5, 7, 1, 7},
+	'-': {0, 0, 7, 0, 0},
+}
@@ -0,0 +1,149 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+
+	"github.com/wricardo/OneTDraw-Solver/layout"
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+const (
+	laneSpacing = 6.0
+	pointRadius = 5.0
+)
+
+type segment struct {
+	x1, y1, x2, y2 float64
+}
+
+func (this segment) mid() (float64, float64) {
+	return (this.x1 + this.x2) / 2, (this.y1 + this.y2) / 2
+}
+
+func (this segment) reversed() segment {
+	return segment{this.x2, this.y2, this.x1, this.y1}
+}
+
+// bounds returns the pixels a stroke of the segment can cover, pad being
+// at least half its width.
+func (this segment) bounds(pad float64) image.Rectangle {
+	return image.Rect(
+		int(math.Floor(math.Min(this.x1, this.x2)-pad)), int(math.Floor(math.Min(this.y1, this.y2)-pad)),
+		int(math.Ceil(math.Max(this.x1, this.x2)+pad))+1, int(math.Ceil(math.Max(this.y1, this.y2)+pad))+1)
+}
+
+// shortened trims both ends by d, so strokes stop at the point circles.
+func (this segment) shortened(d float64) segment {
+	length := math.Hypot(this.x2-this.x1, this.y2-this.y1)
+	if length <= 2*d {
+		return this
+	}
+	ux, uy := (this.x2-this.x1)/length, (this.y2-this.y1)/length
+	return segment{this.x1 + ux*d, this.y1 + uy*d, this.x2 - ux*d, this.y2 - uy*d}
+}
+
+type scenePoint struct {
+	x, y       float64
+	label      string
+	start, end bool
+}
+
+// sceneEdge is one puzzle edge drawn as Count parallel lanes, each running
+// from PointA to PointB unless the edge is directed, in which case lanes run
+// in the edge's direction.
+type sceneEdge struct {
+	lanes    []segment
+	from     uint16
+	directed bool
+}
+
+type sceneStep struct {
+	number int
+	lane   segment
+}
+
+// scene is the geometry shared by the SVG and raster renderers.
+type scene struct {
+	width, height float64
+	points        []scenePoint
+	edges         []sceneEdge
+	steps         []sceneStep
+}
+
+func newScene(puzzle *solver.Puzzle, solution solver.Solution, width, height float64) (*scene, error) {
+	l := layout.Compute(puzzle, width, height)
+	s := &scene{width: width, height: height}
+
+	for _, n := range l.Nodes {
+		if n.Hidden {
+			continue
+		}
+		sp := scenePoint{x: n.X, y: n.Y, label: strconv.Itoa(n.ID)}
+		if p := puzzle.GetPoint(n.ID); p != nil {
+			if p.Label != "" {
+				sp.label = p.Label
+			}
+			sp.start, sp.end = p.Start, p.End
+		}
+		s.points = append(s.points, sp)
+	}
+
+	for _, e := range puzzle.Edges {
+		from, to := e.PointA, e.PointB
+		if e.Direction.Unidirectional && e.Direction.From == e.PointB {
+			from, to = to, from
+		}
+		x1, y1, _ := l.Position(int(from))
+		x2, y2, _ := l.Position(int(to))
+		s.edges = append(s.edges, sceneEdge{lanes: lanes(segment{x1, y1, x2, y2}, int(e.Count)), from: from, directed: e.Direction.Unidirectional})
+	}
+
+	used := make([]int, len(puzzle.Edges))
+	for k := 1; k < len(solution); k++ {
+		a, b := solution[k-1], solution[k]
+		lane, err := s.takeLane(puzzle, used, a, b)
+		if err != nil {
+			return nil, fmt.Errorf("solution step %d: %w", k, err)
+		}
+		s.steps = append(s.steps, sceneStep{number: k, lane: lane})
+	}
+	return s, nil
+}
+
+// takeLane returns the next unused lane of the edge between a and b,
+// oriented from a to b.
+func (this *scene) takeLane(puzzle *solver.Puzzle, used []int, a, b uint16) (segment, error) {
+	for k, e := range puzzle.Edges {
+		if !(e.PointA == a && e.PointB == b) && !(e.PointA == b && e.PointB == a) {
+			continue
+		}
+		if used[k] >= int(e.Count) {
+			continue
+		}
+		lane := this.edges[k].lanes[used[k]]
+		used[k]++
+		if this.edges[k].from != a {
+			lane = lane.reversed()
+		}
+		return lane, nil
+	}
+	return segment{}, fmt.Errorf("no edge left between %d and %d", a, b)
+}
+
+// lanes splits seg into count parallel segments laneSpacing apart.
+func lanes(seg segment, count int) []segment {
+	length := math.Hypot(seg.x2-seg.x1, seg.y2-seg.y1)
+	nx, ny := 0.0, 0.0
+	if length > 0 {
+		nx, ny = -(seg.y2-seg.y1)/length, (seg.x2-seg.x1)/length
+	}
+	r := make([]segment, count)
+	for i := 0; i < count; i++ {
+		offset := (float64(i) - float64(count-1)/2) * laneSpacing
+		r[i] = segment{seg.x1 + nx*offset, seg.y1 + ny*offset, seg.x2 + nx*offset, seg.y2 + ny*offset}
+	}
+	return r
+}
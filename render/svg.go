@@ -0,0 +1,116 @@
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+const arrowSize = 8.0
+
+type Options struct {
+	Width  float64
+	Height float64
+	Theme  Theme
+	// Solution, when not empty, is drawn over the puzzle as a numbered path.
+	Solution solver.Solution
+}
+
+func DefaultOptions() Options {
+	return Options{Width: 500, Height: 500, Theme: DefaultTheme}
+}
+
+// SVG writes the puzzle as an SVG document. Edges are drawn as one stroke per
+// required visit, directed edges get an arrowhead at their middle and the
+// optional solution is overlaid step by step, coloured from the theme's
+// SolutionFirst to SolutionLast.
+func SVG(w io.Writer, puzzle *solver.Puzzle, opts Options) error {
+	s, err := newScene(puzzle, opts.Solution, opts.Width, opts.Height)
+	if err != nil {
+		return err
+	}
+	theme := opts.Theme
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %s %s">`+"\n",
+		num(s.width), num(s.height), num(s.width), num(s.height))
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", hex(theme.Background))
+
+	b.WriteString(`<g class="edges">` + "\n")
+	for _, e := range s.edges {
+		for _, lane := range e.lanes {
+			fmt.Fprintf(&b, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="2"/>`+"\n",
+				num(lane.x1), num(lane.y1), num(lane.x2), num(lane.y2), hex(theme.Edge))
+			if e.directed {
+				writeArrow(&b, lane, hex(theme.Edge))
+			}
+		}
+	}
+	b.WriteString("</g>\n")
+
+	if len(s.steps) > 0 {
+		b.WriteString(`<g class="solution">` + "\n")
+		for k, step := range s.steps {
+			c := hex(theme.StepColor(k, len(s.steps)))
+			lane := step.lane.shortened(pointRadius)
+			fmt.Fprintf(&b, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="4" stroke-linecap="round"/>`+"\n",
+				num(lane.x1), num(lane.y1), num(lane.x2), num(lane.y2), c)
+		}
+		for k, step := range s.steps {
+			x, y := step.lane.mid()
+			fmt.Fprintf(&b, `<circle cx="%s" cy="%s" r="8" fill="%s"/>`+"\n", num(x), num(y), hex(theme.StepColor(k, len(s.steps))))
+			fmt.Fprintf(&b, `<text x="%s" y="%s" font-family="sans-serif" font-size="10" text-anchor="middle" dominant-baseline="central" fill="%s">%d</text>`+"\n",
+				num(x), num(y), hex(theme.Background), step.number)
+		}
+		b.WriteString("</g>\n")
+	}
+
+	b.WriteString(`<g class="points">` + "\n")
+	for _, p := range s.points {
+		if p.start {
+			fmt.Fprintf(&b, `<circle cx="%s" cy="%s" r="%s" fill="none" stroke="%s" stroke-width="2"/>`+"\n", num(p.x), num(p.y), num(pointRadius*2), hex(theme.Start))
+		}
+		if p.end {
+			fmt.Fprintf(&b, `<circle cx="%s" cy="%s" r="%s" fill="none" stroke="%s" stroke-width="2"/>`+"\n", num(p.x), num(p.y), num(pointRadius*2+3), hex(theme.End))
+		}
+		fmt.Fprintf(&b, `<circle cx="%s" cy="%s" r="%s" fill="%s"/>`+"\n", num(p.x), num(p.y), num(pointRadius), hex(theme.Point))
+		fmt.Fprintf(&b, `<text x="%s" y="%s" font-family="sans-serif" font-size="16" fill="%s">`, num(p.x+pointRadius), num(p.y-pointRadius-2), hex(theme.Label))
+		xml.EscapeText(&b, []byte(p.label))
+		b.WriteString("</text>\n")
+	}
+	b.WriteString("</g>\n</svg>\n")
+
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// writeArrow draws a filled arrowhead at the middle of lane, pointing from
+// its start to its end.
+func writeArrow(b *bytes.Buffer, lane segment, fill string) {
+	x, y := lane.mid()
+	angle := math.Atan2(lane.y2-lane.y1, lane.x2-lane.x1)
+	tipX, tipY := x+math.Cos(angle)*arrowSize/2, y+math.Sin(angle)*arrowSize/2
+	baseX, baseY := x-math.Cos(angle)*arrowSize/2, y-math.Sin(angle)*arrowSize/2
+	nx, ny := -math.Sin(angle)*arrowSize/2, math.Cos(angle)*arrowSize/2
+	fmt.Fprintf(b, `<polygon points="%s,%s %s,%s %s,%s" fill="%s"/>`+"\n",
+		num(tipX), num(tipY), num(baseX+nx), num(baseY+ny), num(baseX-nx), num(baseY-ny), fill)
+}
+
+// num formats a coordinate with at most two decimals.
+func num(f float64) string {
+	s := fmt.Sprintf("%.2f", f)
+	for s[len(s)-1] == '0' {
+		s = s[:len(s)-1]
+	}
+	if s[len(s)-1] == '.' {
+		s = s[:len(s)-1]
+	}
+	if s == "-0" {
+		s = "0"
+	}
+	return s
+}
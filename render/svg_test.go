@@ -0,0 +1,97 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+func triangle() *solver.Puzzle {
+	return solver.NewPuzzle([]solver.Edge{
+		{PointA: 1, PointB: 2, Count: 1, Direction: solver.Direction{From: 1, To: 2, Unidirectional: true}},
+		{PointA: 2, PointB: 3, Count: 2},
+		{PointA: 3, PointB: 1, Count: 1},
+	})
+}
+
+func TestSVGDrawsOneStrokePerCount(t *testing.T) {
+	var b bytes.Buffer
+	if err := SVG(&b, triangle(), DefaultOptions()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	out := b.String()
+
+	if !strings.HasPrefix(out, "<svg ") || !strings.HasSuffix(out, "</svg>\n") {
+		t.Errorf("Expected an SVG document, got %s", out)
+	}
+	if n := strings.Count(out, "<line "); n != 4 {
+		t.Errorf("Expected 4 edge strokes, got %d", n)
+	}
+	if n := strings.Count(out, "<polygon "); n != 1 {
+		t.Errorf("Expected 1 arrowhead, got %d", n)
+	}
+	if strings.Contains(out, `class="solution"`) {
+		t.Error("Expected no solution overlay")
+	}
+}
+
+func TestSVGOverlaysSolution(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Solution = solver.Solution{3, 1, 2, 3, 2}
+
+	var b bytes.Buffer
+	if err := SVG(&b, triangle(), opts); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	out := b.String()
+
+	if n := strings.Count(out, "<line "); n != 4+4 {
+		t.Errorf("Expected 8 strokes, got %d", n)
+	}
+	for _, label := range []string{">1</text>", ">4</text>"} {
+		if !strings.Contains(out, label) {
+			t.Errorf("Expected step label %s", label)
+		}
+	}
+	first, last := hex(DefaultTheme.SolutionFirst), hex(DefaultTheme.SolutionLast)
+	if !strings.Contains(out, `stroke="`+first+`" stroke-width="4"`) || !strings.Contains(out, `stroke="`+last+`" stroke-width="4"`) {
+		t.Errorf("Expected steps graded from %s to %s", first, last)
+	}
+}
+
+func TestSVGRejectsImpossibleSolution(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Solution = solver.Solution{1, 2, 1}
+
+	var b bytes.Buffer
+	if err := SVG(&b, triangle(), opts); err == nil {
+		t.Error("Expected error for a solution that reuses edge 1-2")
+	}
+}
+
+func TestSVGEscapesLabels(t *testing.T) {
+	p := triangle()
+	p.Points = []solver.Point{{ID: 1, Label: "<a&b>", Start: true}, {ID: 2, End: true}, {ID: 3}}
+
+	var b bytes.Buffer
+	if err := SVG(&b, p, DefaultOptions()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(b.String(), "&lt;a&amp;b&gt;") {
+		t.Error("Expected escaped label")
+	}
+	if !strings.Contains(b.String(), hex(DefaultTheme.Start)) || !strings.Contains(b.String(), hex(DefaultTheme.End)) {
+		t.Error("Expected start and end markers")
+	}
+}
+
+func TestNum(t *testing.T) {
+	tests := map[float64]string{0: "0", 100: "100", 62.5: "62.5", 1.005: "1", 1.256: "1.26", -0.001: "0"}
+	for in, expected := range tests {
+		if got := num(in); got != expected {
+			t.Errorf("num(%v) = %s, want %s", in, got, expected)
+		}
+	}
+}
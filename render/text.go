@@ -0,0 +1,232 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/wricardo/OneTDraw-Solver/layout"
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+// TextOptions controls Text. Columns and Rows are the size of the drawing in
+// characters.
+type TextOptions struct {
+	Columns int
+	Rows    int
+	// Solution is drawn over the puzzle; it may be the start of a solution,
+	// as in a game being played. Its last point is marked as current.
+	Solution solver.Solution
+	// ASCII restricts the drawing to ASCII characters.
+	ASCII bool
+	// Color highlights drawn edges and the current point with ANSI escapes.
+	Color bool
+}
+
+func DefaultTextOptions() TextOptions {
+	return TextOptions{Columns: 60, Rows: 20}
+}
+
+type charset struct {
+	horizontal, vertical, rising, falling, undrawn rune
+	arrows                                         [8]rune // east, then counterclockwise
+}
+
+var unicodeCharset = charset{'─', '│', '╱', '╲', '·', [8]rune{'→', '↗', '↑', '↖', '←', '↙', '↓', '↘'}}
+var asciiCharset = charset{'-', '|', '/', '\\', '.', [8]rune{'>', '/', '^', '\\', '<', '/', 'v', '\\'}}
+
+const (
+	styleNone = iota
+	styleDrawn
+	styleCurrent
+)
+
+type cell struct {
+	r     rune
+	style int
+}
+
+// Text draws the puzzle as character art, placing points from their
+// coordinates or levels like the other renderers. Edges not drawn yet are
+// dotted and drawn ones solid; directed edges get an arrow at their middle
+// and edges to draw more than once the number of times left.
+func Text(w io.Writer, puzzle *solver.Puzzle, opts TextOptions) error {
+	if opts.Columns < 8 || opts.Rows < 4 {
+		return fmt.Errorf("text drawing of %dx%d is too small", opts.Columns, opts.Rows)
+	}
+	used, err := usedCounts(puzzle, opts.Solution)
+	if err != nil {
+		return err
+	}
+	g := unicodeCharset
+	if opts.ASCII {
+		g = asciiCharset
+	}
+
+	// Characters are about twice as high as wide.
+	l := layout.Compute(puzzle, float64(opts.Columns-1), float64(opts.Rows-1)*2)
+	grid := make([][]cell, opts.Rows)
+	for k := range grid {
+		grid[k] = make([]cell, opts.Columns)
+		for i := range grid[k] {
+			grid[k][i].r = ' '
+		}
+	}
+	position := func(id uint16) (int, int) {
+		x, y, _ := l.Position(int(id))
+		return int(math.Round(x)), int(math.Round(y / 2))
+	}
+	set := func(col, row int, r rune, style int) {
+		if row >= 0 && row < len(grid) && col >= 0 && col < len(grid[row]) {
+			grid[row][col] = cell{r, style}
+		}
+	}
+
+	// Undrawn edges first, so drawn ones cover them where they cross.
+	for pass := 0; pass < 2; pass++ {
+		for k, e := range puzzle.Edges {
+			left := int(e.Count) - used[k]
+			if (pass == 0) != (left > 0) {
+				continue
+			}
+			from, to := e.PointA, e.PointB
+			if e.Direction.Unidirectional && e.Direction.From == e.PointB {
+				from, to = to, from
+			}
+			c1, r1 := position(from)
+			c2, r2 := position(to)
+			r, style := lineGlyph(g, c2-c1, r2-r1), styleDrawn
+			if left > 0 {
+				r, style = g.undrawn, styleNone
+			}
+			n := max(abs(c2-c1), abs(r2-r1))
+			for i := 1; i < n; i++ {
+				t := float64(i) / float64(n)
+				set(int(math.Round(float64(c1)+t*float64(c2-c1))), int(math.Round(float64(r1)+t*float64(r2-r1))), r, style)
+			}
+
+			midCol, midRow := (c1+c2)/2, (r1+r2)/2
+			if e.Direction.Unidirectional {
+				set(midCol, midRow, arrowGlyph(g, c2-c1, r2-r1), style)
+				midCol++
+			}
+			if left > 1 {
+				set(midCol, midRow, rune('0'+min(left, 9)), style)
+			}
+		}
+	}
+
+	current := -1
+	if len(opts.Solution) > 0 {
+		current = int(opts.Solution[len(opts.Solution)-1])
+	}
+	for _, n := range l.Nodes {
+		if n.Hidden {
+			continue
+		}
+		label := strconv.Itoa(n.ID)
+		style := styleNone
+		if n.ID == current {
+			label, style = "["+label+"]", styleCurrent
+		}
+		col, row := int(math.Round(n.X))-len(label)/2, int(math.Round(n.Y/2))
+		col = max(0, min(col, opts.Columns-len(label)))
+		for i, r := range label {
+			set(col+i, row, r, style)
+		}
+	}
+
+	var b strings.Builder
+	for _, row := range grid {
+		line := strings.TrimRight(renderRow(row, opts.Color), " ")
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+func renderRow(row []cell, color bool) string {
+	var b strings.Builder
+	style := styleNone
+	for _, c := range row {
+		if color && c.style != style {
+			switch c.style {
+			case styleNone:
+				b.WriteString("\x1b[0m")
+			case styleDrawn:
+				b.WriteString("\x1b[0;1;36m")
+			case styleCurrent:
+				b.WriteString("\x1b[0;7m")
+			}
+			style = c.style
+		}
+		b.WriteRune(c.r)
+	}
+	if style != styleNone {
+		b.WriteString("\x1b[0m")
+	}
+	return b.String()
+}
+
+// lineGlyph picks the character closest to the slope of a line going dc
+// columns and dr rows, counting rows twice as high as columns.
+func lineGlyph(g charset, dc, dr int) rune {
+	angle := math.Abs(math.Atan2(float64(-2*dr), float64(dc))) * 180 / math.Pi
+	switch {
+	case angle < 22.5 || angle > 157.5:
+		return g.horizontal
+	case angle > 67.5 && angle < 112.5:
+		return g.vertical
+	case (dc > 0) == (dr < 0):
+		return g.rising
+	default:
+		return g.falling
+	}
+}
+
+func arrowGlyph(g charset, dc, dr int) rune {
+	angle := math.Atan2(float64(-2*dr), float64(dc)) * 180 / math.Pi
+	if angle < 0 {
+		angle += 360
+	}
+	return g.arrows[int(math.Round(angle/45))%8]
+}
+
+// usedCounts returns how many times the solution draws every edge of the
+// puzzle, preferring directed edges when an edge is repeated, like the
+// solver.
+func usedCounts(puzzle *solver.Puzzle, solution solver.Solution) ([]int, error) {
+	used := make([]int, len(puzzle.Edges))
+	for k := 1; k < len(solution); k++ {
+		a, b := solution[k-1], solution[k]
+		found := -1
+		for i, e := range puzzle.Edges {
+			if !(e.PointA == a && e.PointB == b || e.PointA == b && e.PointB == a) || used[i] >= int(e.Count) {
+				continue
+			}
+			if !e.Direction.Unidirectional {
+				if found < 0 {
+					found = i
+				}
+			} else if e.Direction.From == a {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			return nil, fmt.Errorf("solution step %d: no edge left between %d and %d", k, a, b)
+		}
+		used[found]++
+	}
+	return used, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
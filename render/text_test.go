@@ -0,0 +1,55 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+func TestTextDrawsPointsAndEdges(t *testing.T) {
+	var b bytes.Buffer
+	if err := Text(&b, triangle(), TextOptions{Columns: 30, Rows: 10, ASCII: true}); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, label := range []string{"1", "2", "3"} {
+		if !strings.Contains(out, label) {
+			t.Errorf("Expected point %s in\n%s", label, out)
+		}
+	}
+	if !strings.Contains(out, ".") {
+		t.Errorf("Expected undrawn edges to be dotted in\n%s", out)
+	}
+	if strings.ContainsAny(out, "\x1b─") {
+		t.Errorf("Expected plain ASCII, got\n%s", out)
+	}
+}
+
+func TestTextDrawsPath(t *testing.T) {
+	p := solver.NewPuzzle([]solver.Edge{
+		{PointA: 1, PointB: 2, Count: 1},
+		{PointA: 2, PointB: 3, Count: 2},
+	})
+	p.Points = []solver.Point{{ID: 1, Level: 1}, {ID: 2, Level: 1}, {ID: 3, Level: 1}}
+
+	var b bytes.Buffer
+	if err := Text(&b, p, TextOptions{Columns: 40, Rows: 5, Solution: solver.Solution{1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	if !strings.Contains(out, "1────") {
+		t.Errorf("Expected 1-2 drawn solid in\n%s", out)
+	}
+	if !strings.Contains(out, "[2]") {
+		t.Errorf("Expected the current point marked in\n%s", out)
+	}
+	if !strings.Contains(out, "·2·") {
+		t.Errorf("Expected the count left on 2-3 in\n%s", out)
+	}
+
+	if err := Text(&b, p, TextOptions{Columns: 40, Rows: 5, Solution: solver.Solution{1, 3}}); err == nil {
+		t.Error("Expected error for a path using a missing edge")
+	}
+}
@@ -0,0 +1,78 @@
+// Package render draws puzzles and their solutions, using the positions
+// computed by the layout package.
+package render
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// Theme holds the colours shared by every renderer. Solution steps are
+// graded from SolutionFirst to SolutionLast.
+type Theme struct {
+	Background    color.RGBA
+	Edge          color.RGBA
+	Point         color.RGBA
+	Label         color.RGBA
+	Start         color.RGBA
+	End           color.RGBA
+	SolutionFirst color.RGBA
+	SolutionLast  color.RGBA
+}
+
+var DefaultTheme = Theme{
+	Background:    color.RGBA{0xff, 0xff, 0xff, 0xff},
+	Edge:          color.RGBA{0x99, 0x99, 0x99, 0xff},
+	Point:         color.RGBA{0x00, 0x00, 0x00, 0xff},
+	Label:         color.RGBA{0x00, 0x00, 0xff, 0xff},
+	Start:         color.RGBA{0x2e, 0xcc, 0x40, 0xff},
+	End:           color.RGBA{0xff, 0x41, 0x36, 0xff},
+	SolutionFirst: color.RGBA{0x00, 0xcc, 0x00, 0xff},
+	SolutionLast:  color.RGBA{0xff, 0x00, 0x33, 0xff},
+}
+
+var DarkTheme = Theme{
+	Background:    color.RGBA{0x1e, 0x1e, 0x1e, 0xff},
+	Edge:          color.RGBA{0x66, 0x66, 0x66, 0xff},
+	Point:         color.RGBA{0xee, 0xee, 0xee, 0xff},
+	Label:         color.RGBA{0x7f, 0xdb, 0xff, 0xff},
+	Start:         color.RGBA{0x2e, 0xcc, 0x40, 0xff},
+	End:           color.RGBA{0xff, 0x41, 0x36, 0xff},
+	SolutionFirst: color.RGBA{0xff, 0xdc, 0x00, 0xff},
+	SolutionLast:  color.RGBA{0xf0, 0x12, 0xbe, 0xff},
+}
+
+var themes = map[string]Theme{
+	"light": DefaultTheme,
+	"dark":  DarkTheme,
+}
+
+// GetTheme returns the theme registered under name.
+func GetTheme(name string) (Theme, error) {
+	theme, ok := themes[name]
+	if !ok {
+		return Theme{}, fmt.Errorf("unknown theme %q", name)
+	}
+	return theme, nil
+}
+
+// StepColor returns the colour of step (0-based) out of total steps.
+func (this Theme) StepColor(step, total int) color.RGBA {
+	if total <= 1 {
+		return this.SolutionFirst
+	}
+	t := float64(step) / float64(total-1)
+	mix := func(a, b uint8) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*t + 0.5)
+	}
+	return color.RGBA{
+		mix(this.SolutionFirst.R, this.SolutionLast.R),
+		mix(this.SolutionFirst.G, this.SolutionLast.G),
+		mix(this.SolutionFirst.B, this.SolutionLast.B),
+		0xff,
+	}
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,27 @@
+package render
+
+import (
+	"testing"
+)
+
+func TestStepColorGradesBetweenEnds(t *testing.T) {
+	theme := DefaultTheme
+	if c := theme.StepColor(0, 5); c != theme.SolutionFirst {
+		t.Errorf("Expected first step to be %v, got %v", theme.SolutionFirst, c)
+	}
+	if c := theme.StepColor(4, 5); c != theme.SolutionLast {
+		t.Errorf("Expected last step to be %v, got %v", theme.SolutionLast, c)
+	}
+	if c := theme.StepColor(0, 1); c != theme.SolutionFirst {
+		t.Errorf("Expected single step to be %v, got %v", theme.SolutionFirst, c)
+	}
+}
+
+func TestGetTheme(t *testing.T) {
+	if theme, err := GetTheme("dark"); err != nil || theme != DarkTheme {
+		t.Errorf("Expected dark theme, got %v, %v", theme, err)
+	}
+	if _, err := GetTheme("neon"); err == nil {
+		t.Error("Expected error for unknown theme")
+	}
+}
@@ -1,82 +1,457 @@
 package main
 
 import (
+	"bytes"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/stretchr/goweb"
-	"github.com/stretchr/goweb/context"
+	"github.com/wricardo/OneTDraw-Solver/layout"
+	"github.com/wricardo/OneTDraw-Solver/render"
 	"github.com/wricardo/OneTDraw-Solver/solver"
 )
 
-func validateFilename(filename string) error {
-	cleaned := filepath.Clean(filename)
-	if strings.Contains(cleaned, "..") || strings.HasPrefix(cleaned, "/") {
-		return fmt.Errorf("invalid filename: %s", filename)
-	}
-	if !strings.HasSuffix(cleaned, ".json") {
-		return fmt.Errorf("only .json files allowed")
-	}
-	return nil
+const (
+	defaultLayoutSize = 500.0
+	// maxRenderSize bounds the width and height of a layout or an image,
+	// whose memory grows with their product.
+	maxRenderSize = 4096.0
+	// maxLayoutPoints bounds the points of a puzzle laid out for a request,
+	// the force-directed layout taking time in their square.
+	maxLayoutPoints = 500
+)
+
+// puzzleEntry is one puzzle of the index, JsonFile being the name of its
+// file in the puzzle directory, extension included, as in "house.json".
+type puzzleEntry struct {
+	Name     string
+	JsonFile string
 }
 
-func mapRoutes() {
-	goweb.Map("/", func(c context.Context) error {
-		return goweb.Respond.WithRedirect(c, "/static/ui2.html")
-	})
+// renderRequest is the query of the routes that lay out or draw a puzzle.
+// Width and Height default to the size of the canvas in ui2.html.
+type renderRequest struct {
+	Width    float64
+	Height   float64
+	Theme    string
+	Solution *int
+	// Delay is the time each step of a GIF is shown, given in milliseconds.
+	Delay *time.Duration
+}
 
-	goweb.Map("/puzzles", func(c context.Context) error {
-		jsonBlob, err := os.ReadFile("puzzles.json")
-		if err != nil {
-			return goweb.Respond.With(c, 404, []byte("ERROR: Could not read puzzles.json"))
+func parseRenderRequest(q url.Values) (renderRequest, error) {
+	req := renderRequest{Width: defaultLayoutSize, Height: defaultLayoutSize, Theme: q.Get("theme")}
+	for _, size := range []struct {
+		name  string
+		value *float64
+	}{{"width", &req.Width}, {"height", &req.Height}} {
+		param := q.Get(size.name)
+		if param == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(param, 64)
+		// Written so that NaN fails too.
+		if err != nil || !(v > 0) {
+			return req, fmt.Errorf("invalid %s %q", size.name, param)
 		}
-		type puzzle struct {
-			Name     string
-			JsonFile string
+		if v > maxRenderSize {
+			return req, fmt.Errorf("%s %s is larger than %g", size.name, param, maxRenderSize)
 		}
-		var puzzles []puzzle
-		err = json.Unmarshal(jsonBlob, &puzzles)
+		*size.value = v
+	}
+	if param := q.Get("solution"); param != "" {
+		index, err := strconv.Atoi(param)
 		if err != nil {
-			return goweb.Respond.With(c, 404, []byte("ERROR: Invalid JSON"))
+			return req, fmt.Errorf("invalid solution index %q", param)
 		}
-		return goweb.API.RespondWithData(c, puzzles)
-	})
-	goweb.Map("/puzzle/solve/{filename}", func(c context.Context) error {
-		filenameParam := c.PathParams().Get("filename").Str()
-		if err := validateFilename(filenameParam + ".json"); err != nil {
-			return goweb.Respond.With(c, 400, []byte("ERROR: "+err.Error()))
+		req.Solution = &index
+	}
+	if param := q.Get("delay"); param != "" {
+		ms, err := strconv.Atoi(param)
+		if err != nil || ms < 0 {
+			return req, fmt.Errorf("invalid delay %q", param)
 		}
-		filename := fmt.Sprintf("puzzles/%s.json", filenameParam)
-		puzzle, err := createPuzzleByFilename(&filename)
+		delay := time.Duration(ms) * time.Millisecond
+		req.Delay = &delay
+	}
+	return req, nil
+}
+
+// options turns the request into render options, calling solve for the
+// solutions when one of them is asked for. Its errors are returned as
+// they are, the others are a 400.
+func (this renderRequest) options(solve func() (solver.Solutions, error)) (render.Options, error) {
+	opts := render.DefaultOptions()
+	opts.Width, opts.Height = this.Width, this.Height
+	if this.Theme != "" {
+		theme, err := render.GetTheme(this.Theme)
 		if err != nil {
-			return goweb.Respond.With(c, 404, []byte("ERROR: Could not load puzzle"))
+			return opts, &httpError{Status: http.StatusBadRequest, Err: err}
 		}
-		solution := solver.Solve(puzzle)
-		return goweb.API.RespondWithData(c, solution)
-	})
-	goweb.Map("/puzzle/get_points/{filename}", func(c context.Context) error {
-		filenameParam := c.PathParams().Get("filename").Str()
-		if err := validateFilename(filenameParam + ".json"); err != nil {
-			return goweb.Respond.With(c, 400, []byte("ERROR: "+err.Error()))
+		opts.Theme = theme
+	}
+	if this.Solution != nil {
+		solutions, err := solve()
+		if err != nil {
+			return opts, err
 		}
-		filename := fmt.Sprintf("puzzles/%s.json", filenameParam)
-		jsonBlob, err := os.ReadFile(filename)
+		opts.Solution, err = pickSolution(solutions, *this.Solution)
 		if err != nil {
-			return goweb.Respond.With(c, 404, []byte("ERROR: Could not read puzzle file"))
+			return opts, &httpError{Status: http.StatusBadRequest, Err: err}
 		}
-		type puzzle struct {
-			Points interface{}
-			Edges  interface{}
+	}
+	return opts, nil
+}
+
+func (this *Server) routes() {
+	this.mux.Handle("GET /{$}", http.RedirectHandler("/static/ui2.html", http.StatusFound))
+	this.mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.Dir(this.StaticDir))))
+	this.mux.Handle("GET /healthz", handlerFunc(this.healthz))
+	this.mux.Handle("GET /readyz", handlerFunc(this.readyz))
+	this.mux.Handle("GET /metrics", handlerFunc(this.serveMetrics))
+	this.mux.Handle("GET /puzzles", handlerFunc(this.listPuzzles))
+	this.mux.Handle("POST /puzzles", this.puzzleWrite(this.createPuzzle))
+	this.mux.Handle("GET /puzzles/{name}", handlerFunc(this.getPuzzle))
+	this.mux.Handle("GET /puzzles/{name}/versions", handlerFunc(this.listVersions))
+	this.mux.Handle("PUT /puzzles/{name}", this.puzzleWrite(this.updatePuzzle))
+	this.mux.Handle("DELETE /puzzles/{name}", this.puzzleWrite(this.deletePuzzle))
+	this.mux.Handle("GET /puzzle/solve/{filename}", this.rateLimited(this.solvePuzzle))
+	this.mux.Handle("POST /puzzle/analyze", this.rateLimited(this.analyzePuzzle))
+	this.mux.Handle("GET /puzzle/stream/{filename}", this.rateLimited(this.streamSolutions))
+	this.mux.Handle("GET /puzzle/get_points/{filename}", this.rateLimited(this.getPoints))
+	this.mux.Handle("GET /puzzle/svg/{filename}", this.rateLimited(this.renderSVG))
+	this.mux.Handle("GET /puzzle/gif/{filename}", this.rateLimited(this.renderGIF))
+	this.mux.Handle("POST /games", this.rateLimited(this.createGame))
+	this.mux.Handle("GET /games/{id}", handlerFunc(this.getGame))
+	this.mux.Handle("DELETE /games/{id}", handlerFunc(this.deleteGame))
+	this.mux.Handle("POST /games/{id}/moves", handlerFunc(this.playMove))
+	this.mux.Handle("POST /games/{id}/undo", handlerFunc(this.undoMove))
+	this.mux.Handle("POST /games/{id}/restart", handlerFunc(this.restartGame))
+	this.mux.Handle("GET /games/{id}/hint", this.rateLimited(this.gameHint))
+	this.mux.Handle("POST /jobs", this.rateLimited(this.createJob))
+	this.mux.Handle("GET /jobs/{id}", handlerFunc(this.getJob))
+	this.mux.Handle("DELETE /jobs/{id}", handlerFunc(this.deleteJob))
+	this.mux.Handle("/", handlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return httpErrorf(http.StatusNotFound, "%s not found", r.URL.Path)
+	}))
+}
+
+// puzzle loads the puzzle named by the filename path parameter.
+func (this *Server) puzzle(r *http.Request) (*solver.Puzzle, error) {
+	return this.loadPuzzle(r.PathValue("filename"))
+}
+
+// puzzleName is the name of a puzzle given by file, as the index lists
+// them, or by name: the file name without the .json extension.
+func puzzleName(name string) string {
+	return strings.TrimSuffix(name, ".json")
+}
+
+// loadPuzzle loads a puzzle of UserPuzzleDir or PuzzleDir by its name or
+// its file name.
+func (this *Server) loadPuzzle(name string) (*solver.Puzzle, error) {
+	name = puzzleName(name)
+	if err := validatePuzzleName(name); err != nil {
+		return nil, &httpError{Status: http.StatusBadRequest, Err: err}
+	}
+	puzzle, err := this.store().get(name, 0)
+	if err == errPuzzleNotFound {
+		return nil, httpErrorf(http.StatusNotFound, "puzzle %s not found", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not load puzzle %s: %v", name, err)
+	}
+	return puzzle, nil
+}
+
+func (this *Server) listPuzzles(w http.ResponseWriter, r *http.Request) error {
+	puzzles, err := this.store().list()
+	if err != nil {
+		return err
+	}
+	return writeJson(w, http.StatusOK, puzzles)
+}
+
+func (this *Server) solvePuzzle(w http.ResponseWriter, r *http.Request) error {
+	puzzle, err := this.puzzle(r)
+	if err != nil {
+		return err
+	}
+	if checkETag(w, r, puzzle.CanonicalHash()) {
+		return nil
+	}
+	solutions, err := this.solutions(w, r, puzzle)
+	if err != nil {
+		return err
+	}
+	return writeJson(w, http.StatusOK, solutions)
+}
+
+// solver returns a function solving the puzzle of the request through the
+// cache.
+func (this *Server) solver(w http.ResponseWriter, r *http.Request, puzzle *solver.Puzzle) func() (solver.Solutions, error) {
+	return func() (solver.Solutions, error) {
+		return this.solutions(w, r, puzzle)
+	}
+}
+
+// checkLayout fails with a 400 for the puzzles with too many points to lay
+// out within a request.
+func checkLayout(puzzle *solver.Puzzle) error {
+	if n := layout.Count(puzzle); n > maxLayoutPoints {
+		return httpErrorf(http.StatusBadRequest, "puzzle has %d points, more than the %d that can be laid out", n, maxLayoutPoints)
+	}
+	return nil
+}
+
+// getPoints returns the puzzle with the coordinates of every point laid out
+// for the requested canvas size.
+func (this *Server) getPoints(w http.ResponseWriter, r *http.Request) error {
+	puzzle, err := this.puzzle(r)
+	if err != nil {
+		return err
+	}
+	req, err := parseRenderRequest(r.URL.Query())
+	if err != nil {
+		return &httpError{Status: http.StatusBadRequest, Err: err}
+	}
+	if err := checkLayout(puzzle); err != nil {
+		return err
+	}
+	// Laying out takes a slot like a search.
+	if err := this.acquireSlot(w); err != nil {
+		return err
+	}
+	defer this.slots.release()
+	positioned := layout.Compute(puzzle, req.Width, req.Height).Apply(puzzle)
+	return writeJson(w, http.StatusOK, positioned)
+}
+
+func (this *Server) renderSVG(w http.ResponseWriter, r *http.Request) error {
+	puzzle, err := this.puzzle(r)
+	if err != nil {
+		return err
+	}
+	req, err := parseRenderRequest(r.URL.Query())
+	if err != nil {
+		return &httpError{Status: http.StatusBadRequest, Err: err}
+	}
+	if err := checkLayout(puzzle); err != nil {
+		return err
+	}
+	if checkETag(w, r, imageETag(puzzle, r.URL.RawQuery)) {
+		return nil
+	}
+	opts, err := req.options(this.solver(w, r, puzzle))
+	if err != nil {
+		return err
+	}
+	// Laying out and drawing take a slot too, once the solutions are found.
+	if err := this.acquireSlot(w); err != nil {
+		return err
+	}
+	defer this.slots.release()
+	var svg bytes.Buffer
+	if err := render.SVG(&svg, puzzle, opts); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	_, err = w.Write(svg.Bytes())
+	return err
+}
+
+// renderGIF animates the requested solution, the first one by default.
+func (this *Server) renderGIF(w http.ResponseWriter, r *http.Request) error {
+	puzzle, err := this.puzzle(r)
+	if err != nil {
+		return err
+	}
+	req, err := parseRenderRequest(r.URL.Query())
+	if err != nil {
+		return &httpError{Status: http.StatusBadRequest, Err: err}
+	}
+	if req.Solution == nil {
+		req.Solution = new(int)
+	}
+	if err := checkLayout(puzzle); err != nil {
+		return err
+	}
+	if checkETag(w, r, imageETag(puzzle, r.URL.RawQuery)) {
+		return nil
+	}
+	opts := render.DefaultGIFOptions()
+	if opts.Options, err = req.options(this.solver(w, r, puzzle)); err != nil {
+		return err
+	}
+	if req.Delay != nil {
+		opts.Delay = *req.Delay
+	}
+	// Laying out and drawing take a slot too, once the solutions are found.
+	if err := this.acquireSlot(w); err != nil {
+		return err
+	}
+	defer this.slots.release()
+	var img bytes.Buffer
+	if err := render.GIF(&img, puzzle, opts); errors.Is(err, render.ErrGIFTooLarge) {
+		return &httpError{Status: http.StatusBadRequest, Err: err}
+	} else if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "image/gif")
+	_, err = w.Write(img.Bytes())
+	return err
+}
+
+// puzzleUpload is the body of POST and PUT /puzzles. Puzzle is a puzzle in
+// JSON, or a string holding it in any of the formats of the convert
+// command. Name can be left out of a PUT, and Title defaults to the name
+// when the puzzle is created and to its current title on updates.
+type puzzleUpload struct {
+	Name   string          `json:"name"`
+	Title  string          `json:"title"`
+	Puzzle json.RawMessage `json:"puzzle"`
+}
+
+// puzzleSaved answers a successful upload.
+type puzzleSaved struct {
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+}
+
+// readUpload decodes and validates the puzzle of an upload.
+func readUpload(w http.ResponseWriter, r *http.Request) (puzzleUpload, *solver.Puzzle, error) {
+	var upload puzzleUpload
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&upload); err != nil {
+		return upload, nil, httpErrorf(http.StatusBadRequest, "invalid upload: %v", err)
+	}
+	if len(upload.Puzzle) == 0 {
+		return upload, nil, httpErrorf(http.StatusBadRequest, "upload has no puzzle")
+	}
+	puzzle, err := decodeUploadedPuzzle(upload.Puzzle)
+	if err != nil {
+		return upload, nil, &httpError{Status: http.StatusUnprocessableEntity, Err: err}
+	}
+	return upload, puzzle, nil
+}
+
+func storeError(name string, err error) error {
+	switch err {
+	case errPuzzleNotFound:
+		return httpErrorf(http.StatusNotFound, "puzzle %s not found", name)
+	case errPuzzleExists:
+		return httpErrorf(http.StatusConflict, "puzzle %s already exists", name)
+	case errPuzzleBundled:
+		return httpErrorf(http.StatusForbidden, "puzzle %s comes with the server and cannot be changed, save it under another name", name)
+	}
+	return err
+}
+
+// puzzleWrite guards the routes changing puzzles, which need the
+// PuzzleToken as a bearer token and are turned off without one.
+func (this *Server) puzzleWrite(h handlerFunc) handlerFunc {
+	return this.rateLimited(func(w http.ResponseWriter, r *http.Request) error {
+		if this.puzzleToken == "" {
+			return httpErrorf(http.StatusForbidden, "changing puzzles is turned off, see serve -puzzle_token")
 		}
-		var p puzzle
-		err = json.Unmarshal(jsonBlob, &p)
-		if err != nil {
-			return goweb.Respond.With(c, 404, []byte("ERROR: Invalid JSON"))
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(this.puzzleToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			return httpErrorf(http.StatusUnauthorized, "changing puzzles needs the puzzle token")
 		}
-		return goweb.API.RespondWithData(c, p)
+		return h(w, r)
 	})
+}
+
+func (this *Server) createPuzzle(w http.ResponseWriter, r *http.Request) error {
+	upload, puzzle, err := readUpload(w, r)
+	if err != nil {
+		return err
+	}
+	if err := validatePuzzleName(upload.Name); err != nil {
+		return &httpError{Status: http.StatusBadRequest, Err: err}
+	}
+	version, err := this.store().save(upload.Name, upload.Title, puzzle, true)
+	this.cache.forget(upload.Name)
+	if err != nil {
+		return storeError(upload.Name, err)
+	}
+	w.Header().Set("Location", "/puzzles/"+upload.Name)
+	return writeJson(w, http.StatusCreated, puzzleSaved{Name: upload.Name, Version: version})
+}
+
+func (this *Server) updatePuzzle(w http.ResponseWriter, r *http.Request) error {
+	name := r.PathValue("name")
+	if err := validatePuzzleName(name); err != nil {
+		return &httpError{Status: http.StatusBadRequest, Err: err}
+	}
+	upload, puzzle, err := readUpload(w, r)
+	if err != nil {
+		return err
+	}
+	if upload.Name != "" && upload.Name != name {
+		return httpErrorf(http.StatusBadRequest, "name %q does not match the URL", upload.Name)
+	}
+	version, err := this.store().save(name, upload.Title, puzzle, false)
+	this.cache.forget(name)
+	if err != nil {
+		return storeError(name, err)
+	}
+	return writeJson(w, http.StatusOK, puzzleSaved{Name: name, Version: version})
+}
 
+// getPuzzle returns a puzzle as JSON, the version given by the version
+// query parameter or else the current one.
+func (this *Server) getPuzzle(w http.ResponseWriter, r *http.Request) error {
+	name := r.PathValue("name")
+	if err := validatePuzzleName(name); err != nil {
+		return &httpError{Status: http.StatusBadRequest, Err: err}
+	}
+	version := 0
+	if param := r.URL.Query().Get("version"); param != "" {
+		var err error
+		if version, err = strconv.Atoi(param); err != nil || version < 1 {
+			return httpErrorf(http.StatusBadRequest, "invalid version %q", param)
+		}
+	}
+	puzzle, err := this.store().get(name, version)
+	if err == errPuzzleNotFound && version > 0 {
+		return httpErrorf(http.StatusNotFound, "version %d of puzzle %s not found", version, name)
+	}
+	if err != nil {
+		return storeError(name, err)
+	}
+	return writeJson(w, http.StatusOK, puzzle)
+}
+
+func (this *Server) listVersions(w http.ResponseWriter, r *http.Request) error {
+	name := r.PathValue("name")
+	if err := validatePuzzleName(name); err != nil {
+		return &httpError{Status: http.StatusBadRequest, Err: err}
+	}
+	versions, err := this.store().versions(name)
+	if err != nil {
+		return storeError(name, err)
+	}
+	return writeJson(w, http.StatusOK, versions)
+}
+
+func (this *Server) deletePuzzle(w http.ResponseWriter, r *http.Request) error {
+	name := r.PathValue("name")
+	if err := validatePuzzleName(name); err != nil {
+		return &httpError{Status: http.StatusBadRequest, Err: err}
+	}
+	this.cache.forget(name)
+	if err := this.store().remove(name); err != nil {
+		return storeError(name, err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
 }
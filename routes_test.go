@@ -2,8 +2,13 @@ package main
 
 import (
 	"encoding/json"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
 )
 
 func TestPuzzlesJSONLoading(t *testing.T) {
@@ -190,3 +195,125 @@ func TestValidatePuzzleStructure(t *testing.T) {
 	}`
 	validatePuzzleStructure(t, validPuzzleNoPoints)
 }
+
+// newTestServer returns a server closed at the end of the test.
+func newTestServer(t *testing.T, opts ServerOptions) *Server {
+	if opts.SessionDir == "" {
+		opts.SessionDir = t.TempDir()
+	}
+	s := NewServer(opts)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func serve(t *testing.T, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	newTestServer(t, ServerOptions{Workers: 1}).ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+	return w
+}
+
+func assertJsonError(t *testing.T, w *httptest.ResponseRecorder, status int, contains string) {
+	t.Helper()
+	if w.Code != status {
+		t.Errorf("Expected status %d, got %d", status, w.Code)
+	}
+	var body errorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Expected a JSON error body, got %q", w.Body.String())
+	}
+	if !strings.Contains(body.Error, contains) {
+		t.Errorf("Expected error containing %q, got %q", contains, body.Error)
+	}
+}
+
+func TestServerListPuzzles(t *testing.T) {
+	w := serve(t, "/puzzles")
+	if w.Code != 200 {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var puzzles []puzzleEntry
+	if err := json.Unmarshal(w.Body.Bytes(), &puzzles); err != nil {
+		t.Fatal(err)
+	}
+	if len(puzzles) == 0 || puzzles[0].JsonFile == "" {
+		t.Errorf("Expected the puzzles of the index, got %v", puzzles)
+	}
+}
+
+func TestServerMissingIndex(t *testing.T) {
+	s := newTestServer(t, ServerOptions{Workers: 1})
+	s.Index = filepath.Join(t.TempDir(), "puzzles.json")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/puzzles", nil))
+	assertJsonError(t, w, 500, "could not read puzzles.json")
+}
+
+func TestServerSolve(t *testing.T) {
+	w := serve(t, "/puzzle/solve/house")
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Expected JSON, got %q", ct)
+	}
+	var solutions solver.Solutions
+	if err := json.Unmarshal(w.Body.Bytes(), &solutions); err != nil {
+		t.Fatal(err)
+	}
+	if len(solutions) != 88 {
+		t.Errorf("Expected 88 solutions, got %d", len(solutions))
+	}
+
+	assertJsonError(t, serve(t, "/puzzle/solve/nope"), 404, "puzzle nope not found")
+	assertJsonError(t, serve(t, "/puzzle/solve/..%2Fgo"), 400, "invalid puzzle name")
+
+	// The UI names puzzles by the file listed in the index.
+	if w := serve(t, "/puzzle/solve/regular_triangle.json"); w.Code != 200 {
+		t.Errorf("Expected the .json extension accepted, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestServerGetPoints(t *testing.T) {
+	w := serve(t, "/puzzle/get_points/house?width=200&height=100")
+	var puzzle solver.Puzzle
+	if err := json.Unmarshal(w.Body.Bytes(), &puzzle); err != nil {
+		t.Fatal(err)
+	}
+	if len(puzzle.Points) == 0 {
+		t.Fatal("Expected laid out points")
+	}
+	for _, p := range puzzle.Points {
+		if p.X == nil || p.Y == nil || *p.X > 200 || *p.Y > 100 {
+			t.Errorf("Expected point %d inside 200x100", p.ID)
+		}
+	}
+
+	assertJsonError(t, serve(t, "/puzzle/get_points/house?width=wide"), 400, `invalid width "wide"`)
+	assertJsonError(t, serve(t, "/puzzle/svg/house?height=100000"), 400, "height 100000 is larger than 4096")
+	assertJsonError(t, serve(t, "/puzzle/gif/house?width=NaN"), 400, `invalid width "NaN"`)
+	assertJsonError(t, serve(t, "/puzzle/svg/house?height=Inf"), 400, "height Inf is larger than 4096")
+}
+
+func TestServerImages(t *testing.T) {
+	w := serve(t, "/puzzle/svg/house?solution=0&theme=dark")
+	if w.Code != 200 || w.Header().Get("Content-Type") != "image/svg+xml" || !strings.Contains(w.Body.String(), "<svg") {
+		t.Errorf("Expected an SVG, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	assertJsonError(t, serve(t, "/puzzle/svg/house?theme=neon"), 400, "neon")
+	assertJsonError(t, serve(t, "/puzzle/svg/house?solution=1000"), 400, "out of range")
+
+	w = serve(t, "/puzzle/gif/regular_triangle?delay=100")
+	if w.Code != 200 || w.Header().Get("Content-Type") != "image/gif" || !strings.HasPrefix(w.Body.String(), "GIF8") {
+		t.Errorf("Expected a GIF, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	assertJsonError(t, serve(t, "/puzzle/gif/house?delay=-1"), 400, "invalid delay")
+}
+
+func TestServerStaticAndNotFound(t *testing.T) {
+	w := serve(t, "/")
+	if w.Code != 302 || w.Header().Get("Location") != "/static/ui2.html" {
+		t.Errorf("Expected a redirect to the UI, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if w := serve(t, "/static/ui2.html"); w.Code != 200 || !strings.Contains(w.Body.String(), "<canvas") {
+		t.Errorf("Expected the UI, got %d", w.Code)
+	}
+	assertJsonError(t, serve(t, "/nothing/here"), 404, "/nothing/here not found")
+}
@@ -0,0 +1,51 @@
+package solver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Codec converts puzzles to and from one file format.
+type Codec interface {
+	Encode(w io.Writer, puzzle *Puzzle) error
+	Decode(data []byte) (*Puzzle, error)
+}
+
+var codecs = map[string]Codec{
+	".json":    JsonCodec{},
+	".dot":     DotCodec{},
+	".gv":      DotCodec{},
+	".otd":     TextCodec{},
+	".graphml": GraphmlCodec{},
+	".csv":     CsvCodec{},
+}
+
+// CodecForFile picks the codec from the extension of filename.
+func CodecForFile(filename string) (Codec, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	codec, ok := codecs[ext]
+	if !ok {
+		return nil, fmt.Errorf("unknown puzzle format %q", ext)
+	}
+	return codec, nil
+}
+
+// JsonCodec is the format of the files in puzzles/.
+type JsonCodec struct{}
+
+func (this JsonCodec) Encode(w io.Writer, puzzle *Puzzle) error {
+	b, err := json.MarshalIndent(puzzle, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+func (this JsonCodec) Decode(data []byte) (*Puzzle, error) {
+	return newPuzzleFromJson(bytes.TrimSpace(data))
+}
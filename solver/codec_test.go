@@ -0,0 +1,52 @@
+package solver
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCodecForFile(t *testing.T) {
+	tests := map[string]Codec{
+		"puzzles/house.json": JsonCodec{},
+		"house.DOT":          DotCodec{},
+		"house.gv":           DotCodec{},
+		"house.graphml":      GraphmlCodec{},
+		"house.csv":          CsvCodec{},
+	}
+	for name, expected := range tests {
+		codec, err := CodecForFile(name)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+		if codec != expected {
+			t.Errorf("%s: expected %T, got %T", name, expected, codec)
+		}
+	}
+	if _, err := CodecForFile("house.txt"); err == nil {
+		t.Error("Expected error for unknown extension")
+	}
+}
+
+func TestJsonCodecRoundTripBundledPuzzles(t *testing.T) {
+	for name, p := range loadBundledPuzzles(t) {
+		var b bytes.Buffer
+		if err := (JsonCodec{}).Encode(&b, p); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := JsonCodec{}.Decode(b.Bytes())
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		assertSamePuzzle(t, name, p, got)
+	}
+}
+
+func TestNewPuzzleFromBytesEdgeList(t *testing.T) {
+	p, err := NewPuzzleFromBytes([]byte(` [{"PointA": 1, "PointB": 2, "Count": 2}]`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(p.Edges) != 1 || p.count != 2 {
+		t.Errorf("Expected one edge drawn twice, got %+v", p)
+	}
+}
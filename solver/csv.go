@@ -0,0 +1,222 @@
+package solver
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var csvHeader = []string{"type", "id", "point_a", "point_b", "count", "from", "level", "label", "x", "y", "start", "end", "hide"}
+
+// CsvCodec reads and writes puzzles as one CSV table. Each row is either an
+// edge (type "edge", id is its index in Edges, from is set on directed
+// edges) or a point (type "point", id is the point, followed by its
+// metadata). Unused cells are left empty.
+//
+// Plain edge lists are read too: without a type column, every row is an
+// edge with the columns point_a (or source), point_b (or target), count and
+// from, or in that order when there is no header.
+type CsvCodec struct{}
+
+func (this CsvCodec) Encode(w io.Writer, puzzle *Puzzle) error {
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	for k, e := range puzzle.Edges {
+		from := ""
+		if e.Direction.Unidirectional {
+			from = strconv.Itoa(int(e.Direction.From))
+		}
+		cw.Write([]string{"edge", strconv.Itoa(k), strconv.Itoa(int(e.PointA)), strconv.Itoa(int(e.PointB)), strconv.Itoa(int(e.Count)), from,
+			"", "", "", "", "", "", ""})
+	}
+	for _, p := range puzzle.Points {
+		x, y := "", ""
+		if p.HasPosition() {
+			x, y = formatFloat(*p.X), formatFloat(*p.Y)
+		}
+		cw.Write([]string{"point", strconv.Itoa(p.ID), "", "", "", "",
+			strconv.Itoa(p.Level), p.Label, x, y, csvBool(p.Start), csvBool(p.End), csvBool(p.Hide)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvBool(b bool) string {
+	if b {
+		return "true"
+	}
+	return ""
+}
+
+func (this CsvCodec) Decode(data []byte) (*Puzzle, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+
+	var columns map[string]int
+	typed := false
+	cell := func(row []string, name string) string {
+		k, ok := columns[name]
+		if !ok || k >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[k])
+	}
+
+	var edges []Edge
+	var points []Point
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("csv: %w", err)
+		}
+		line, _ := r.FieldPos(0)
+
+		if columns == nil {
+			if _, err := strconv.Atoi(strings.TrimSpace(row[0])); err == nil {
+				columns = map[string]int{"point_a": 0, "point_b": 1, "count": 2, "from": 3}
+			} else {
+				columns = csvColumns(row)
+				_, typed = columns["type"]
+				continue
+			}
+		}
+
+		kind := "edge"
+		if typed {
+			kind = strings.ToLower(cell(row, "type"))
+		}
+		switch kind {
+		case "edge":
+			e, err := csvEdge(cell(row, "point_a"), cell(row, "point_b"), cell(row, "count"), cell(row, "from"))
+			if err != nil {
+				return nil, fmt.Errorf("csv: line %d: %w", line, err)
+			}
+			edges = append(edges, e)
+		case "point":
+			id, err := strconv.Atoi(cell(row, "id"))
+			if err != nil {
+				return nil, fmt.Errorf("csv: line %d: invalid point %q", line, cell(row, "id"))
+			}
+			v := map[string]string{}
+			for _, name := range []string{"level", "label", "x", "y", "start", "end", "hide"} {
+				v[name] = cell(row, name)
+			}
+			p, err := pointFromValues(id, strconv.Itoa(id), v)
+			if err != nil {
+				return nil, fmt.Errorf("csv: line %d: %w", line, err)
+			}
+			points = append(points, p)
+		default:
+			return nil, fmt.Errorf("csv: line %d: unknown row type %q", line, kind)
+		}
+	}
+	if len(edges) == 0 {
+		return nil, fmt.Errorf("csv: no edges")
+	}
+
+	np := NewPuzzle(edges)
+	np.Points = points
+	return np, nil
+}
+
+// csvColumns indexes a header row by lower-case column name, accepting
+// source and target for point_a and point_b.
+func csvColumns(header []string) map[string]int {
+	columns := make(map[string]int, len(header))
+	for k, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = k
+	}
+	for alias, name := range map[string]string{"source": "point_a", "target": "point_b"} {
+		if k, ok := columns[alias]; ok {
+			if _, exists := columns[name]; !exists {
+				columns[name] = k
+			}
+		}
+	}
+	return columns
+}
+
+func csvEdge(a, b, count, from string) (Edge, error) {
+	pa, errA := strconv.ParseUint(a, 10, 16)
+	pb, errB := strconv.ParseUint(b, 10, 16)
+	if errA != nil || errB != nil {
+		return Edge{}, fmt.Errorf("invalid edge %q-%q", a, b)
+	}
+	e := Edge{PointA: uint16(pa), PointB: uint16(pb), Count: 1}
+	if count != "" {
+		n, err := strconv.ParseUint(count, 10, 16)
+		if err != nil || n == 0 {
+			return Edge{}, fmt.Errorf("invalid count %q", count)
+		}
+		e.Count = uint16(n)
+	}
+	switch from {
+	case "":
+	case a:
+		e.Direction = Direction{From: e.PointA, To: e.PointB, Unidirectional: true}
+	case b:
+		e.Direction = Direction{From: e.PointB, To: e.PointA, Unidirectional: true}
+	default:
+		return Edge{}, fmt.Errorf("edge %s-%s cannot start from %s", a, b, from)
+	}
+	return e, nil
+}
+
+// WriteSolutionsCsv writes one row per step of every solution: the solution
+// index, the step number, the points it goes from and to, and the index in
+// puzzle.Edges of the edge it draws, as written by CsvCodec. The edge is left
+// empty when puzzle is nil.
+func WriteSolutionsCsv(w io.Writer, puzzle *Puzzle, solutions *Solutions) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"solution", "step", "from", "to", "edge"})
+	for k, solution := range *solutions {
+		var steps []int
+		if puzzle != nil {
+			var err error
+			if steps, err = solutionEdges(puzzle, solution); err != nil {
+				return fmt.Errorf("solution %d: %w", k, err)
+			}
+		}
+		for i := 1; i < len(solution); i++ {
+			edge := ""
+			if steps != nil {
+				edge = strconv.Itoa(steps[i-1])
+			}
+			cw.Write([]string{strconv.Itoa(k), strconv.Itoa(i), strconv.Itoa(int(solution[i-1])), strconv.Itoa(int(solution[i])), edge})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// solutionEdges returns, for every step of the solution, the index of the
+// puzzle edge it draws, checking the edge can still be drawn that way.
+func solutionEdges(puzzle *Puzzle, solution Solution) ([]int, error) {
+	p := puzzle.Copy()
+	steps := make([]int, 0, len(solution))
+	for i := 1; i < len(solution); i++ {
+		from, to := solution[i-1], solution[i]
+		found := -1
+		for k := range p.Edges {
+			e := &p.Edges[k]
+			if (e.PointA == from && e.PointB == to || e.PointA == to && e.PointB == from) && e.canGoTo(from, to) {
+				found = k
+				break
+			}
+		}
+		if found < 0 {
+			return nil, fmt.Errorf("step %d: no edge left from %d to %d", i, from, to)
+		}
+		p.visitEdge(&p.Edges[found])
+		steps = append(steps, found)
+	}
+	return steps, nil
+}
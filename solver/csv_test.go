@@ -0,0 +1,81 @@
+package solver
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCsvRoundTripBundledPuzzles(t *testing.T) {
+	for name, p := range loadBundledPuzzles(t) {
+		var b bytes.Buffer
+		if err := (CsvCodec{}).Encode(&b, p); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := CsvCodec{}.Decode(b.Bytes())
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", name, err, b.String())
+		}
+		assertSamePuzzle(t, name, p, got)
+	}
+}
+
+func TestCsvDecodeEdgeLists(t *testing.T) {
+	expected := NewPuzzle([]Edge{
+		{PointA: 1, PointB: 2, Count: 1},
+		{PointA: 2, PointB: 3, Count: 2},
+		{PointA: 3, PointB: 1, Count: 1, Direction: Direction{From: 1, To: 3, Unidirectional: true}},
+	})
+	tests := map[string]string{
+		"no header":    "1,2\n2,3,2\n3,1,1,1\n",
+		"source":       "Source,Target,Count,From\n1,2,,\n2,3,2,\n# comment\n3,1,,1\n",
+		"typed header": "type,point_a,point_b,count,from\nedge,1,2,1,\nedge,2,3,2,\nedge,3,1,1,1\n",
+	}
+	for name, data := range tests {
+		got, err := CsvCodec{}.Decode([]byte(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		assertSamePuzzle(t, name, expected, got)
+	}
+}
+
+func TestCsvDecodeErrors(t *testing.T) {
+	tests := map[string]string{
+		"empty":     "",
+		"bad edge":  "1,a\n",
+		"bad count": "1,2,0\n",
+		"bad from":  "1,2,1,3\n",
+		"bad type":  "type,id\nnode,1\n",
+		"bad point": "type,id,level\npoint,x,1\n",
+		"bad level": "type,id,level\npoint,1,high\n",
+	}
+	for name, data := range tests {
+		_, err := CsvCodec{}.Decode([]byte(data))
+		if err == nil || !strings.HasPrefix(err.Error(), "csv: ") {
+			t.Errorf("%s: expected csv error, got %v", name, err)
+		}
+	}
+}
+
+func TestWriteSolutionsCsv(t *testing.T) {
+	p := NewPuzzle([]Edge{
+		{PointA: 1, PointB: 2, Count: 1},
+		{PointA: 2, PointB: 3, Count: 1},
+		{PointA: 3, PointB: 1, Count: 2},
+	})
+	solutions := Solutions{{1, 3, 2, 1, 3}}
+	var b bytes.Buffer
+	if err := WriteSolutionsCsv(&b, p, &solutions); err != nil {
+		t.Fatal(err)
+	}
+	expected := "solution,step,from,to,edge\n0,1,1,3,2\n0,2,3,2,1\n0,3,2,1,0\n0,4,1,3,2\n"
+	if b.String() != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, b.String())
+	}
+
+	invalid := Solutions{{1, 2, 1}}
+	if err := WriteSolutionsCsv(&bytes.Buffer{}, p, &invalid); err == nil {
+		t.Error("Expected error for solution drawing an edge twice")
+	}
+}
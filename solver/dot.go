@@ -0,0 +1,516 @@
+package solver
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DotCodec reads and writes Graphviz DOT. Puzzles with directed edges are
+// written as a digraph, where undirected edges carry dir=none and edges
+// declared against their direction carry dir=back. An edge that must be
+// drawn Count times is written as Count parallel edges.
+//
+// The reader accepts the subset of DOT needed to sketch puzzles: node and
+// edge statements (including chains like 1 -- 2 -- 3), attribute lists and
+// node/edge defaults. Parallel edges add up, as does a count=N attribute or
+// a label of the form "x2". Nodes may be named instead of numbered; named
+// nodes get the next free numbers and keep their name as label. Subgraphs are
+// not supported.
+type DotCodec struct{}
+
+func (this DotCodec) Encode(w io.Writer, puzzle *Puzzle) error {
+	directed := false
+	for _, e := range puzzle.Edges {
+		if e.Direction.Unidirectional {
+			directed = true
+		}
+	}
+	kind, op := "graph", "--"
+	if directed {
+		kind, op = "digraph", "->"
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s puzzle {\n", kind)
+	for _, p := range puzzle.Points {
+		attrs := make([]string, 0, 6)
+		if p.Level != 0 {
+			attrs = append(attrs, fmt.Sprintf("level=%d", p.Level))
+		}
+		if p.Label != "" {
+			attrs = append(attrs, "label="+dotQuote(p.Label))
+		}
+		if p.HasPosition() {
+			attrs = append(attrs, fmt.Sprintf("pos=\"%s,%s\"", formatFloat(*p.X), formatFloat(*p.Y)))
+		}
+		if p.Start {
+			attrs = append(attrs, "start=true")
+		}
+		if p.End {
+			attrs = append(attrs, "end=true")
+		}
+		if p.Hide {
+			attrs = append(attrs, "hide=true", "style=invis")
+		}
+		fmt.Fprintf(bw, "\t%d%s;\n", p.ID, dotAttrs(attrs))
+	}
+	for _, e := range puzzle.Edges {
+		var attrs []string
+		if directed && !e.Direction.Unidirectional {
+			attrs = append(attrs, "dir=none")
+		} else if e.Direction.Unidirectional && e.Direction.From == e.PointB {
+			attrs = append(attrs, "dir=back")
+		}
+		for i := uint16(0); i < e.Count; i++ {
+			fmt.Fprintf(bw, "\t%d %s %d%s;\n", e.PointA, op, e.PointB, dotAttrs(attrs))
+		}
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+func (this DotCodec) Decode(data []byte) (*Puzzle, error) {
+	p := &dotParser{tokens: dotTokens(string(data))}
+	if err := p.parse(); err != nil {
+		return nil, fmt.Errorf("dot: %w", err)
+	}
+	return p.puzzle()
+}
+
+func dotAttrs(attrs []string) string {
+	if len(attrs) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(attrs, ", ") + "]"
+}
+
+// dotQuote writes s as a DOT string, escaping backslashes too so that a
+// label ending with one does not escape the closing quote.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+type dotToken struct {
+	text   string
+	quoted bool
+	line   int
+}
+
+// dotTokens splits DOT source into IDs, quoted strings, edge operators and
+// punctuation, dropping comments and preprocessor lines.
+func dotTokens(src string) []dotToken {
+	var tokens []dotToken
+	line := 1
+	atLineStart := true
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			atLineStart = true
+			i++
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+			continue
+		case c == '#' && atLineStart:
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				end = len(src) - i - 2
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += end + 4
+			continue
+		}
+		atLineStart = false
+
+		switch {
+		case strings.HasPrefix(src[i:], "--") || strings.HasPrefix(src[i:], "->"):
+			tokens = append(tokens, dotToken{text: src[i : i+2], line: line})
+			i += 2
+		case strings.ContainsRune("{}[];,=:", rune(c)):
+			tokens = append(tokens, dotToken{text: string(c), line: line})
+			i++
+		case c == '"':
+			var b strings.Builder
+			start := line
+			i++
+			for i < len(src) && src[i] != '"' {
+				if src[i] == '\\' && i+1 < len(src) && (src[i+1] == '"' || src[i+1] == '\\') {
+					i++
+				} else if src[i] == '\n' {
+					line++
+				}
+				b.WriteByte(src[i])
+				i++
+			}
+			i++
+			tokens = append(tokens, dotToken{text: b.String(), quoted: true, line: start})
+		default:
+			j := i
+			for j < len(src) && isDotIDChar(src, j, i) {
+				j++
+			}
+			if j == i {
+				j = i + 1
+			}
+			tokens = append(tokens, dotToken{text: src[i:j], line: line})
+			i = j
+		}
+	}
+	return tokens
+}
+
+func isDotIDChar(src string, j, start int) bool {
+	c := src[j]
+	if c == '-' {
+		// A leading minus belongs to a numeral, any other one starts an
+		// edge operator.
+		return j == start && j+1 < len(src) && (src[j+1] == '.' || (src[j+1] >= '0' && src[j+1] <= '9'))
+	}
+	return c == '_' || c == '.' || c >= 0x80 || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+type dotNode struct {
+	name  string
+	attrs map[string]string
+}
+
+type dotEdge struct {
+	a, b     string
+	directed bool
+	attrs    map[string]string
+}
+
+type dotParser struct {
+	tokens   []dotToken
+	pos      int
+	directed bool
+	nodes    []*dotNode
+	byName   map[string]*dotNode
+	declared bool
+	edges    []dotEdge
+}
+
+func (this *dotParser) peek() *dotToken {
+	if this.pos >= len(this.tokens) {
+		return nil
+	}
+	return &this.tokens[this.pos]
+}
+
+func (this *dotParser) next() (dotToken, error) {
+	t := this.peek()
+	if t == nil {
+		return dotToken{}, errors.New("unexpected end of input")
+	}
+	this.pos++
+	return *t, nil
+}
+
+func (this *dotParser) is(text string) bool {
+	t := this.peek()
+	return t != nil && !t.quoted && strings.EqualFold(t.text, text)
+}
+
+func (this *dotParser) expect(text string) error {
+	t, err := this.next()
+	if err != nil {
+		return err
+	}
+	if t.quoted || t.text != text {
+		return fmt.Errorf("line %d: expected %q, got %q", t.line, text, t.text)
+	}
+	return nil
+}
+
+func (this *dotParser) id() (dotToken, error) {
+	t, err := this.next()
+	if err != nil {
+		return t, err
+	}
+	if !t.quoted && (strings.ContainsAny(t.text, "{}[];,=:") || t.text == "--" || t.text == "->") {
+		return t, fmt.Errorf("line %d: expected an ID, got %q", t.line, t.text)
+	}
+	return t, nil
+}
+
+func (this *dotParser) parse() error {
+	this.byName = make(map[string]*dotNode)
+	if this.is("strict") {
+		this.pos++
+	}
+	switch {
+	case this.is("graph"):
+	case this.is("digraph"):
+		this.directed = true
+	default:
+		return errors.New("expected graph or digraph")
+	}
+	this.pos++
+	if !this.is("{") {
+		if _, err := this.id(); err != nil {
+			return err
+		}
+	}
+	if err := this.expect("{"); err != nil {
+		return err
+	}
+
+	nodeDefaults, edgeDefaults := map[string]string{}, map[string]string{}
+	for !this.is("}") {
+		t := this.peek()
+		if t == nil {
+			return errors.New("missing closing brace")
+		}
+		switch {
+		case this.is(";"):
+			this.pos++
+			continue
+		case this.is("subgraph") || this.is("{"):
+			return fmt.Errorf("line %d: subgraphs are not supported", t.line)
+		case this.is("graph") || this.is("node") || this.is("edge"):
+			kind := strings.ToLower(t.text)
+			this.pos++
+			attrs, err := this.attrList()
+			if err != nil {
+				return err
+			}
+			target := nodeDefaults
+			if kind == "edge" {
+				target = edgeDefaults
+			}
+			if kind != "graph" {
+				for k, v := range attrs {
+					target[k] = v
+				}
+			}
+			continue
+		}
+
+		first, err := this.id()
+		if err != nil {
+			return err
+		}
+		if this.is("=") {
+			// Graph attribute, e.g. rankdir=LR.
+			this.pos++
+			if _, err := this.id(); err != nil {
+				return err
+			}
+			continue
+		}
+		if this.is(":") {
+			return fmt.Errorf("line %d: ports are not supported", first.line)
+		}
+
+		chain := []string{first.text}
+		var ops []string
+		for this.is("--") || this.is("->") {
+			op, _ := this.next()
+			to, err := this.id()
+			if err != nil {
+				return err
+			}
+			ops = append(ops, op.text)
+			chain = append(chain, to.text)
+		}
+		attrs, err := this.attrList()
+		if err != nil {
+			return err
+		}
+
+		if len(chain) == 1 {
+			n := this.node(first.text)
+			this.declared = true
+			for k, v := range nodeDefaults {
+				if _, exists := n.attrs[k]; !exists {
+					n.attrs[k] = v
+				}
+			}
+			for k, v := range attrs {
+				n.attrs[k] = v
+			}
+			continue
+		}
+		merged := make(map[string]string, len(edgeDefaults)+len(attrs))
+		for k, v := range edgeDefaults {
+			merged[k] = v
+		}
+		for k, v := range attrs {
+			merged[k] = v
+		}
+		for k := range ops {
+			this.node(chain[k])
+			this.node(chain[k+1])
+			this.edges = append(this.edges, dotEdge{a: chain[k], b: chain[k+1], directed: ops[k] == "->", attrs: merged})
+		}
+	}
+	this.pos++
+	return nil
+}
+
+func (this *dotParser) node(name string) *dotNode {
+	n, ok := this.byName[name]
+	if !ok {
+		n = &dotNode{name: name, attrs: map[string]string{}}
+		this.byName[name] = n
+		this.nodes = append(this.nodes, n)
+	}
+	return n
+}
+
+func (this *dotParser) attrList() (map[string]string, error) {
+	attrs := map[string]string{}
+	for this.is("[") {
+		this.pos++
+		for !this.is("]") {
+			key, err := this.id()
+			if err != nil {
+				return nil, err
+			}
+			value := "true"
+			if this.is("=") {
+				this.pos++
+				v, err := this.id()
+				if err != nil {
+					return nil, err
+				}
+				value = v.text
+			}
+			attrs[strings.ToLower(key.text)] = value
+			if this.is(",") || this.is(";") {
+				this.pos++
+			}
+		}
+		this.pos++
+	}
+	return attrs, nil
+}
+
+// puzzle turns the parsed statements into a puzzle, numbering named nodes
+// after the highest numbered one.
+func (this *dotParser) puzzle() (*Puzzle, error) {
+	ids := newPointNumbering()
+	for _, n := range this.nodes {
+		ids.add(n.name)
+	}
+	ids.assign()
+
+	p := &Puzzle{}
+	if this.declared || ids.named {
+		for _, n := range this.nodes {
+			point, err := pointFromValues(ids.id(n.name), n.name, dotValues(n.attrs))
+			if err != nil {
+				return nil, fmt.Errorf("dot: node %s: %w", n.name, err)
+			}
+			p.Points = append(p.Points, point)
+		}
+	}
+
+	type edgeKey struct {
+		a, b uint16
+		from uint16
+	}
+	index := make(map[edgeKey]int)
+	for _, de := range this.edges {
+		a, b := ids.id(de.a), ids.id(de.b)
+		if a < 0 || a > 0xffff || b < 0 || b > 0xffff {
+			return nil, fmt.Errorf("dot: edge %s-%s: points on edges must be between 0 and 65535", de.a, de.b)
+		}
+		e := Edge{PointA: uint16(a), PointB: uint16(b)}
+		directed := de.directed && this.directed
+		switch strings.ToLower(de.attrs["dir"]) {
+		case "none", "both":
+			directed = false
+		case "back":
+			if directed {
+				e.Direction = Direction{From: e.PointB, To: e.PointA, Unidirectional: true}
+			}
+		}
+		if directed && !e.Direction.Unidirectional {
+			e.Direction = Direction{From: e.PointA, To: e.PointB, Unidirectional: true}
+		}
+
+		count, err := dotCount(de.attrs)
+		if err != nil {
+			return nil, fmt.Errorf("dot: edge %s-%s: %w", de.a, de.b, err)
+		}
+
+		key := edgeKey{e.PointA, e.PointB, e.Direction.From}
+		if !directed && key.a > key.b {
+			key.a, key.b = key.b, key.a
+		}
+		if k, exists := index[key]; exists {
+			p.Edges[k].Count += count
+			continue
+		}
+		e.Count = count
+		index[key] = len(p.Edges)
+		p.Edges = append(p.Edges, e)
+	}
+
+	np := NewPuzzle(p.Edges)
+	np.Points = p.Points
+	return np, nil
+}
+
+// dotValues maps DOT node attributes to the names pointFromValues reads.
+func dotValues(attrs map[string]string) map[string]string {
+	v := make(map[string]string, len(attrs)+2)
+	for k, value := range attrs {
+		v[k] = value
+	}
+	if pos, ok := attrs["pos"]; ok {
+		x, y, found := strings.Cut(strings.TrimSuffix(pos, "!"), ",")
+		if !found {
+			x, y = pos, "invalid"
+		}
+		v["x"], v["y"] = strings.TrimSpace(x), strings.TrimSpace(y)
+	}
+	if strings.EqualFold(attrs["style"], "invis") {
+		v["hide"] = "true"
+	}
+	return v
+}
+
+// dotCount reads how many times an edge statement counts: its count
+// attribute, else a label such as "x2", else once. A bare number is a
+// label like any other, not a count.
+func dotCount(attrs map[string]string) (uint16, error) {
+	v, ok := attrs["count"]
+	if !ok {
+		label := strings.TrimSpace(attrs["label"])
+		for _, prefix := range []string{"x", "×"} {
+			digits, found := strings.CutPrefix(label, prefix)
+			if n, err := strconv.ParseUint(digits, 10, 16); found && err == nil && n > 0 {
+				return uint16(n), nil
+			}
+		}
+		return 1, nil
+	}
+	n, err := strconv.ParseUint(v, 10, 16)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid count %q", v)
+	}
+	return uint16(n), nil
+}
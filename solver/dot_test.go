@@ -0,0 +1,194 @@
+package solver
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func loadBundledPuzzles(t *testing.T) map[string]*Puzzle {
+	t.Helper()
+	files, err := filepath.Glob("../puzzles/*.json")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("No bundled puzzles found: %v", err)
+	}
+	puzzles := make(map[string]*Puzzle, len(files))
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := NewPuzzleFromBytes(content)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		puzzles[filepath.Base(file)] = p
+	}
+	return puzzles
+}
+
+func assertSamePuzzle(t *testing.T, name string, expected, got *Puzzle) {
+	t.Helper()
+	if !reflect.DeepEqual(expected.Edges, got.Edges) {
+		t.Errorf("%s: edges differ\nexpected %+v\ngot      %+v", name, expected.Edges, got.Edges)
+	}
+	e, _ := json.Marshal(expected.Points)
+	g, _ := json.Marshal(got.Points)
+	if !bytes.Equal(e, g) && len(expected.Points)+len(got.Points) > 0 {
+		t.Errorf("%s: points differ\nexpected %s\ngot      %s", name, e, g)
+	}
+	if expected.count != got.count {
+		t.Errorf("%s: expected %d edges to draw, got %d", name, expected.count, got.count)
+	}
+}
+
+func TestDotRoundTripBundledPuzzles(t *testing.T) {
+	for name, p := range loadBundledPuzzles(t) {
+		var b bytes.Buffer
+		if err := (DotCodec{}).Encode(&b, p); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := DotCodec{}.Decode(b.Bytes())
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", name, err, b.String())
+		}
+		assertSamePuzzle(t, name, p, got)
+	}
+}
+
+func TestDotEncodeDirectedPuzzle(t *testing.T) {
+	p := NewPuzzle([]Edge{
+		{PointA: 1, PointB: 2, Count: 1, Direction: Direction{From: 1, To: 2, Unidirectional: true}},
+		{PointA: 2, PointB: 3, Count: 2},
+		{PointA: 3, PointB: 1, Count: 1, Direction: Direction{From: 1, To: 3, Unidirectional: true}},
+	})
+	var b bytes.Buffer
+	DotCodec{}.Encode(&b, p)
+
+	expected := "digraph puzzle {\n" +
+		"\t1 -> 2;\n" +
+		"\t2 -> 3 [dir=none];\n" +
+		"\t2 -> 3 [dir=none];\n" +
+		"\t3 -> 1 [dir=back];\n" +
+		"}\n"
+	if b.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+}
+
+func TestDotDecodeSketch(t *testing.T) {
+	src := `
+# sketched by hand
+strict graph house {
+	rankdir=TB
+	node [shape=circle]
+	/* the roof */
+	top [level=1, start=true]
+	left -- right -- top -- left
+	left -- right [label="x2"]; // floor is drawn three times
+	spacer [style=invis]
+}`
+	p, err := DotCodec{}.Decode([]byte(src))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expectedEdges := []Edge{
+		{PointA: 2, PointB: 3, Count: 3},
+		{PointA: 3, PointB: 1, Count: 1},
+		{PointA: 1, PointB: 2, Count: 1},
+	}
+	if !reflect.DeepEqual(p.Edges, expectedEdges) {
+		t.Errorf("Expected edges %+v, got %+v", expectedEdges, p.Edges)
+	}
+	if p.count != 5 {
+		t.Errorf("Expected 5 edges to draw, got %d", p.count)
+	}
+	top := p.GetPoint(1)
+	if top == nil || top.Label != "top" || top.Level != 1 || !top.Start {
+		t.Errorf("Expected point 1 to be the labelled start, got %+v", top)
+	}
+	if spacer := p.GetPoint(4); spacer == nil || !spacer.Hide {
+		t.Errorf("Expected point 4 to be a hidden spacer, got %+v", spacer)
+	}
+}
+
+func TestDotRoundTripLabels(t *testing.T) {
+	p := NewPuzzle([]Edge{{PointA: 1, PointB: 2, Count: 1}})
+	labels := []string{`C:\dir\`, `say "hi"`, `\"`, `a\nb`}
+	for k, label := range labels {
+		p.Points = append(p.Points, Point{ID: k + 1, Label: label})
+	}
+	var b bytes.Buffer
+	if err := (DotCodec{}).Encode(&b, p); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	decoded, err := DotCodec{}.Decode(b.Bytes())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v\n%s", err, b.String())
+	}
+	for k, label := range labels {
+		if point := decoded.GetPoint(k + 1); point == nil || point.Label != label {
+			t.Errorf("Expected label %q, got %+v", label, point)
+		}
+	}
+}
+
+func TestDotDecodeCountLabels(t *testing.T) {
+	src := `graph { 1 -- 2 [label="x2"]; 2 -- 3 [label="×3"]; 3 -- 4 [label="2"]; 4 -- 5 [label=x] }`
+	p, err := DotCodec{}.Decode([]byte(src))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var counts []uint16
+	for _, e := range p.Edges {
+		counts = append(counts, e.Count)
+	}
+	if expected := []uint16{2, 3, 1, 1}; !reflect.DeepEqual(counts, expected) {
+		t.Errorf("Expected counts %v, got %v", expected, counts)
+	}
+}
+
+func TestDotDecodeDigraph(t *testing.T) {
+	src := `digraph { 1 -> 2; 2 -> 3 [dir=none, count=2]; 1 -> 3 [dir=back]; -1 [pos="1.5,2!"] }`
+	p, err := DotCodec{}.Decode([]byte(src))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expectedEdges := []Edge{
+		{PointA: 1, PointB: 2, Count: 1, Direction: Direction{From: 1, To: 2, Unidirectional: true}},
+		{PointA: 2, PointB: 3, Count: 2},
+		{PointA: 1, PointB: 3, Count: 1, Direction: Direction{From: 3, To: 1, Unidirectional: true}},
+	}
+	if !reflect.DeepEqual(p.Edges, expectedEdges) {
+		t.Errorf("Expected edges %+v, got %+v", expectedEdges, p.Edges)
+	}
+	spacer := p.GetPoint(-1)
+	if spacer == nil || !spacer.HasPosition() || *spacer.X != 1.5 || *spacer.Y != 2 {
+		t.Errorf("Expected point -1 at (1.5, 2), got %+v", spacer)
+	}
+}
+
+func TestDotDecodeErrors(t *testing.T) {
+	tests := map[string]string{
+		"not a graph":  `{ 1 -- 2 }`,
+		"unterminated": `graph { 1 -- 2`,
+		"subgraph":     `graph { subgraph s { 1 -- 2 } }`,
+		"bad count":    `graph { 1 -- 2 [count=0] }`,
+		"bad level":    `graph { 1 [level=top] }`,
+		"bad pos":      `graph { 1 [pos="1"] }`,
+		"edge to -1":   `graph { 1 -- -1 }`,
+		"missing id":   `graph { 1 -- }`,
+	}
+	for name, src := range tests {
+		if _, err := (DotCodec{}).Decode([]byte(src)); err == nil {
+			t.Errorf("%s: expected error", name)
+		} else if !strings.HasPrefix(err.Error(), "dot: ") {
+			t.Errorf("%s: expected error prefixed with dot:, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package solver
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func FuzzNewPuzzleFromBytes(f *testing.F) {
+	files, _ := filepath.Glob("../puzzles/*.json")
+	for _, file := range files {
+		if content, err := os.ReadFile(file); err == nil {
+			f.Add(content)
+		}
+	}
+	f.Add([]byte("1-2-3-1\n2>4 x2\npoint 4 level=2 label=\"top\"\nstart 2\n"))
+	f.Add([]byte("digraph { 1 -> 2 [count=2]; 2 -> 3 -> 1; 1 [pos=\"1,2\"] }"))
+	f.Add([]byte(`<graphml><graph edgedefault="directed"><node id="a"/><edge source="a" target="b"/></graph></graphml>`))
+	f.Add([]byte(`[{"PointA": 1, "PointB": 2, "Count": 1}]`))
+	f.Add([]byte("# c\n{\n"))
+	f.Add([]byte("// x\n{"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p, err := NewPuzzleFromBytes(data)
+		if err != nil || p.Validate() != nil {
+			return
+		}
+
+		var b bytes.Buffer
+		if err := (JsonCodec{}).Encode(&b, p); err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+		got, err := JsonCodec{}.Decode(b.Bytes())
+		if err != nil {
+			t.Fatalf("Decode of %s: %v", b.String(), err)
+		}
+		assertSamePuzzle(t, "round trip", p, got)
+
+		if p.count <= maxReferenceEdges {
+			checkSolverProperties(t, p)
+		}
+	})
+}
+
+// FuzzSolve reads every two bytes as an edge between points 1 to 5: the
+// first byte picks the points, the second the count and direction. Edges
+// may repeat, which the solver has to handle like the reference does.
+func FuzzSolve(f *testing.F) {
+	f.Add([]byte{5, 0, 11, 0, 2, 0})
+	f.Add([]byte{5, 4, 5, 0, 11, 1})
+	f.Add([]byte{5, 6, 11, 0, 17, 2, 23, 0})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var edges []Edge
+		total := 0
+		for i := 0; i+1 < len(data); i += 2 {
+			a, b := uint16(1+data[i]%5), uint16(1+data[i]/5%5)
+			if a == b {
+				continue
+			}
+			e := Edge{PointA: a, PointB: b, Count: uint16(1 + data[i+1]&1)}
+			switch data[i+1] >> 1 & 3 {
+			case 2:
+				e.Direction = Direction{From: a, To: b, Unidirectional: true}
+			case 3:
+				e.Direction = Direction{From: b, To: a, Unidirectional: true}
+			}
+			if total+int(e.Count) > maxReferenceEdges {
+				break
+			}
+			total += int(e.Count)
+			edges = append(edges, e)
+		}
+		if len(edges) == 0 {
+			return
+		}
+		checkSolverProperties(t, NewPuzzle(edges))
+	})
+}
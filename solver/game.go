@@ -0,0 +1,306 @@
+package solver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// GameState is a puzzle being drawn one stroke at a time, as a player
+// would: the first move picks the starting point and every other one draws
+// an edge from the current point.
+type GameState struct {
+	puzzle Puzzle
+	path   []uint16
+	// drawn is the index in puzzle.Edges of the edge drawn by every step.
+	drawn []int
+}
+
+// NewGameState starts a game on a copy of puzzle.
+func NewGameState(puzzle *Puzzle) *GameState {
+	return &GameState{puzzle: puzzle.Copy()}
+}
+
+// Puzzle returns the puzzle with the edges drawn so far taken out of the
+// counts. It must not be modified.
+func (this *GameState) Puzzle() *Puzzle {
+	return &this.puzzle
+}
+
+// Path returns the points visited so far.
+func (this *GameState) Path() Solution {
+	return append(Solution(nil), this.path...)
+}
+
+// Current returns the point the player is on, and false before the first
+// move.
+func (this *GameState) Current() (uint16, bool) {
+	if len(this.path) == 0 {
+		return 0, false
+	}
+	return this.path[len(this.path)-1], true
+}
+
+// Moves lists the points the next move can go to: the starting points
+// before the first move, then the ends of the edges that can still be
+// drawn from the current point.
+func (this *GameState) Moves() []uint16 {
+	current, ok := this.Current()
+	if !ok {
+		return this.puzzle.listStartingPoints()
+	}
+	return this.puzzle.listPossibleEdgesToVisit(&current)
+}
+
+// Play moves to point, drawing the edge from the current point.
+func (this *GameState) Play(point uint16) error {
+	current, ok := this.Current()
+	if !ok {
+		for _, p := range this.Moves() {
+			if p == point {
+				this.path = append(this.path, point)
+				return nil
+			}
+		}
+		return fmt.Errorf("cannot start at %d", point)
+	}
+
+	edge, err := this.puzzle.getEdge(&current, &point)
+	if err != nil {
+		return fmt.Errorf("no edge left from %d to %d", current, point)
+	}
+	for k := range this.puzzle.Edges {
+		if &this.puzzle.Edges[k] == edge {
+			this.drawn = append(this.drawn, k)
+		}
+	}
+	this.puzzle.visitEdge(edge)
+	this.path = append(this.path, point)
+	return nil
+}
+
+// Undo takes back the last move, returning false when there is none.
+func (this *GameState) Undo() bool {
+	if len(this.path) == 0 {
+		return false
+	}
+	if len(this.drawn) > 0 && len(this.drawn) == len(this.path)-1 {
+		k := this.drawn[len(this.drawn)-1]
+		this.drawn = this.drawn[:len(this.drawn)-1]
+		this.puzzle.Edges[k].Count++
+		this.puzzle.count++
+	}
+	this.path = this.path[:len(this.path)-1]
+	return true
+}
+
+// IsSolved reports whether every edge is drawn and the path ends on a point
+// where solutions may end.
+func (this *GameState) IsSolved() bool {
+	current, ok := this.Current()
+	return ok && this.puzzle.isSolved() && this.puzzle.canEndAt(current)
+}
+
+// IsStuck reports whether the game is over without being solved: no move
+// is left, whether or not every edge is drawn.
+func (this *GameState) IsStuck() bool {
+	return !this.IsSolved() && len(this.Moves()) == 0
+}
+
+// Remaining returns the number of strokes left to draw, counting an edge
+// once for every time it is still to be drawn.
+func (this *GameState) Remaining() int {
+	return int(this.puzzle.count)
+}
+
+// Hint returns a move that leads to a solution from the current state, and
+// false when there is none.
+func (this *GameState) Hint() (uint16, bool) {
+	next, ok, _ := this.HintContext(context.Background(), nil)
+	return next, ok
+}
+
+// HintContext is Hint, stopping when ctx is done or when the search goes
+// over the node limit of progress, with their error.
+func (this *GameState) HintContext(ctx context.Context, progress *Progress) (uint16, bool, error) {
+	solution, err := this.findSolution(ctx, progress)
+	if solution == nil {
+		return 0, false, err
+	}
+	return solution[len(this.path)], true, nil
+}
+
+// findSolution returns the first solution that starts with the moves played
+// so far, or nil.
+func (this *GameState) findSolution(ctx context.Context, progress *Progress) (Solution, error) {
+	if this.IsSolved() {
+		return nil, nil
+	}
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+	starts := this.Moves()
+	prefix := []uint16{}
+	if current, ok := this.Current(); ok {
+		starts = []uint16{current}
+		prefix = this.path[:len(this.path)-1]
+	}
+	for k := range starts {
+		finder := &solutionFinder{walker: walker{ctx: ctx, stop: stop, progress: progress}}
+		pc := this.puzzle.Copy()
+		findSolutions(&pc, &starts[k], append([]uint16(nil), prefix...), finder, &finder.walker)
+		finder.walker.report()
+		if finder.solution != nil {
+			return finder.solution, nil
+		}
+		if ctx.Err() != nil {
+			return nil, context.Cause(ctx)
+		}
+	}
+	return nil, nil
+}
+
+// CountSolutions returns the number of solutions that start with the moves
+// played so far.
+func (this *GameState) CountSolutions() int {
+	current, ok := this.Current()
+	if !ok {
+		return GetNumberOfSolutions(&this.puzzle)
+	}
+	if this.IsSolved() {
+		return 1
+	}
+	counter := newSolutionCounter()
+	pc := this.puzzle.Copy()
+	findSolutions(&pc, &current, nil, counter, &walker{ctx: context.Background()})
+	return counter.count_solutions
+}
+
+// Degree counts the ends of the edges left to draw at a point: Undirected
+// for edges that can be drawn either way, In and Out for directed ones.
+type Degree struct {
+	Point      uint16
+	Undirected int
+	In         int
+	Out        int
+}
+
+// Degrees returns the degree of every point with edges left, by point.
+func (this *GameState) Degrees() []Degree {
+	index := map[uint16]int{}
+	var degrees []Degree
+	at := func(point uint16) *Degree {
+		k, ok := index[point]
+		if !ok {
+			k = len(degrees)
+			index[point] = k
+			degrees = append(degrees, Degree{Point: point})
+		}
+		return &degrees[k]
+	}
+	for _, e := range this.puzzle.Edges {
+		if e.Count == 0 {
+			continue
+		}
+		n := int(e.Count)
+		if e.Direction.Unidirectional {
+			at(e.Direction.From).Out += n
+			at(e.Direction.To).In += n
+		} else {
+			at(e.PointA).Undirected += n
+			at(e.PointB).Undirected += n
+		}
+	}
+	sort.Slice(degrees, func(i, j int) bool { return degrees[i].Point < degrees[j].Point })
+	return degrees
+}
+
+// Position returns the edges left to draw as a puzzle of their own, whose
+// solutions start on the current point.
+func (this *GameState) Position() *Puzzle {
+	var edges []Edge
+	for _, e := range this.puzzle.Edges {
+		if e.Count > 0 {
+			edges = append(edges, e.Copy())
+		}
+	}
+	p := NewPuzzle(edges)
+	for _, point := range this.puzzle.Points {
+		p.Points = append(p.Points, point.Copy())
+	}
+
+	current, ok := this.Current()
+	if !ok {
+		return p
+	}
+	if len(p.Points) == 0 {
+		for _, e := range this.puzzle.Edges {
+			for _, id := range []uint16{e.PointA, e.PointB} {
+				if p.GetPoint(int(id)) == nil {
+					p.Points = append(p.Points, Point{ID: int(id)})
+				}
+			}
+		}
+	}
+	for k := range p.Points {
+		p.Points[k].Start = p.Points[k].ID == int(current)
+	}
+	return p
+}
+
+// gameJson is the JSON form of a GameState. Only Puzzle and Path are read
+// back; the rest is there for clients that do not run a move engine.
+type gameJson struct {
+	Puzzle *Puzzle
+	Path   []uint16
+	// Left is the edges of the puzzle with the counts left to draw.
+	Left      []Edge
+	Moves     []uint16
+	Remaining int
+	Solved    bool
+	Stuck     bool
+}
+
+// MarshalJSON writes the puzzle as it was before the first move along with
+// the path played, and the state derived from them.
+func (this *GameState) MarshalJSON() ([]byte, error) {
+	initial := this.puzzle.Copy()
+	for _, k := range this.drawn {
+		initial.Edges[k].Count++
+		initial.count++
+	}
+	return json.Marshal(gameJson{
+		Puzzle:    &initial,
+		Path:      append([]uint16{}, this.path...),
+		Left:      append([]Edge{}, this.puzzle.Edges...),
+		Moves:     append([]uint16{}, this.Moves()...),
+		Remaining: this.Remaining(),
+		Solved:    this.IsSolved(),
+		Stuck:     this.IsStuck(),
+	})
+}
+
+// UnmarshalJSON restores a game by replaying its path on its puzzle, so an
+// illegal path is an error.
+func (this *GameState) UnmarshalJSON(data []byte) error {
+	var g gameJson
+	if err := json.Unmarshal(data, &g); err != nil {
+		return err
+	}
+	if g.Puzzle == nil {
+		return fmt.Errorf("game has no puzzle")
+	}
+	puzzle := NewPuzzle(g.Puzzle.Edges)
+	puzzle.Points = g.Puzzle.Points
+	if err := puzzle.Validate(); err != nil {
+		return err
+	}
+	game := NewGameState(puzzle)
+	for k, point := range g.Path {
+		if err := game.Play(point); err != nil {
+			return fmt.Errorf("move %d: %v", k+1, err)
+		}
+	}
+	*this = *game
+	return nil
+}
@@ -0,0 +1,207 @@
+package solver
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func housePuzzle() *Puzzle {
+	return NewPuzzle([]Edge{
+		{PointA: 1, PointB: 2, Count: 1},
+		{PointA: 1, PointB: 3, Count: 1},
+		{PointA: 2, PointB: 3, Count: 1},
+		{PointA: 2, PointB: 4, Count: 1},
+		{PointA: 2, PointB: 5, Count: 1},
+		{PointA: 3, PointB: 4, Count: 1},
+		{PointA: 3, PointB: 5, Count: 1},
+		{PointA: 4, PointB: 5, Count: 1},
+	})
+}
+
+func houseGame() *GameState {
+	return NewGameState(housePuzzle())
+}
+
+func TestGameStatePlayAndUndo(t *testing.T) {
+	g := houseGame()
+	if _, ok := g.Current(); ok {
+		t.Error("Expected no current point before the first move")
+	}
+	if err := g.Play(9); err == nil {
+		t.Error("Expected error starting on a point not in the puzzle")
+	}
+	for _, p := range []uint16{4, 2, 1} {
+		if err := g.Play(p); err != nil {
+			t.Fatalf("Play(%d): %v", p, err)
+		}
+	}
+	if err := g.Play(2); err == nil {
+		t.Error("Expected error drawing 1-2 twice")
+	}
+	if moves := g.Moves(); !reflect.DeepEqual(moves, []uint16{3}) {
+		t.Errorf("Expected moves [3], got %v", moves)
+	}
+
+	if !g.Undo() {
+		t.Fatal("Expected undo to succeed")
+	}
+	if current, _ := g.Current(); current != 2 {
+		t.Errorf("Expected to be back on 2, got %d", current)
+	}
+	if err := g.Play(1); err != nil {
+		t.Errorf("Expected 2-1 to be drawable again after undo: %v", err)
+	}
+	for g.Undo() {
+	}
+	if len(g.Path()) != 0 || g.Puzzle().count != 8 {
+		t.Errorf("Expected a fresh game after undoing everything, got path %v and %d edges left", g.Path(), g.Puzzle().count)
+	}
+}
+
+func TestGameStateRespectsDirections(t *testing.T) {
+	g := NewGameState(NewPuzzle([]Edge{
+		{PointA: 1, PointB: 2, Count: 1, Direction: Direction{From: 1, To: 2, Unidirectional: true}},
+		{PointA: 2, PointB: 3, Count: 1},
+	}))
+	g.Play(2)
+	if err := g.Play(1); err == nil {
+		t.Error("Expected error going against the edge direction")
+	}
+}
+
+func TestGameStateSolvedWithHints(t *testing.T) {
+	g := houseGame()
+	for !g.IsSolved() {
+		next, ok := g.Hint()
+		if !ok {
+			t.Fatalf("No hint after %v", g.Path())
+		}
+		if err := g.Play(next); err != nil {
+			t.Fatalf("Hint %d is not a legal move after %v: %v", next, g.Path(), err)
+		}
+	}
+	if err := checkSolution(housePuzzle(), g.Path()); err != nil {
+		t.Errorf("Expected %v to solve the house: %v", g.Path(), err)
+	}
+	if _, ok := g.Hint(); ok {
+		t.Error("Expected no hint once solved")
+	}
+}
+
+func TestGameStateHintLimits(t *testing.T) {
+	g := houseGame()
+	g.Play(1)
+	if _, ok, err := g.HintContext(context.Background(), &Progress{MaxNodes: 1}); ok || err != ErrNodeLimit {
+		t.Errorf("Expected the node limit, got %v %v", ok, err)
+	}
+	progress := &Progress{}
+	if _, ok, err := g.HintContext(context.Background(), progress); ok || err != nil || progress.Nodes.Load() == 0 {
+		t.Errorf("Expected no hint after exploring nodes, got %v %v %d", ok, err, progress.Nodes.Load())
+	}
+}
+
+func TestGameStateNoHintWhenStuck(t *testing.T) {
+	g := houseGame()
+	// Starting from 1, which has an even degree, cannot solve the house.
+	g.Play(1)
+	if _, ok := g.Hint(); ok {
+		t.Error("Expected no hint from a losing start")
+	}
+}
+
+func TestGameStateCountAndDegrees(t *testing.T) {
+	g := houseGame()
+	if n := g.CountSolutions(); n != 88 {
+		t.Errorf("Expected 88 solutions before the first move, got %d", n)
+	}
+	g.Play(4)
+	g.Play(2)
+	if n := g.CountSolutions(); n != 16 {
+		t.Errorf("Expected 16 solutions after 4-2, got %d", n)
+	}
+	expected := []Degree{
+		{Point: 1, Undirected: 2},
+		{Point: 2, Undirected: 3},
+		{Point: 3, Undirected: 4},
+		{Point: 4, Undirected: 2},
+		{Point: 5, Undirected: 3},
+	}
+	if degrees := g.Degrees(); !reflect.DeepEqual(degrees, expected) {
+		t.Errorf("Expected degrees %v, got %v", expected, degrees)
+	}
+}
+
+func TestGameStatePosition(t *testing.T) {
+	g := houseGame()
+	g.Play(4)
+	g.Play(2)
+	position := g.Position()
+	if len(position.Edges) != 7 {
+		t.Fatalf("Expected 7 edges left, got %d", len(position.Edges))
+	}
+	if err := position.Validate(); err != nil {
+		t.Fatalf("Expected a valid puzzle: %v", err)
+	}
+	if n := GetNumberOfSolutions(position); n != 16 {
+		t.Errorf("Expected the position to have the 16 solutions left, got %d", n)
+	}
+}
+
+func TestGameStateStuckAndRemaining(t *testing.T) {
+	g := houseGame()
+	if g.Remaining() != 8 || g.IsStuck() {
+		t.Errorf("Expected 8 edges left and not stuck, got %d and %v", g.Remaining(), g.IsStuck())
+	}
+	// 1-2-3-1 closes the roof with the walls still to draw.
+	for _, p := range []uint16{1, 2, 3, 1} {
+		if err := g.Play(p); err != nil {
+			t.Fatalf("Play(%d): %v", p, err)
+		}
+	}
+	if g.Remaining() != 5 {
+		t.Errorf("Expected 5 edges left, got %d", g.Remaining())
+	}
+	if !g.IsStuck() || g.IsSolved() {
+		t.Error("Expected the game stuck on 1")
+	}
+	g.Undo()
+	if g.IsStuck() {
+		t.Error("Expected a way out after undo")
+	}
+}
+
+func TestGameStateJson(t *testing.T) {
+	g := houseGame()
+	for _, p := range []uint16{4, 2, 1} {
+		g.Play(p)
+	}
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	json.Unmarshal(data, &fields)
+	left, _ := fields["Left"].([]interface{})
+	if fields["Remaining"] != float64(6) || fields["Solved"] != false || len(left) != 8 {
+		t.Errorf("Unexpected derived fields in %s", data)
+	}
+
+	var restored GameState
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(restored.Path(), g.Path()) || restored.Remaining() != g.Remaining() {
+		t.Errorf("Expected path %v with %d left, got %v with %d", g.Path(), g.Remaining(), restored.Path(), restored.Remaining())
+	}
+	for restored.Undo() {
+	}
+	if restored.Remaining() != 8 {
+		t.Errorf("Expected the full puzzle back after undoing, got %d edges", restored.Remaining())
+	}
+
+	if err := json.Unmarshal([]byte(`{"Puzzle":{"Edges":[{"PointA":1,"PointB":2,"Count":1}]},"Path":[1,2,1]}`), &restored); err == nil {
+		t.Error("Expected an illegal path to be refused")
+	}
+}
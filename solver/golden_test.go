@@ -0,0 +1,175 @@
+package solver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+var goldenTimeout = flag.Duration("golden.timeout", 30*time.Second, "time allowed to solve a bundled puzzle, -update only counting the solutions of those taking longer")
+var goldenSlow = flag.Bool("golden.slow", false, "also count the solutions of the puzzles whose golden file has nothing else")
+
+// maxGoldenSolutions is the most solutions a golden file lists one by one;
+// bigger sets are checked through their count and digest only.
+const maxGoldenSolutions = 5000
+
+// TestGoldenBundledPuzzles solves every puzzle in puzzles/ and compares the
+// sorted solutions with testdata/golden/<puzzle>.golden. Run
+// `go test ./solver -run Golden -update` to accept a change.
+func TestGoldenBundledPuzzles(t *testing.T) {
+	puzzles := loadBundledPuzzles(t)
+	names := make([]string, 0, len(puzzles))
+	for name := range puzzles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := puzzles[name]
+		t.Run(strings.TrimSuffix(name, ".json"), func(t *testing.T) {
+			file := filepath.Join("testdata", "golden", strings.TrimSuffix(name, ".json")+".golden")
+			var expected []byte
+			if !*update {
+				var err error
+				if expected, err = os.ReadFile(file); err != nil {
+					t.Fatalf("%v (run with -update to create it)", err)
+				}
+				if !bytes.HasPrefix(expected, []byte("count ")) {
+					t.Fatalf("%s has no solution count (run with -update to regenerate it)", file)
+				}
+			}
+
+			var got []byte
+			if *update || !goldenCountOnly(expected) {
+				ctx, cancel := context.WithTimeout(context.Background(), *goldenTimeout)
+				defer cancel()
+				solutions, _, err := SolveContext(ctx, p)
+				switch {
+				case err == nil:
+					got = goldenFormat(solutions)
+				case !*update || !errors.Is(err, context.DeadlineExceeded):
+					t.Fatalf("%s not solved within -golden.timeout %v: %v", name, *goldenTimeout, err)
+				}
+			}
+			// Puzzles too slow to solve are checked through their count only,
+			// which takes minutes too.
+			if got == nil {
+				if !*update && !*goldenSlow {
+					t.Skipf("%s has only a count, checked with -golden.slow", file)
+				}
+				count, _, err := CountContext(context.Background(), p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = []byte(fmt.Sprintf("count %d\n", count))
+			}
+
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(file, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if !bytes.Equal(expected, got) {
+				t.Errorf("solutions of %s changed (run with -update to accept):\n%s", name, goldenDiff(expected, got))
+			}
+		})
+	}
+}
+
+// goldenCountOnly reports whether a golden file has the solution count
+// alone, with neither a digest nor solutions.
+func goldenCountOnly(golden []byte) bool {
+	return bytes.Count(golden, []byte("\n")) == 1
+}
+
+// goldenFormat writes the number of solutions, a digest of the sorted
+// solutions and, when there are not too many, the solutions themselves, one
+// per line.
+func goldenFormat(solutions *Solutions) []byte {
+	lines := make([]string, len(*solutions))
+	for k, s := range *solutions {
+		lines[k] = joinSolution(s, "-")
+	}
+	sort.Strings(lines)
+	all := strings.Join(lines, "\n")
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "count %d\n", len(lines))
+	fmt.Fprintf(&b, "digest %x\n", sha256.Sum256([]byte(all)))
+	if len(lines) <= maxGoldenSolutions {
+		for _, line := range lines {
+			fmt.Fprintln(&b, line)
+		}
+	}
+	return b.Bytes()
+}
+
+// goldenDiff describes how two golden files differ: the header lines that
+// changed and the first solutions missing from or added to the expected ones.
+func goldenDiff(expected, got []byte) string {
+	const shown = 10
+	e := strings.Split(strings.TrimSpace(string(expected)), "\n")
+	g := strings.Split(strings.TrimSpace(string(got)), "\n")
+
+	var b strings.Builder
+	for k := 0; k < 2 && k < len(e) && k < len(g); k++ {
+		if e[k] != g[k] {
+			fmt.Fprintf(&b, "  expected %s\n  got      %s\n", e[k], g[k])
+		}
+	}
+	inGot := make(map[string]bool, len(g))
+	for _, line := range g[min(2, len(g)):] {
+		inGot[line] = true
+	}
+	inExpected := make(map[string]bool, len(e))
+	for _, line := range e[min(2, len(e)):] {
+		inExpected[line] = true
+	}
+	report := func(label string, lines []string, other map[string]bool) {
+		n := 0
+		for _, line := range lines {
+			if other[line] {
+				continue
+			}
+			if n < shown {
+				fmt.Fprintf(&b, "  %s %s\n", label, line)
+			}
+			n++
+		}
+		if n > shown {
+			fmt.Fprintf(&b, "  ... and %s more %s\n", strconv.Itoa(n-shown), strings.TrimSpace(label))
+		}
+	}
+	report("missing", e[min(2, len(e)):], inGot)
+	report("extra  ", g[min(2, len(g)):], inExpected)
+	return b.String()
+}
+
+func TestGoldenDiff(t *testing.T) {
+	expected := []byte("count 2\ndigest aa\n1-2-3\n3-2-1\n")
+	got := []byte("count 2\ndigest bb\n1-2-3\n2-1-3\n")
+	diff := goldenDiff(expected, got)
+	for _, want := range []string{"expected digest aa", "got      digest bb", "missing 3-2-1", "extra   2-1-3"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("Expected %q in diff:\n%s", want, diff)
+		}
+	}
+	if strings.Contains(diff, "1-2-3") {
+		t.Errorf("Unchanged solutions should not be reported:\n%s", diff)
+	}
+}
@@ -0,0 +1,312 @@
+package solver
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// GraphmlCodec reads and writes GraphML. Point metadata is stored in node
+// data keys (level, label, x, y, start, end, hide), the number of times an
+// edge is drawn in the count edge key and directed edges are marked
+// directed="true" with the point they start from in the from key, so the
+// PointA/PointB order survives.
+//
+// When reading, keys are matched by attr.name, so files saved by other tools
+// work as long as they use the same names. Nodes become Points when any of
+// them carries data or is not numbered; named nodes get the next free
+// numbers and keep their name as label.
+type GraphmlCodec struct{}
+
+type graphmlKey struct {
+	ID      string `xml:"id,attr"`
+	For     string `xml:"for,attr"`
+	Name    string `xml:"attr.name,attr"`
+	Type    string `xml:"attr.type,attr"`
+	Default string `xml:"default,omitempty"`
+}
+
+type graphmlData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphmlNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphmlData `xml:"data"`
+}
+
+type graphmlEdge struct {
+	Source   string        `xml:"source,attr"`
+	Target   string        `xml:"target,attr"`
+	Directed string        `xml:"directed,attr,omitempty"`
+	Data     []graphmlData `xml:"data"`
+}
+
+type graphmlGraph struct {
+	ID          string        `xml:"id,attr,omitempty"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphmlNode `xml:"node"`
+	Edges       []graphmlEdge `xml:"edge"`
+}
+
+type graphmlDocument struct {
+	XMLName xml.Name       `xml:"graphml"`
+	Xmlns   string         `xml:"xmlns,attr,omitempty"`
+	Keys    []graphmlKey   `xml:"key"`
+	Graphs  []graphmlGraph `xml:"graph"`
+}
+
+var graphmlKeys = []graphmlKey{
+	{ID: "level", For: "node", Name: "level", Type: "int"},
+	{ID: "label", For: "node", Name: "label", Type: "string"},
+	{ID: "x", For: "node", Name: "x", Type: "double"},
+	{ID: "y", For: "node", Name: "y", Type: "double"},
+	{ID: "start", For: "node", Name: "start", Type: "boolean"},
+	{ID: "end", For: "node", Name: "end", Type: "boolean"},
+	{ID: "hide", For: "node", Name: "hide", Type: "boolean"},
+	{ID: "count", For: "edge", Name: "count", Type: "int", Default: "1"},
+	{ID: "from", For: "edge", Name: "from", Type: "int"},
+}
+
+func (this GraphmlCodec) Encode(w io.Writer, puzzle *Puzzle) error {
+	doc := graphmlDocument{
+		Xmlns:  "http://graphml.graphdrawing.org/xmlns",
+		Keys:   graphmlKeys,
+		Graphs: []graphmlGraph{{ID: "puzzle", EdgeDefault: "undirected"}},
+	}
+	g := &doc.Graphs[0]
+
+	declared := make(map[int]struct{}, len(puzzle.Points))
+	for _, p := range puzzle.Points {
+		declared[p.ID] = struct{}{}
+		n := graphmlNode{ID: strconv.Itoa(p.ID)}
+		if p.Level != 0 {
+			n.Data = append(n.Data, graphmlData{"level", strconv.Itoa(p.Level)})
+		}
+		if p.Label != "" {
+			n.Data = append(n.Data, graphmlData{"label", p.Label})
+		}
+		if p.HasPosition() {
+			n.Data = append(n.Data, graphmlData{"x", formatFloat(*p.X)}, graphmlData{"y", formatFloat(*p.Y)})
+		}
+		for _, flag := range []struct {
+			key string
+			set bool
+		}{{"start", p.Start}, {"end", p.End}, {"hide", p.Hide}} {
+			if flag.set {
+				n.Data = append(n.Data, graphmlData{flag.key, "true"})
+			}
+		}
+		g.Nodes = append(g.Nodes, n)
+	}
+	// GraphML requires every edge endpoint to be declared.
+	for _, e := range puzzle.Edges {
+		for _, id := range []int{int(e.PointA), int(e.PointB)} {
+			if _, exists := declared[id]; !exists {
+				declared[id] = struct{}{}
+				g.Nodes = append(g.Nodes, graphmlNode{ID: strconv.Itoa(id)})
+			}
+		}
+	}
+
+	for _, e := range puzzle.Edges {
+		ge := graphmlEdge{Source: strconv.Itoa(int(e.PointA)), Target: strconv.Itoa(int(e.PointB))}
+		if e.Count != 1 {
+			ge.Data = append(ge.Data, graphmlData{"count", strconv.Itoa(int(e.Count))})
+		}
+		if e.Direction.Unidirectional {
+			ge.Directed = "true"
+			ge.Data = append(ge.Data, graphmlData{"from", strconv.Itoa(int(e.Direction.From))})
+		}
+		g.Edges = append(g.Edges, ge)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func (this GraphmlCodec) Decode(data []byte) (*Puzzle, error) {
+	var doc graphmlDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("graphml: %w", err)
+	}
+	if len(doc.Graphs) != 1 {
+		return nil, fmt.Errorf("graphml: expected one graph, got %d", len(doc.Graphs))
+	}
+	g := doc.Graphs[0]
+
+	// Map key ids to attribute names, and collect defaults.
+	names := map[string]string{}
+	defaults := map[string]map[string]string{"node": {}, "edge": {}}
+	for _, k := range doc.Keys {
+		names[k.ID] = k.Name
+		if k.Default != "" && defaults[k.For] != nil {
+			defaults[k.For][k.Name] = strings.TrimSpace(k.Default)
+		}
+	}
+	values := func(kind string, data []graphmlData) map[string]string {
+		v := make(map[string]string, len(data))
+		for name, value := range defaults[kind] {
+			v[name] = value
+		}
+		for _, d := range data {
+			name, ok := names[d.Key]
+			if !ok {
+				name = d.Key
+			}
+			v[name] = strings.TrimSpace(d.Value)
+		}
+		return v
+	}
+
+	ids := newPointNumbering()
+	withPoints := false
+	for _, n := range g.Nodes {
+		ids.add(n.ID)
+		if len(n.Data) > 0 {
+			withPoints = true
+		}
+	}
+	for _, e := range g.Edges {
+		ids.add(e.Source)
+		ids.add(e.Target)
+	}
+	ids.assign()
+	withPoints = withPoints || ids.named
+
+	var points []Point
+	if withPoints {
+		for _, n := range g.Nodes {
+			p, err := pointFromValues(ids.id(n.ID), n.ID, values("node", n.Data))
+			if err != nil {
+				return nil, fmt.Errorf("graphml: node %s: %w", n.ID, err)
+			}
+			points = append(points, p)
+		}
+	}
+
+	var edges []Edge
+	for _, ge := range g.Edges {
+		a, b := ids.id(ge.Source), ids.id(ge.Target)
+		if a < 0 || a > 0xffff || b < 0 || b > 0xffff {
+			return nil, fmt.Errorf("graphml: edge %s-%s: points on edges must be between 0 and 65535", ge.Source, ge.Target)
+		}
+		e := Edge{PointA: uint16(a), PointB: uint16(b), Count: 1}
+		v := values("edge", ge.Data)
+		if c, ok := v["count"]; ok {
+			n, err := strconv.ParseUint(c, 10, 16)
+			if err != nil || n == 0 {
+				return nil, fmt.Errorf("graphml: edge %s-%s: invalid count %q", ge.Source, ge.Target, c)
+			}
+			e.Count = uint16(n)
+		}
+		directed := g.EdgeDefault == "directed"
+		if ge.Directed != "" {
+			directed = ge.Directed == "true"
+		}
+		if directed {
+			e.Direction = Direction{From: e.PointA, To: e.PointB, Unidirectional: true}
+			if v["from"] == ge.Target {
+				e.Direction = Direction{From: e.PointB, To: e.PointA, Unidirectional: true}
+			}
+		}
+		edges = append(edges, e)
+	}
+
+	np := NewPuzzle(edges)
+	np.Points = points
+	return np, nil
+}
+
+// pointNumbering gives numbers to point names: numeric names keep their
+// value and the others follow the highest number, in order of appearance.
+// All names are added before assign numbers them.
+type pointNumbering struct {
+	order   []string
+	numbers map[string]int
+	named   bool
+}
+
+func newPointNumbering() *pointNumbering {
+	return &pointNumbering{numbers: map[string]int{}}
+}
+
+func (this *pointNumbering) add(name string) {
+	if _, exists := this.numbers[name]; exists {
+		return
+	}
+	this.order = append(this.order, name)
+	this.numbers[name] = 0
+}
+
+func (this *pointNumbering) assign() {
+	next := 1
+	var pending []string
+	for _, name := range this.order {
+		if n, err := strconv.Atoi(name); err == nil {
+			this.numbers[name] = n
+			if n >= next {
+				next = n + 1
+			}
+		} else {
+			pending = append(pending, name)
+		}
+	}
+	for _, name := range pending {
+		this.numbers[name] = next
+		next++
+		this.named = true
+	}
+}
+
+func (this *pointNumbering) id(name string) int {
+	return this.numbers[name]
+}
+
+// pointFromValues builds a point from named attribute values shared by the
+// GraphML, CSV and DOT codecs.
+func pointFromValues(id int, name string, v map[string]string) (Point, error) {
+	p := Point{ID: id}
+	if strconv.Itoa(id) != name {
+		p.Label = name
+	}
+	var err error
+	if s := v["level"]; s != "" {
+		if p.Level, err = strconv.Atoi(s); err != nil {
+			return p, fmt.Errorf("invalid level %q", s)
+		}
+	}
+	if s, ok := v["label"]; ok && s != "" {
+		p.Label = s
+	}
+	if v["x"] != "" || v["y"] != "" {
+		x, errX := strconv.ParseFloat(v["x"], 64)
+		y, errY := strconv.ParseFloat(v["y"], 64)
+		if errX != nil || errY != nil {
+			return p, fmt.Errorf("invalid position %q,%q", v["x"], v["y"])
+		}
+		p.X, p.Y = &x, &y
+	}
+	for _, flag := range []struct {
+		key    string
+		target *bool
+	}{{"start", &p.Start}, {"end", &p.End}, {"hide", &p.Hide}} {
+		if s := v[flag.key]; s != "" {
+			if *flag.target, err = strconv.ParseBool(s); err != nil {
+				return p, fmt.Errorf("invalid %s %q", flag.key, s)
+			}
+		}
+	}
+	return p, nil
+}
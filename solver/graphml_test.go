@@ -0,0 +1,72 @@
+package solver
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGraphmlRoundTripBundledPuzzles(t *testing.T) {
+	for name, p := range loadBundledPuzzles(t) {
+		var b bytes.Buffer
+		if err := (GraphmlCodec{}).Encode(&b, p); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := GraphmlCodec{}.Decode(b.Bytes())
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", name, err, b.String())
+		}
+		assertSamePuzzle(t, name, p, got)
+	}
+}
+
+func TestGraphmlDecodeForeignKeys(t *testing.T) {
+	data := `<?xml version="1.0"?>
+<graphml>
+  <key id="d0" for="node" attr.name="label" attr.type="string"/>
+  <key id="d1" for="edge" attr.name="count" attr.type="int"><default>1</default></key>
+  <graph edgedefault="directed">
+    <node id="a"><data key="d0">Top</data></node>
+    <node id="b"/>
+    <edge source="a" target="b"><data key="d1">2</data></edge>
+    <edge source="b" target="a" directed="false"/>
+  </graph>
+</graphml>`
+	p, err := GraphmlCodec{}.Decode([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Points) != 2 || p.Points[0].ID != 1 || p.Points[0].Label != "Top" || p.Points[1].Label != "b" {
+		t.Errorf("Unexpected points %+v", p.Points)
+	}
+	expected := []Edge{
+		{PointA: 1, PointB: 2, Count: 2, Direction: Direction{From: 1, To: 2, Unidirectional: true}},
+		{PointA: 2, PointB: 1, Count: 1},
+	}
+	assertSamePuzzle(t, "foreign", NewPuzzle(expected), &Puzzle{Edges: p.Edges, count: p.count})
+}
+
+func TestGraphmlDecodeErrors(t *testing.T) {
+	tests := map[string]string{
+		"not xml":   "graph {",
+		"no graph":  "<graphml></graphml>",
+		"bad count": `<graphml><key id="c" for="edge" attr.name="count"/><graph><edge source="1" target="2"><data key="c">0</data></edge></graph></graphml>`,
+		"bad point": `<graphml><graph><edge source="1" target="70000"/></graph></graphml>`,
+	}
+	for name, data := range tests {
+		_, err := GraphmlCodec{}.Decode([]byte(data))
+		if err == nil || !strings.HasPrefix(err.Error(), "graphml: ") {
+			t.Errorf("%s: expected graphml error, got %v", name, err)
+		}
+	}
+}
+
+func TestNewPuzzleFromBytesSniffsGraphml(t *testing.T) {
+	p, err := NewPuzzleFromBytes([]byte(`<graphml><graph><edge source="1" target="2"/></graph></graphml>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Edges) != 1 || p.Edges[0].PointB != 2 {
+		t.Errorf("Unexpected edges %+v", p.Edges)
+	}
+}
@@ -0,0 +1,43 @@
+package solver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CanonicalHash identifies the solutions of a puzzle: puzzles with the
+// same hash have the same solutions. It does not depend on the order of
+// the edges or of their endpoints, nor on the data only used to draw the
+// points (labels, levels, coordinates, hidden spacers). Point IDs are
+// kept, as solutions are written with them.
+func (this *Puzzle) CanonicalHash() string {
+	var lines []string
+	for _, e := range this.Edges {
+		if e.Count == 0 {
+			continue
+		}
+		if e.Direction.Unidirectional {
+			lines = append(lines, fmt.Sprintf("%d>%d x%d", e.Direction.From, e.Direction.To, e.Count))
+			continue
+		}
+		a, b := e.PointA, e.PointB
+		if a > b {
+			a, b = b, a
+		}
+		lines = append(lines, fmt.Sprintf("%d-%d x%d", a, b, e.Count))
+	}
+	for _, p := range this.Points {
+		if p.Start {
+			lines = append(lines, fmt.Sprintf("start %d", p.ID))
+		}
+		if p.End {
+			lines = append(lines, fmt.Sprintf("end %d", p.ID))
+		}
+	}
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,38 @@
+package solver
+
+import "testing"
+
+func TestCanonicalHash(t *testing.T) {
+	base := puzzleFromText(t, "1-2\n2-3 x2\n3>1\npoint 1 label=\"top\" level=1")
+	same := []string{
+		"1<3\n3-2 x2\n2-1",
+		"2-1\n3>1\n2-3 x2\npoint 2 level=4 label=\"other\"",
+	}
+	for _, text := range same {
+		if got := puzzleFromText(t, text).CanonicalHash(); got != base.CanonicalHash() {
+			t.Errorf("Expected %q to hash like the base puzzle", text)
+		}
+	}
+	different := []string{
+		"1-2\n2-3\n3>1",
+		"1-2\n2-3 x2\n1>3",
+		"1-2\n2-3 x2\n3-1",
+		"1-2\n2-3 x2\n3>1\nstart 1",
+		"1-2\n2-3 x2\n3>1\nend 1",
+		"1-4\n4-3 x2\n3>1",
+	}
+	for _, text := range different {
+		if puzzleFromText(t, text).CanonicalHash() == base.CanonicalHash() {
+			t.Errorf("Expected %q to hash differently", text)
+		}
+	}
+}
+
+func puzzleFromText(t *testing.T, text string) *Puzzle {
+	t.Helper()
+	p, err := TextCodec{}.Decode([]byte(text))
+	if err != nil {
+		t.Fatalf("Decode %q: %v", text, err)
+	}
+	return p
+}
@@ -0,0 +1,82 @@
+package solver
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Point is a vertex of a puzzle as stored in the puzzle files. The solver
+// walks Edges, only reading the Start and End markers of the points; the
+// rest is what renderers need to draw them. Negative IDs are used by some
+// levels as hidden spacers for the layout.
+type Point struct {
+	ID    int      `json:"Point"`
+	Level int      `json:"Level"`
+	Label string   `json:"Label,omitempty"`
+	X     *float64 `json:"X,omitempty"`
+	Y     *float64 `json:"Y,omitempty"`
+	Start bool     `json:"Start,omitempty"`
+	End   bool     `json:"End,omitempty"`
+	Hide  bool     `json:"Hide,omitempty"`
+}
+
+// UnmarshalJSON accepts the boolean markers either as JSON booleans or as
+// numbers, since older puzzle files write "Hide": 1.
+func (this *Point) UnmarshalJSON(data []byte) error {
+	type plain Point
+	var raw struct {
+		plain
+		Start json.RawMessage `json:"Start"`
+		End   json.RawMessage `json:"End"`
+		Hide  json.RawMessage `json:"Hide"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*this = Point(raw.plain)
+
+	var err error
+	if this.Start, err = parseFlag(raw.Start); err != nil {
+		return fmt.Errorf("point %d: Start: %w", this.ID, err)
+	}
+	if this.End, err = parseFlag(raw.End); err != nil {
+		return fmt.Errorf("point %d: End: %w", this.ID, err)
+	}
+	if this.Hide, err = parseFlag(raw.Hide); err != nil {
+		return fmt.Errorf("point %d: Hide: %w", this.ID, err)
+	}
+	return nil
+}
+
+// HasPosition reports whether the point has explicit coordinates.
+func (this *Point) HasPosition() bool {
+	return this.X != nil && this.Y != nil
+}
+
+func (this *Point) Copy() Point {
+	c := *this
+	if this.X != nil {
+		x := *this.X
+		c.X = &x
+	}
+	if this.Y != nil {
+		y := *this.Y
+		c.Y = &y
+	}
+	return c
+}
+
+func parseFlag(raw json.RawMessage) (bool, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return false, nil
+	}
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return b, nil
+	}
+	var n float64
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return false, fmt.Errorf("expected boolean or number, got %s", raw)
+	}
+	return n != 0, nil
+}
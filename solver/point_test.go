@@ -0,0 +1,74 @@
+package solver
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPointUnmarshalNumericFlags(t *testing.T) {
+	var p Point
+	err := json.Unmarshal([]byte(`{"Point": -1, "Level": 2, "Hide": 1}`), &p)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if p.ID != -1 {
+		t.Errorf("Expected ID -1, got %d", p.ID)
+	}
+	if p.Level != 2 {
+		t.Errorf("Expected Level 2, got %d", p.Level)
+	}
+	if !p.Hide {
+		t.Error("Expected Hide to be true")
+	}
+	if p.Start || p.End {
+		t.Error("Expected Start and End to default to false")
+	}
+}
+
+func TestPointUnmarshalFullPoint(t *testing.T) {
+	var p Point
+	err := json.Unmarshal([]byte(`{"Point": 3, "Level": 1, "Label": "top", "X": 1.5, "Y": 2, "Start": true, "End": false}`), &p)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if p.Label != "top" {
+		t.Errorf("Expected Label 'top', got '%s'", p.Label)
+	}
+	if !p.HasPosition() || *p.X != 1.5 || *p.Y != 2 {
+		t.Errorf("Expected position (1.5, 2), got %v, %v", p.X, p.Y)
+	}
+	if !p.Start || p.End {
+		t.Errorf("Expected Start=true End=false, got %v %v", p.Start, p.End)
+	}
+}
+
+func TestPointUnmarshalInvalidFlag(t *testing.T) {
+	var p Point
+	err := json.Unmarshal([]byte(`{"Point": 1, "Hide": "yes"}`), &p)
+	if err == nil {
+		t.Error("Expected error for string Hide flag")
+	}
+}
+
+func TestPointMarshalOmitsEmptyFields(t *testing.T) {
+	p := Point{ID: 1, Level: 1}
+	b, _ := json.Marshal(p)
+	expected := `{"Point":1,"Level":1}`
+	if string(b) != expected {
+		t.Errorf("Expected %s, got %s", expected, string(b))
+	}
+}
+
+func TestPointCopy(t *testing.T) {
+	x, y := 1.0, 2.0
+	p := Point{ID: 1, X: &x, Y: &y}
+	c := p.Copy()
+
+	*c.X = 5
+	if *p.X != 1 {
+		t.Error("Original point was modified")
+	}
+	if *c.Y != 2 {
+		t.Errorf("Expected Y 2, got %v", *c.Y)
+	}
+}
@@ -3,31 +3,148 @@ package solver
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 type SolutionPrinter interface {
-	Print(solutions *Solutions)
+	Print(w io.Writer, solutions *Solutions) error
+}
+
+// printers maps -output names to printer constructors. Printers that show
+// edges need the puzzle the solutions belong to.
+var printers = map[string]func(puzzle *Puzzle) SolutionPrinter{
+	"clean":    func(*Puzzle) SolutionPrinter { return CleanPrinter{} },
+	"json":     func(*Puzzle) SolutionPrinter { return JsonPrinter{} },
+	"ndjson":   func(*Puzzle) SolutionPrinter { return NdjsonPrinter{} },
+	"csv":      func(p *Puzzle) SolutionPrinter { return CsvPrinter{Puzzle: p} },
+	"markdown": func(*Puzzle) SolutionPrinter { return MarkdownPrinter{} },
+	"arrow":    func(p *Puzzle) SolutionPrinter { return ArrowPrinter{Puzzle: p} },
+}
+
+// RegisterPrinter makes a printer available under name, replacing any
+// printer already registered with it.
+func RegisterPrinter(name string, create func(puzzle *Puzzle) SolutionPrinter) {
+	printers[name] = create
+}
+
+// NewPrinter returns the printer registered under name for puzzle.
+func NewPrinter(name string, puzzle *Puzzle) (SolutionPrinter, error) {
+	create, ok := printers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown output %q, expected one of %s", name, strings.Join(PrinterNames(), ", "))
+	}
+	return create(puzzle), nil
+}
+
+// PrinterNames lists the registered printers in alphabetical order.
+func PrinterNames() []string {
+	names := make([]string, 0, len(printers))
+	for name := range printers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 type JsonPrinter struct{}
 
-func (this JsonPrinter) Print(s *Solutions) {
-	a, _ := json.Marshal(s)
-	fmt.Println(string(a))
+func (this JsonPrinter) Print(w io.Writer, s *Solutions) error {
+	a, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(a))
+	return err
+}
+
+// NdjsonPrinter writes every solution as a JSON array on its own line.
+type NdjsonPrinter struct{}
+
+func (this NdjsonPrinter) Print(w io.Writer, s *Solutions) error {
+	enc := json.NewEncoder(w)
+	for _, v := range *s {
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 type CleanPrinter struct{}
 
-func (this CleanPrinter) Print(s *Solutions) {
+func (this CleanPrinter) Print(w io.Writer, s *Solutions) error {
 	for _, v := range *s {
-		l := len(v)
-		for k, v1 := range v {
-			if k < l-1 {
-				fmt.Printf("%v - ", v1)
-			} else {
-				fmt.Printf("%v", v1)
+		if _, err := fmt.Fprintln(w, joinSolution(v, " - ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarkdownPrinter writes a table with the index and path of every solution.
+type MarkdownPrinter struct{}
+
+func (this MarkdownPrinter) Print(w io.Writer, s *Solutions) error {
+	if _, err := fmt.Fprint(w, "| # | Solution |\n|---|----------|\n"); err != nil {
+		return err
+	}
+	for k, v := range *s {
+		if _, err := fmt.Fprintf(w, "| %d | %s |\n", k, joinSolution(v, " - ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ArrowPrinter writes solutions as 1→2→3, using ⇒ for the steps that draw a
+// directed edge. Without a puzzle every step is written with →.
+type ArrowPrinter struct {
+	Puzzle *Puzzle
+}
+
+func (this ArrowPrinter) Print(w io.Writer, s *Solutions) error {
+	for k, v := range *s {
+		var steps []int
+		if this.Puzzle != nil {
+			var err error
+			if steps, err = solutionEdges(this.Puzzle, v); err != nil {
+				return fmt.Errorf("solution %d: %w", k, err)
 			}
 		}
-		fmt.Println("")
+		var b strings.Builder
+		for i, point := range v {
+			if i > 0 {
+				if steps != nil && this.Puzzle.Edges[steps[i-1]].Direction.Unidirectional {
+					b.WriteString("⇒")
+				} else {
+					b.WriteString("→")
+				}
+			}
+			b.WriteString(strconv.Itoa(int(point)))
+		}
+		if _, err := fmt.Fprintln(w, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CsvPrinter prints solutions as written by WriteSolutionsCsv.
+type CsvPrinter struct {
+	Puzzle *Puzzle
+}
+
+func (this CsvPrinter) Print(w io.Writer, s *Solutions) error {
+	return WriteSolutionsCsv(w, this.Puzzle, s)
+}
+
+func joinSolution(solution Solution, sep string) string {
+	parts := make([]string, len(solution))
+	for k, v := range solution {
+		parts[k] = strconv.Itoa(int(v))
 	}
+	return strings.Join(parts, sep)
 }
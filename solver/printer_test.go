@@ -40,7 +40,7 @@ func TestJsonPrinterEmptySolutions(t *testing.T) {
 	printer := JsonPrinter{}
 	solutions := Solutions{}
 
-	printer.Print(&solutions)
+	printer.Print(os.Stdout, &solutions)
 	output := helper.CaptureOutput()
 
 	if output != "[]\n" {
@@ -57,7 +57,7 @@ func TestJsonPrinterSingleSolution(t *testing.T) {
 	solution := Solution{1, 2, 3}
 	solutions := Solutions{solution}
 
-	printer.Print(&solutions)
+	printer.Print(os.Stdout, &solutions)
 	output := helper.CaptureOutput()
 
 	// Verify valid JSON
@@ -84,7 +84,7 @@ func TestJsonPrinterMultipleSolutions(t *testing.T) {
 	solution2 := Solution{3, 2, 1}
 	solutions := Solutions{solution1, solution2}
 
-	printer.Print(&solutions)
+	printer.Print(os.Stdout, &solutions)
 	output := helper.CaptureOutput()
 
 	// Verify valid JSON
@@ -106,7 +106,7 @@ func TestCleanPrinterEmptySolutions(t *testing.T) {
 	printer := CleanPrinter{}
 	solutions := Solutions{}
 
-	printer.Print(&solutions)
+	printer.Print(os.Stdout, &solutions)
 	output := helper.CaptureOutput()
 
 	if output != "" {
@@ -123,7 +123,7 @@ func TestCleanPrinterSingleSolution(t *testing.T) {
 	solution := Solution{1, 2, 3}
 	solutions := Solutions{solution}
 
-	printer.Print(&solutions)
+	printer.Print(os.Stdout, &solutions)
 	output := helper.CaptureOutput()
 
 	expected := "1 - 2 - 3\n"
@@ -142,7 +142,7 @@ func TestCleanPrinterMultipleSolutions(t *testing.T) {
 	solution2 := Solution{3, 2, 1}
 	solutions := Solutions{solution1, solution2}
 
-	printer.Print(&solutions)
+	printer.Print(os.Stdout, &solutions)
 	output := helper.CaptureOutput()
 
 	expected := "1 - 2 - 3\n3 - 2 - 1\n"
@@ -160,7 +160,7 @@ func TestCleanPrinterSingleNodeSolution(t *testing.T) {
 	solution := Solution{5}
 	solutions := Solutions{solution}
 
-	printer.Print(&solutions)
+	printer.Print(os.Stdout, &solutions)
 	output := helper.CaptureOutput()
 
 	expected := "5\n"
@@ -178,7 +178,7 @@ func TestCleanPrinterLongPath(t *testing.T) {
 	solution := Solution{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
 	solutions := Solutions{solution}
 
-	printer.Print(&solutions)
+	printer.Print(os.Stdout, &solutions)
 	output := helper.CaptureOutput()
 
 	expected := "1 - 2 - 3 - 4 - 5 - 6 - 7 - 8 - 9 - 10\n"
@@ -222,6 +222,10 @@ func TestPrinterInterface(t *testing.T) {
 	// This test ensures both printers implement the interface
 	var _ SolutionPrinter = JsonPrinter{}
 	var _ SolutionPrinter = CleanPrinter{}
+	var _ SolutionPrinter = NdjsonPrinter{}
+	var _ SolutionPrinter = MarkdownPrinter{}
+	var _ SolutionPrinter = ArrowPrinter{}
+	var _ SolutionPrinter = CsvPrinter{}
 
 	// If this compiles, the test passes
 }
@@ -248,3 +252,89 @@ func TestCleanPrinterFormatting(t *testing.T) {
 		t.Errorf("Expected %s, got %s", expected, buf.String())
 	}
 }
+
+func TestNdjsonPrinter(t *testing.T) {
+	var buf bytes.Buffer
+	solutions := Solutions{{1, 2, 3}, {3, 2, 1}}
+	if err := (NdjsonPrinter{}).Print(&buf, &solutions); err != nil {
+		t.Fatal(err)
+	}
+	expected := "[1,2,3]\n[3,2,1]\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestMarkdownPrinter(t *testing.T) {
+	var buf bytes.Buffer
+	solutions := Solutions{{1, 2, 3}, {3, 2, 1}}
+	if err := (MarkdownPrinter{}).Print(&buf, &solutions); err != nil {
+		t.Fatal(err)
+	}
+	expected := "| # | Solution |\n|---|----------|\n| 0 | 1 - 2 - 3 |\n| 1 | 3 - 2 - 1 |\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestArrowPrinter(t *testing.T) {
+	p := NewPuzzle([]Edge{
+		{PointA: 1, PointB: 2, Count: 1},
+		{PointA: 2, PointB: 3, Count: 1, Direction: Direction{From: 2, To: 3, Unidirectional: true}},
+	})
+	solutions := Solutions{{1, 2, 3}}
+
+	var buf bytes.Buffer
+	if err := (ArrowPrinter{Puzzle: p}).Print(&buf, &solutions); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "1→2⇒3\n" {
+		t.Errorf("Expected directed step marked, got %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := (ArrowPrinter{}).Print(&buf, &solutions); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "1→2→3\n" {
+		t.Errorf("Expected plain arrows without a puzzle, got %q", buf.String())
+	}
+
+	invalid := Solutions{{3, 2, 1}}
+	if err := (ArrowPrinter{Puzzle: p}).Print(&buf, &invalid); err == nil {
+		t.Error("Expected error for a step against the edge direction")
+	}
+}
+
+func TestCsvPrinterWithoutPuzzle(t *testing.T) {
+	var buf bytes.Buffer
+	solutions := Solutions{{1, 2, 3}}
+	if err := (CsvPrinter{}).Print(&buf, &solutions); err != nil {
+		t.Fatal(err)
+	}
+	expected := "solution,step,from,to,edge\n0,1,1,2,\n0,2,2,3,\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestNewPrinter(t *testing.T) {
+	for _, name := range []string{"clean", "json", "ndjson", "csv", "markdown", "arrow"} {
+		if _, err := NewPrinter(name, nil); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := NewPrinter("yaml", nil); err == nil {
+		t.Error("Expected error for unknown printer")
+	}
+
+	RegisterPrinter("test", func(*Puzzle) SolutionPrinter { return NdjsonPrinter{} })
+	defer delete(printers, "test")
+	printer, err := NewPrinter("test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := printer.(NdjsonPrinter); !ok {
+		t.Errorf("Expected registered printer, got %T", printer)
+	}
+}
@@ -0,0 +1,280 @@
+package solver
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// maxReferenceEdges bounds the puzzles given to bruteForceSolutions, which
+// tries every permutation of the edges to draw.
+const maxReferenceEdges = 7
+
+// bruteForceSolutions is the reference the solver is checked against: it
+// lists every ordering of the edge multiset (an edge appears Count times),
+// lays each one out in both directions and keeps the point sequences that
+// checkSolution accepts, sorted and without repetitions.
+func bruteForceSolutions(p *Puzzle) []string {
+	var strokes []Edge
+	for _, e := range p.Edges {
+		for i := uint16(0); i < e.Count; i++ {
+			strokes = append(strokes, e)
+		}
+	}
+	if len(strokes) == 0 || len(strokes) > maxReferenceEdges {
+		panic(fmt.Sprintf("bruteForceSolutions: %d edges to draw", len(strokes)))
+	}
+
+	found := map[string]struct{}{}
+	order := make([]int, len(strokes))
+	for k := range order {
+		order[k] = k
+	}
+	permute(order, 0, func(order []int) {
+		first := strokes[order[0]]
+		for _, start := range []uint16{first.PointA, first.PointB} {
+			solution := Solution{start}
+			for _, k := range order {
+				at := solution[len(solution)-1]
+				e := strokes[k]
+				switch at {
+				case e.PointA:
+					solution = append(solution, e.PointB)
+				case e.PointB:
+					solution = append(solution, e.PointA)
+				}
+			}
+			if len(solution) == len(strokes)+1 && checkSolution(p, solution) == nil {
+				found[joinSolution(solution, "-")] = struct{}{}
+			}
+		}
+	})
+
+	solutions := make([]string, 0, len(found))
+	for s := range found {
+		solutions = append(solutions, s)
+	}
+	sort.Strings(solutions)
+	return solutions
+}
+
+func permute(order []int, k int, visit func([]int)) {
+	if k == len(order) {
+		visit(order)
+		return
+	}
+	for i := k; i < len(order); i++ {
+		order[k], order[i] = order[i], order[k]
+		permute(order, k+1, visit)
+		order[k], order[i] = order[i], order[k]
+	}
+}
+
+// checkSolution tells whether solution draws every edge of p exactly Count
+// times, only along its direction, and starts and ends on allowed points.
+// It shares no code with the solver.
+func checkSolution(p *Puzzle, solution Solution) error {
+	left := make([]int, len(p.Edges))
+	total := 0
+	for k, e := range p.Edges {
+		left[k] = int(e.Count)
+		total += int(e.Count)
+	}
+	if len(solution) != total+1 {
+		return fmt.Errorf("%d steps for %d edges", len(solution)-1, total)
+	}
+	for i := 1; i < len(solution); i++ {
+		from, to := solution[i-1], solution[i]
+		// A repeated edge drawn in its direction is used before an
+		// undirected one, which is never worse.
+		drawn := -1
+		for k, e := range p.Edges {
+			if left[k] == 0 || !(e.PointA == from && e.PointB == to || e.PointA == to && e.PointB == from) {
+				continue
+			}
+			if !e.Direction.Unidirectional {
+				if drawn < 0 {
+					drawn = k
+				}
+			} else if e.Direction.From == from {
+				drawn = k
+				break
+			}
+		}
+		if drawn < 0 {
+			return fmt.Errorf("step %d: no edge left from %d to %d", i, from, to)
+		}
+		left[drawn]--
+	}
+
+	var starts, ends []int
+	for _, point := range p.Points {
+		if point.Start {
+			starts = append(starts, point.ID)
+		}
+		if point.End {
+			ends = append(ends, point.ID)
+		}
+	}
+	if !containsOrEmpty(starts, int(solution[0])) {
+		return fmt.Errorf("starts on %d, expected one of %v", solution[0], starts)
+	}
+	if !containsOrEmpty(ends, int(solution[len(solution)-1])) {
+		return fmt.Errorf("ends on %d, expected one of %v", solution[len(solution)-1], ends)
+	}
+	return nil
+}
+
+func containsOrEmpty(ids []int, id int) bool {
+	if len(ids) == 0 {
+		return true
+	}
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// randomPuzzle returns a puzzle of up to maxReferenceEdges edges to draw
+// between at most points points. Edges join different pairs of points, a
+// quarter of them are directed, and points are sometimes marked Start or End.
+func randomPuzzle(r *rand.Rand, points int) *Puzzle {
+	type pair struct{ a, b uint16 }
+	used := map[pair]bool{}
+	var edges []Edge
+	total := 0
+	wanted := 1 + r.Intn(maxReferenceEdges)
+	for attempts := 0; total < wanted && attempts < 50; attempts++ {
+		a, b := uint16(1+r.Intn(points)), uint16(1+r.Intn(points))
+		if a == b || used[pair{a, b}] || used[pair{b, a}] {
+			continue
+		}
+		used[pair{a, b}] = true
+		e := Edge{PointA: a, PointB: b, Count: 1}
+		if total+2 <= wanted && r.Intn(4) == 0 {
+			e.Count = 2
+		}
+		if r.Intn(4) == 0 {
+			e.Direction = Direction{From: a, To: b, Unidirectional: true}
+			if r.Intn(2) == 0 {
+				e.Direction = Direction{From: b, To: a, Unidirectional: true}
+			}
+		}
+		total += int(e.Count)
+		edges = append(edges, e)
+	}
+	if len(edges) == 0 {
+		edges = []Edge{{PointA: 1, PointB: 2, Count: 1}}
+	}
+
+	p := NewPuzzle(edges)
+	if r.Intn(5) == 0 {
+		for id := 1; id <= points; id++ {
+			point := Point{ID: id, Start: r.Intn(3) == 0, End: r.Intn(3) == 0}
+			p.Points = append(p.Points, point)
+		}
+	}
+	return p
+}
+
+func sortedSolutions(solutions *Solutions) []string {
+	lines := make([]string, len(*solutions))
+	for k, s := range *solutions {
+		lines[k] = joinSolution(s, "-")
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// checkSolverProperties compares the solver with the reference on p and
+// checks the invariants every result must keep.
+func checkSolverProperties(t *testing.T, p *Puzzle) {
+	t.Helper()
+	solutions := Solve(p)
+	got := sortedSolutions(solutions)
+	expected := bruteForceSolutions(p)
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Fatalf("Solve differs from the reference on %+v %+v\nexpected %v\ngot      %v", p.Edges, p.Points, expected, got)
+	}
+
+	if n := GetNumberOfSolutions(p); n != len(*solutions) {
+		t.Errorf("GetNumberOfSolutions is %d, Solve found %d on %+v", n, len(*solutions), p.Edges)
+	}
+	seen := map[string]bool{}
+	for _, s := range got {
+		if seen[s] {
+			t.Errorf("Solution %s listed twice on %+v", s, p.Edges)
+		}
+		seen[s] = true
+	}
+	for _, s := range *solutions {
+		if err := checkSolution(p, s); err != nil {
+			t.Errorf("Invalid solution %v on %+v: %v", s, p.Edges, err)
+		}
+	}
+
+	// Without directions or markers, a solution walked backwards is one too.
+	for _, e := range p.Edges {
+		if e.Direction.Unidirectional {
+			return
+		}
+	}
+	if len(p.Points) > 0 {
+		return
+	}
+	for _, s := range *solutions {
+		reversed := make(Solution, len(s))
+		for k, v := range s {
+			reversed[len(s)-1-k] = v
+		}
+		if !seen[joinSolution(reversed, "-")] {
+			t.Errorf("Reverse of %v missing on %+v", s, p.Edges)
+		}
+	}
+}
+
+func TestBruteForceSolutions(t *testing.T) {
+	triangle := NewPuzzle([]Edge{
+		{PointA: 1, PointB: 2, Count: 1},
+		{PointA: 2, PointB: 3, Count: 1},
+		{PointA: 3, PointB: 1, Count: 1},
+	})
+	if n := len(bruteForceSolutions(triangle)); n != 6 {
+		t.Errorf("Expected 6 solutions for the triangle, got %d", n)
+	}
+
+	directed := NewPuzzle([]Edge{
+		{PointA: 1, PointB: 2, Count: 1, Direction: Direction{From: 2, To: 1, Unidirectional: true}},
+		{PointA: 2, PointB: 3, Count: 2},
+	})
+	expected := []string{"2-3-2-1"}
+	if got := bruteForceSolutions(directed); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestSolverMatchesReferenceOnSmallBundledPuzzles(t *testing.T) {
+	for name, p := range loadBundledPuzzles(t) {
+		if int(p.count) > maxReferenceEdges {
+			continue
+		}
+		t.Run(name, func(t *testing.T) { checkSolverProperties(t, p) })
+	}
+}
+
+func TestSolverMatchesReferenceOnRandomPuzzles(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	runs := 500
+	if testing.Short() {
+		runs = 50
+	}
+	for i := 0; i < runs; i++ {
+		checkSolverProperties(t, randomPuzzle(r, 2+r.Intn(4)))
+		if t.Failed() {
+			return
+		}
+	}
+}
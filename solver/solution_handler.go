@@ -31,3 +31,14 @@ func newSolutionCounter() *solutionCounter {
 func (this *solutionCounter) handleNewSolutionFound(path *[]uint16) {
 	this.count_solutions = this.count_solutions + 1
 }
+
+// solutionFinder keeps the first solution and stops its walker.
+type solutionFinder struct {
+	walker   walker
+	solution Solution
+}
+
+func (this *solutionFinder) handleNewSolutionFound(path *[]uint16) {
+	this.solution = append(Solution(nil), *path...)
+	this.walker.stopped = true
+}
@@ -1,9 +1,15 @@
 package solver
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 type Solution []uint16
@@ -54,8 +60,9 @@ func (this *Edge) canGoTo(pointFrom uint16, pointTo uint16) bool {
 }
 
 type Puzzle struct {
-	Edges []Edge
-	count uint16
+	Points []Point `json:",omitempty"`
+	Edges  []Edge
+	count  uint16
 }
 
 func NewPuzzle(edges []Edge) *Puzzle {
@@ -64,13 +71,59 @@ func NewPuzzle(edges []Edge) *Puzzle {
 	return &np
 }
 
+// NewPuzzleFromBytes reads a puzzle in any of the supported formats, telling
+// them apart by their content: JSON objects or edge lists, Graphviz DOT, or
+// else the compact text format.
 func NewPuzzleFromBytes(puzzle_bytes []byte) (*Puzzle, error) {
+	trimmed := bytes.TrimSpace(puzzle_bytes)
+	switch {
+	case len(trimmed) == 0 || trimmed[0] == '{' || trimmed[0] == '[':
+		return newPuzzleFromJson(trimmed)
+	case trimmed[0] == '<':
+		return GraphmlCodec{}.Decode(trimmed)
+	case looksLikeDot(trimmed):
+		return DotCodec{}.Decode(puzzle_bytes)
+	default:
+		return TextCodec{}.Decode(puzzle_bytes)
+	}
+}
+
+func newPuzzleFromJson(puzzle_bytes []byte) (*Puzzle, error) {
 	var p Puzzle
-	err := json.Unmarshal(puzzle_bytes, &p)
+	var err error
+	if len(puzzle_bytes) > 0 && puzzle_bytes[0] == '[' {
+		// Some puzzle files are a bare list of edges.
+		err = json.Unmarshal(puzzle_bytes, &p.Edges)
+	} else {
+		err = json.Unmarshal(puzzle_bytes, &p)
+	}
 	if err != nil {
 		return nil, err
 	}
-	return NewPuzzle(p.Edges), nil
+	np := NewPuzzle(p.Edges)
+	np.Points = p.Points
+	return np, nil
+}
+
+// looksLikeDot reports whether the first statement, past blank and comment
+// lines, opens a Graphviz graph.
+func looksLikeDot(data []byte) bool {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		if strings.HasPrefix(line, "/*") {
+			return true
+		}
+		words := strings.FieldsFunc(line, func(r rune) bool { return r == ' ' || r == '\t' || r == '{' })
+		if len(words) == 0 {
+			return false
+		}
+		word := strings.ToLower(words[0])
+		return word == "graph" || word == "digraph" || word == "strict"
+	}
+	return false
 }
 
 func countTotalEdges(edges *[]Edge) (total_count uint16) {
@@ -84,35 +137,127 @@ func countTotalEdges(edges *[]Edge) (total_count uint16) {
 func (this *Puzzle) Copy() Puzzle {
 	c := Puzzle{}
 	c.count = this.count
+	for _, v := range this.Points {
+		c.Points = append(c.Points, v.Copy())
+	}
 	for _, v := range this.Edges {
 		c.Edges = append(c.Edges, v.Copy())
 	}
 	return c
 }
 
+// GetPoint returns the point with the given ID, or nil when the puzzle does
+// not declare it.
+func (this *Puzzle) GetPoint(id int) *Point {
+	for k := range this.Points {
+		if this.Points[k].ID == id {
+			return &this.Points[k]
+		}
+	}
+	return nil
+}
+
+// Validate checks that the puzzle is well formed: every edge has a positive
+// count, directions refer to the edge's own endpoints and, when points are
+// declared, every edge endpoint is one of them.
+func (this *Puzzle) Validate() error {
+	if len(this.Edges) == 0 {
+		return errors.New("puzzle has no edges")
+	}
+	seen := make(map[int]struct{}, len(this.Points))
+	for _, p := range this.Points {
+		if _, exists := seen[p.ID]; exists {
+			return fmt.Errorf("duplicate point %d", p.ID)
+		}
+		seen[p.ID] = struct{}{}
+	}
+	for k, e := range this.Edges {
+		if e.PointA == e.PointB {
+			return fmt.Errorf("edge %d: loop on point %d", k, e.PointA)
+		}
+		if e.Count == 0 {
+			return fmt.Errorf("edge %d-%d: count must be positive", e.PointA, e.PointB)
+		}
+		if e.Direction.Unidirectional {
+			d := e.Direction
+			if !(d.From == e.PointA && d.To == e.PointB) && !(d.From == e.PointB && d.To == e.PointA) {
+				return fmt.Errorf("edge %d-%d: direction %d>%d does not match its endpoints", e.PointA, e.PointB, d.From, d.To)
+			}
+		}
+		if len(this.Points) > 0 {
+			for _, id := range []uint16{e.PointA, e.PointB} {
+				if _, exists := seen[int(id)]; !exists {
+					return fmt.Errorf("edge %d-%d: unknown point %d", e.PointA, e.PointB, id)
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func (this *Puzzle) isSolved() bool {
 	return this.count == 0
 }
 
+// listStartingPoints returns the points marked Start, or every point on an
+// edge when none is marked.
 func (this *Puzzle) listStartingPoints() []uint16 {
 	sp := make([]uint16, 0, len(this.Edges)*2)
 	for _, edge := range this.Edges {
-		sp = append(sp, edge.PointA)
-		sp = append(sp, edge.PointB)
+		if this.canStartAt(edge.PointA) {
+			sp = append(sp, edge.PointA)
+		}
+		if this.canStartAt(edge.PointB) {
+			sp = append(sp, edge.PointB)
+		}
 	}
 	sp = removeDuplicates(sp)
 	return sp
 }
 
+func (this *Puzzle) canStartAt(point uint16) bool {
+	return this.isMarked(point, func(p *Point) bool { return p.Start })
+}
+
+func (this *Puzzle) canEndAt(point uint16) bool {
+	return this.isMarked(point, func(p *Point) bool { return p.End })
+}
+
+// isMarked reports whether point passes marker, or true when no point of the
+// puzzle does.
+func (this *Puzzle) isMarked(point uint16, marker func(*Point) bool) bool {
+	any := false
+	for k := range this.Points {
+		if marker(&this.Points[k]) {
+			if this.Points[k].ID == int(point) {
+				return true
+			}
+			any = true
+		}
+	}
+	return !any
+}
+
+// getEdge returns an edge that can still be drawn from e1 to e2. When an
+// edge is repeated instead of using Count, a directed one is drawn before an
+// undirected one, which could still be drawn back later.
 func (this *Puzzle) getEdge(e1 *uint16, e2 *uint16) (*Edge, error) {
+	var undirected *Edge
 	for k, edge := range this.Edges {
-		if edge.PointA == *e1 && edge.PointB == *e2 {
-			return &this.Edges[k], nil
-		} else if edge.PointA == *e2 && edge.PointB == *e1 {
+		if !(edge.PointA == *e1 && edge.PointB == *e2 || edge.PointA == *e2 && edge.PointB == *e1) || !edge.canGoTo(*e1, *e2) {
+			continue
+		}
+		if edge.Direction.Unidirectional {
 			return &this.Edges[k], nil
 		}
+		if undirected == nil {
+			undirected = &this.Edges[k]
+		}
+	}
+	if undirected == nil {
+		return nil, errors.New("edge not found")
 	}
-	return nil, errors.New("edge not found")
+	return undirected, nil
 }
 
 func (this *Puzzle) visitEdge(edge *Edge) {
@@ -134,12 +279,98 @@ func (this *Puzzle) listPossibleEdgesToVisit(from *uint16) []uint16 {
 	return r
 }
 
-func findSolutions(puzzle *Puzzle, starting *uint16, path []uint16, solution_handler SolutionHandler, level uint16, wg *sync.WaitGroup) {
+// Stats describes the work done by a search.
+type Stats struct {
+	// Nodes is the number of points the depth-first search went through.
+	Nodes int64
+}
+
+// Errors of a search stopped by the limits of its Progress.
+var (
+	ErrNodeLimit     = errors.New("node limit reached")
+	ErrSolutionLimit = errors.New("solution limit reached")
+)
+
+// Progress follows a search while it runs. Its counters are updated by the
+// walkers and can be read from any goroutine; Nodes lags behind by up to
+// checkEvery nodes per walker until the search ends.
+type Progress struct {
+	Nodes      atomic.Int64
+	Solutions  atomic.Int64
+	Starts     atomic.Int64
+	StartsDone atomic.Int64
+	// OnSolution, when set, is called with every solution as it is found,
+	// from the goroutine of the walker that found it.
+	OnSolution func(Solution)
+	// MaxNodes and MaxSolutions, when positive, stop the search with
+	// ErrNodeLimit once it explored more nodes, as seen every checkEvery
+	// nodes, or with ErrSolutionLimit on finding one solution more.
+	MaxNodes     int64
+	MaxSolutions int64
+}
+
+// progressHandler reports the solutions of its walker to a Progress before
+// handing them to the handler of the search.
+type progressHandler struct {
+	SolutionHandler
+	progress *Progress
+	stop     context.CancelCauseFunc
+}
+
+func (this progressHandler) handleNewSolutionFound(path *[]uint16) {
+	n := this.progress.Solutions.Add(1)
+	if max := this.progress.MaxSolutions; max > 0 && n > max {
+		this.stop(ErrSolutionLimit)
+		return
+	}
+	if this.progress.OnSolution != nil {
+		this.progress.OnSolution(append(Solution(nil), *path...))
+	}
+	this.SolutionHandler.handleNewSolutionFound(path)
+}
+
+// walker is the state of the search from one starting point.
+type walker struct {
+	ctx      context.Context
+	stop     context.CancelCauseFunc
+	nodes    int64
+	stopped  bool
+	progress *Progress
+	// reported is the part of nodes already added to progress.
+	reported int64
+}
+
+// report adds the nodes visited since the last report to the progress.
+func (this *walker) report() {
+	if this.progress != nil {
+		total := this.progress.Nodes.Add(this.nodes - this.reported)
+		this.reported = this.nodes
+		if max := this.progress.MaxNodes; max > 0 && total > max {
+			this.stop(ErrNodeLimit)
+		}
+	}
+}
+
+// checkEvery is how many nodes a walker visits between checks of its
+// context.
+const checkEvery = 1 << 12
+
+func findSolutions(puzzle *Puzzle, starting *uint16, path []uint16, solution_handler SolutionHandler, w *walker) {
+	w.nodes++
+	if w.nodes%checkEvery == 0 {
+		w.report()
+		if w.ctx.Err() != nil {
+			w.stopped = true
+		}
+	}
+	if w.stopped {
+		return
+	}
 	path = append(path, *starting)
 	possible_edges := puzzle.listPossibleEdgesToVisit(starting)
 
 	if len(possible_edges) == 0 {
-		if puzzle.isSolved() {
+		if puzzle.isSolved() && puzzle.canEndAt(*starting) {
 			solution_handler.handleNewSolutionFound(&path)
 		}
 	} else {
@@ -152,68 +383,118 @@ func findSolutions(puzzle *Puzzle, starting *uint16, path []uint16, solution_han
 			previous_edgecount := edge.Count
 			puzzle.visitEdge(edge)
 
-			findSolutions(puzzle, &possible_edges[k], path, solution_handler, level+1, wg)
+			findSolutions(puzzle, &possible_edges[k], path, solution_handler, w)
 			edge.Count = previous_edgecount
 			puzzle.count = previous_count
 		}
-
-		if level == 1 {
-			wg.Done()
-		}
 	}
 }
 
-func Solve(puzzle *Puzzle) *Solutions {
+// search runs one walker per starting point in parallel, each reporting to
+// the handler made for it by newHandler. It stops early when ctx is done,
+// returning ctx.Err() along with the handlers filled so far.
+//
+// progress, when not nil, is kept up to date while the search runs, and
+// its limits stop it with their error instead.
+func search(ctx context.Context, puzzle *Puzzle, newHandler func() SolutionHandler, progress *Progress) ([]SolutionHandler, Stats, error) {
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
 	starting_points := puzzle.listStartingPoints()
-	arr_solution_storer := make([]solutionStorer, len(starting_points))
+	handlers := make([]SolutionHandler, len(starting_points))
+	walkers := make([]walker, len(starting_points))
 	var wg sync.WaitGroup
+	if progress != nil {
+		progress.Starts.Add(int64(len(starting_points)))
+	}
 
 	for k, _ := range starting_points {
-		arr_solution_storer[k] = *newSolutionStorer()
+		handlers[k] = newHandler()
+		walkers[k].ctx = ctx
+		walkers[k].stop = stop
+		walkers[k].progress = progress
+		var handler SolutionHandler = handlers[k]
+		if progress != nil {
+			handler = progressHandler{handler, progress, stop}
+		}
 		wg.Add(1)
-		path := make([]uint16, 0)
 		pc := puzzle.Copy()
-		go findSolutions(&pc, &starting_points[k], path, &arr_solution_storer[k], 1, &wg)
+		go func(k int) {
+			defer wg.Done()
+			findSolutions(&pc, &starting_points[k], make([]uint16, 0), handler, &walkers[k])
+			walkers[k].report()
+			if progress != nil {
+				progress.StartsDone.Add(1)
+			}
+		}(k)
 	}
-
 	wg.Wait()
+
+	var stats Stats
+	stopped := false
+	for _, w := range walkers {
+		stats.Nodes += w.nodes
+		stopped = stopped || w.stopped
+	}
+	// A limit can be hit by the last walkers running, which then end
+	// without noticing.
+	cause := context.Cause(ctx)
+	if stopped || cause == ErrNodeLimit || cause == ErrSolutionLimit {
+		return handlers, stats, cause
+	}
+	return handlers, stats, nil
+}
+
+func Solve(puzzle *Puzzle) *Solutions {
+	solutions, _, _ := SolveContext(context.Background(), puzzle)
+	return solutions
+}
+
+// SolveContext is Solve, stopping when ctx is done. It then returns the
+// solutions found so far and ctx.Err().
+func SolveContext(ctx context.Context, puzzle *Puzzle) (*Solutions, Stats, error) {
+	return SolveProgress(ctx, puzzle, nil)
+}
+
+// SolveProgress is SolveContext, reporting to progress as it goes.
+func SolveProgress(ctx context.Context, puzzle *Puzzle, progress *Progress) (*Solutions, Stats, error) {
+	handlers, stats, err := search(ctx, puzzle, func() SolutionHandler { return newSolutionStorer() }, progress)
 	to_return := make(Solutions, 0)
-	for _, solutions := range arr_solution_storer {
-		for _, solution := range solutions.solutions {
+	for _, h := range handlers {
+		for _, solution := range h.(*solutionStorer).solutions {
 			to_return = append(to_return, *solution)
 		}
 	}
 
-	return &to_return
+	return &to_return, stats, err
 }
 
 type Solutions []Solution
 
+// Print writes the solutions to stdout.
 func (this *Solutions) Print(printer SolutionPrinter) error {
-	printer.Print(this)
-	return nil
+	return printer.Print(os.Stdout, this)
 }
 
 func GetNumberOfSolutions(puzzle *Puzzle) int {
-	starting_points := puzzle.listStartingPoints()
-	arr_solutions_count := make([]solutionCounter, len(starting_points))
-	var wg sync.WaitGroup
+	n, _, _ := CountContext(context.Background(), puzzle)
+	return n
+}
 
-	for k, _ := range starting_points {
-		arr_solutions_count[k] = *newSolutionCounter()
-		wg.Add(1)
-		path := make([]uint16, 0)
-		pc := puzzle.Copy()
-		go findSolutions(&pc, &starting_points[k], path, &arr_solutions_count[k], 1, &wg)
-	}
+// CountContext is GetNumberOfSolutions, stopping when ctx is done. It then
+// returns the number of solutions found so far and ctx.Err().
+func CountContext(ctx context.Context, puzzle *Puzzle) (int, Stats, error) {
+	return CountProgress(ctx, puzzle, nil)
+}
 
-	wg.Wait()
+// CountProgress is CountContext, reporting to progress as it goes.
+func CountProgress(ctx context.Context, puzzle *Puzzle, progress *Progress) (int, Stats, error) {
+	handlers, stats, err := search(ctx, puzzle, func() SolutionHandler { return newSolutionCounter() }, progress)
 	to_return := 0
-	for _, solutions := range arr_solutions_count {
-		to_return = to_return + solutions.count_solutions
+	for _, h := range handlers {
+		to_return = to_return + h.(*solutionCounter).count_solutions
 	}
 
-	return to_return
+	return to_return, stats, err
 }
 
 func removeDuplicates(s []uint16) []uint16 {
@@ -1,8 +1,11 @@
 package solver
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -319,6 +322,14 @@ func TestNewPuzzleFromBytes(t *testing.T) {
 	}
 }
 
+func TestNewPuzzleFromBytesBraceAfterComment(t *testing.T) {
+	for _, data := range []string{"# c\n{\n", "// x\n{"} {
+		if _, err := NewPuzzleFromBytes([]byte(data)); err == nil {
+			t.Errorf("Expected an error for %q", data)
+		}
+	}
+}
+
 func TestSolveComplexPuzzle(t *testing.T) {
 	// House-like puzzle
 	edges := []Edge{
@@ -357,6 +368,28 @@ func TestSolveMultiEdge(t *testing.T) {
 	}
 }
 
+func TestSolveRepeatedEdges(t *testing.T) {
+	// The same edge listed twice, each to be drawn once.
+	p := NewPuzzle([]Edge{
+		{PointA: 1, PointB: 2, Count: 1},
+		{PointA: 1, PointB: 2, Count: 1},
+	})
+	if solutions := Solve(p); len(*solutions) != 2 {
+		t.Errorf("Expected 1-2-1 and 2-1-2, got %v", *solutions)
+	}
+
+	// Going from 2 to 1 must draw the directed edges, leaving the
+	// undirected one for the way back.
+	p = NewPuzzle([]Edge{
+		{PointA: 1, PointB: 2, Count: 1},
+		{PointA: 1, PointB: 2, Count: 2, Direction: Direction{From: 2, To: 1, Unidirectional: true}},
+	})
+	solutions := Solve(p)
+	if len(*solutions) != 1 || !reflect.DeepEqual((*solutions)[0], Solution{2, 1, 2, 1}) {
+		t.Errorf("Expected only 2-1-2-1, got %v", *solutions)
+	}
+}
+
 func TestGetNumberOfSolutionsComplexPuzzle(t *testing.T) {
 	edges := []Edge{
 		{PointA: 1, PointB: 2, Count: 1},
@@ -373,6 +406,232 @@ func TestGetNumberOfSolutionsComplexPuzzle(t *testing.T) {
 	}
 }
 
+func TestNewPuzzleFromBytesKeepsPoints(t *testing.T) {
+	jsonData := `{"Points": [{"Point": 1, "Level": 1}, {"Point": 2, "Level": 2, "X": 10, "Y": 20}],
+		"Edges": [{"PointA": 1, "PointB": 2, "Count": 1}]}`
+	p, err := NewPuzzleFromBytes([]byte(jsonData))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(p.Points) != 2 {
+		t.Fatalf("Expected 2 points, got %d", len(p.Points))
+	}
+	point := p.GetPoint(2)
+	if point == nil || point.Level != 2 || !point.HasPosition() {
+		t.Errorf("Expected point 2 on level 2 with a position, got %+v", point)
+	}
+	if p.GetPoint(3) != nil {
+		t.Error("Expected no point 3")
+	}
+
+	copy := p.Copy()
+	if len(copy.Points) != 2 {
+		t.Errorf("Expected Copy to keep 2 points, got %d", len(copy.Points))
+	}
+	copy.Points[0].Level = 9
+	if p.Points[0].Level != 1 {
+		t.Error("Original puzzle points were modified")
+	}
+}
+
+func TestPuzzleValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		puzzle  Puzzle
+		wantErr bool
+	}{
+		{
+			name:   "valid without points",
+			puzzle: Puzzle{Edges: []Edge{{PointA: 1, PointB: 2, Count: 1}}},
+		},
+		{
+			name: "valid with spacer point",
+			puzzle: Puzzle{
+				Points: []Point{{ID: 1}, {ID: 2}, {ID: -1, Hide: true}},
+				Edges:  []Edge{{PointA: 1, PointB: 2, Count: 1, Direction: Direction{From: 2, To: 1, Unidirectional: true}}},
+			},
+		},
+		{
+			name:    "no edges",
+			puzzle:  Puzzle{},
+			wantErr: true,
+		},
+		{
+			name:    "zero count",
+			puzzle:  Puzzle{Edges: []Edge{{PointA: 1, PointB: 2}}},
+			wantErr: true,
+		},
+		{
+			name:    "loop",
+			puzzle:  Puzzle{Edges: []Edge{{PointA: 1, PointB: 1, Count: 1}}},
+			wantErr: true,
+		},
+		{
+			name:    "direction on other points",
+			puzzle:  Puzzle{Edges: []Edge{{PointA: 1, PointB: 2, Count: 1, Direction: Direction{From: 2, To: 3, Unidirectional: true}}}},
+			wantErr: true,
+		},
+		{
+			name: "unknown point",
+			puzzle: Puzzle{
+				Points: []Point{{ID: 1}},
+				Edges:  []Edge{{PointA: 1, PointB: 2, Count: 1}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate point",
+			puzzle: Puzzle{
+				Points: []Point{{ID: 1}, {ID: 2}, {ID: 1}},
+				Edges:  []Edge{{PointA: 1, PointB: 2, Count: 1}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.puzzle.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSolveHonoursStartAndEndMarkers(t *testing.T) {
+	edges := []Edge{
+		{PointA: 1, PointB: 2, Count: 1},
+		{PointA: 2, PointB: 3, Count: 1},
+		{PointA: 3, PointB: 1, Count: 1},
+	}
+	p := NewPuzzle(edges)
+	p.Points = []Point{{ID: 1}, {ID: 2, Start: true}, {ID: 3}}
+
+	tmp, _ := json.Marshal(*Solve(p))
+	expected := "[[2,1,3,2],[2,3,1,2]]"
+	if string(tmp) != expected {
+		t.Errorf("Expected %s, got %s", expected, string(tmp))
+	}
+
+	// A triangle is a cycle, so no solution can start at 2 and end at 3.
+	p.Points[2].End = true
+	if n := GetNumberOfSolutions(p); n != 0 {
+		t.Errorf("Expected no solution ending at 3, got %d", n)
+	}
+
+	p.Points[1].Start = false
+	p.Points[2].End = false
+	if n := GetNumberOfSolutions(p); n != 6 {
+		t.Errorf("Expected 6 solutions without markers, got %d", n)
+	}
+}
+
+// The markers are read from JSON puzzle files too, which solved from every
+// point before they were honoured. Files without markers are unchanged.
+func TestSolveJsonPuzzleMarkers(t *testing.T) {
+	triangle := `{"Points": [{"Point": 1, "Level": 1}, {"Point": 2, "Level": 2%s}, {"Point": 3, "Level": 2%s}],
+		"Edges": [{"PointA": 1, "PointB": 2, "Count": 1}, {"PointA": 2, "PointB": 3, "Count": 1}, {"PointA": 3, "PointB": 1, "Count": 1}]}`
+	for _, c := range []struct {
+		start, end string
+		expected   string
+	}{
+		{"", "", "[[1,2,3,1],[1,3,2,1],[2,1,3,2],[2,3,1,2],[3,2,1,3],[3,1,2,3]]"},
+		{`, "Start": true`, "", "[[2,1,3,2],[2,3,1,2]]"},
+		{`, "Start": 1`, "", "[[2,1,3,2],[2,3,1,2]]"},
+		{"", `, "End": true`, "[[3,2,1,3],[3,1,2,3]]"},
+		{`, "Start": true`, `, "End": true`, "[]"},
+	} {
+		p, err := NewPuzzleFromBytes([]byte(fmt.Sprintf(triangle, c.start, c.end)))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if tmp, _ := json.Marshal(*Solve(p)); string(tmp) != c.expected {
+			t.Errorf("Start %q End %q: expected %s, got %s", c.start, c.end, c.expected, tmp)
+		}
+	}
+}
+
+func TestSolveStartingPointWithoutWayOut(t *testing.T) {
+	// 2 is a starting point but its only edge leads into it.
+	p := NewPuzzle([]Edge{
+		{PointA: 1, PointB: 2, Count: 1, Direction: Direction{From: 1, To: 2, Unidirectional: true}},
+	})
+	if n := GetNumberOfSolutions(p); n != 1 {
+		t.Errorf("Expected 1 solution, got %d", n)
+	}
+}
+
+func TestSolveContextStats(t *testing.T) {
+	p := NewPuzzle([]Edge{
+		{PointA: 1, PointB: 2, Count: 1},
+		{PointA: 2, PointB: 3, Count: 1},
+		{PointA: 3, PointB: 1, Count: 1},
+	})
+	solutions, stats, err := SolveContext(context.Background(), p)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(*solutions) != 6 {
+		t.Errorf("Expected 6 solutions, got %d", len(*solutions))
+	}
+	// 3 starting points, each with 2 ways around of 3 steps.
+	if stats.Nodes != 21 {
+		t.Errorf("Expected 21 nodes, got %d", stats.Nodes)
+	}
+	n, countStats, err := CountContext(context.Background(), p)
+	if err != nil || n != 6 || countStats != stats {
+		t.Errorf("Expected count to match solve, got %d %+v %v", n, countStats, err)
+	}
+}
+
+func TestCountContextCancelled(t *testing.T) {
+	// Enough edges for the walkers to check the context.
+	var edges []Edge
+	for i := uint16(1); i <= 6; i++ {
+		for j := i + 1; j <= 6; j++ {
+			edges = append(edges, Edge{PointA: i, PointB: j, Count: 1})
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, stats, err := CountContext(ctx, NewPuzzle(edges))
+	if err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if stats.Nodes == 0 {
+		t.Error("Expected the nodes explored before stopping")
+	}
+}
+
+func TestSolveProgress(t *testing.T) {
+	p := NewPuzzle([]Edge{
+		{PointA: 1, PointB: 2, Count: 1},
+		{PointA: 2, PointB: 3, Count: 1},
+		{PointA: 3, PointB: 1, Count: 1},
+	})
+	var mu sync.Mutex
+	var streamed Solutions
+	progress := &Progress{OnSolution: func(s Solution) {
+		mu.Lock()
+		streamed = append(streamed, s)
+		mu.Unlock()
+	}}
+	solutions, stats, err := SolveProgress(context.Background(), p, progress)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(streamed) != len(*solutions) || progress.Solutions.Load() != 6 {
+		t.Errorf("Expected the 6 solutions streamed, got %d and counted %d", len(streamed), progress.Solutions.Load())
+	}
+	if progress.Nodes.Load() != stats.Nodes {
+		t.Errorf("Expected %d nodes in the progress, got %d", stats.Nodes, progress.Nodes.Load())
+	}
+	if progress.Starts.Load() != 3 || progress.StartsDone.Load() != 3 {
+		t.Errorf("Expected 3 of 3 starting points done, got %d of %d", progress.StartsDone.Load(), progress.Starts.Load())
+	}
+}
+
 func BenchmarkSolveTriangle(b *testing.B) {
 	edges := []Edge{
 		{PointA: 1, PointB: 2, Count: 1},
@@ -480,3 +739,41 @@ func BenchmarkListStartingPoints(b *testing.B) {
 		_ = p.listStartingPoints()
 	}
 }
+
+func TestSearchLimits(t *testing.T) {
+	p := NewPuzzle([]Edge{
+		{PointA: 1, PointB: 2, Count: 1},
+		{PointA: 2, PointB: 3, Count: 1},
+		{PointA: 3, PointB: 1, Count: 1},
+	})
+	progress := &Progress{MaxSolutions: 6}
+	if n, _, err := CountProgress(context.Background(), p, progress); err != nil || n != 6 {
+		t.Errorf("Expected the 6 solutions within the limit, got %d %v", n, err)
+	}
+	progress = &Progress{MaxSolutions: 5}
+	solutions, _, err := SolveProgress(context.Background(), p, progress)
+	if err != ErrSolutionLimit || len(*solutions) > 5 {
+		t.Errorf("Expected ErrSolutionLimit with at most 5 solutions, got %d %v", len(*solutions), err)
+	}
+
+	var edges []Edge
+	for i := uint16(1); i <= 6; i++ {
+		for j := i + 1; j <= 6; j++ {
+			edges = append(edges, Edge{PointA: i, PointB: j, Count: 1})
+		}
+	}
+	progress = &Progress{MaxNodes: 100}
+	_, stats, err := CountProgress(context.Background(), NewPuzzle(edges), progress)
+	if err != ErrNodeLimit {
+		t.Errorf("Expected ErrNodeLimit, got %v", err)
+	}
+	if stats.Nodes <= 100 {
+		t.Errorf("Expected more than 100 nodes explored, got %d", stats.Nodes)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := CountProgress(ctx, NewPuzzle(edges), &Progress{MaxNodes: 1 << 40}); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,295 @@
+package solver
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// TextCodec reads and writes the compact .otd format, one statement per line:
+//
+//	# a comment
+//	1-2          edge between 1 and 2
+//	2-3 x2       edge drawn twice
+//	3>1          edge that can only be drawn from 3 to 1 (1<3 is the same)
+//	1-2-3        chain of edges
+//	start 1      start and end markers
+//	end 3
+//	point -1 level=2 hide
+//	point 4 level=1 label="top left" x=1.5 y=2
+//
+// Repeated edges add up. As soon as a point is described, every point on an
+// edge gets an entry in Points.
+type TextCodec struct{}
+
+func (this TextCodec) Encode(w io.Writer, puzzle *Puzzle) error {
+	bw := bufio.NewWriter(w)
+	for _, p := range puzzle.Points {
+		line := fmt.Sprintf("point %d", p.ID)
+		if p.Level != 0 {
+			line += fmt.Sprintf(" level=%d", p.Level)
+		}
+		if p.Label != "" {
+			line += " label=" + strconv.Quote(p.Label)
+		}
+		if p.HasPosition() {
+			line += " x=" + formatFloat(*p.X) + " y=" + formatFloat(*p.Y)
+		}
+		if p.Hide {
+			line += " hide"
+		}
+		fmt.Fprintln(bw, line)
+	}
+	for _, p := range puzzle.Points {
+		if p.Start {
+			fmt.Fprintf(bw, "start %d\n", p.ID)
+		}
+		if p.End {
+			fmt.Fprintf(bw, "end %d\n", p.ID)
+		}
+	}
+	for _, e := range puzzle.Edges {
+		op := "-"
+		if e.Direction.Unidirectional {
+			op = ">"
+			if e.Direction.From == e.PointB {
+				op = "<"
+			}
+		}
+		fmt.Fprintf(bw, "%d%s%d", e.PointA, op, e.PointB)
+		if e.Count != 1 {
+			fmt.Fprintf(bw, " x%d", e.Count)
+		}
+		fmt.Fprintln(bw)
+	}
+	return bw.Flush()
+}
+
+func (this TextCodec) Decode(data []byte) (*Puzzle, error) {
+	var points []Point
+	pointIndex := make(map[int]int)
+	describe := func(id int) *Point {
+		k, ok := pointIndex[id]
+		if !ok {
+			k = len(points)
+			pointIndex[id] = k
+			points = append(points, Point{ID: id})
+		}
+		return &points[k]
+	}
+
+	type edgeKey struct {
+		a, b uint16
+		from uint16
+	}
+	var edges []Edge
+	edgeIndex := make(map[edgeKey]int)
+	var onEdges []int
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if k := strings.IndexByte(text, '#'); k >= 0 && !strings.Contains(text[:k], `"`) {
+			text = strings.TrimSpace(text[:k])
+		}
+		if text == "" {
+			continue
+		}
+		fields, err := splitTextFields(text)
+		if err != nil {
+			return nil, fmt.Errorf("otd: line %d: %w", line, err)
+		}
+
+		switch fields[0] {
+		case "start", "end":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("otd: line %d: expected %s <point>", line, fields[0])
+			}
+			id, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("otd: line %d: invalid point %q", line, fields[1])
+			}
+			if fields[0] == "start" {
+				describe(id).Start = true
+			} else {
+				describe(id).End = true
+			}
+		case "point":
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("otd: line %d: expected point <id> [attributes]", line)
+			}
+			id, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("otd: line %d: invalid point %q", line, fields[1])
+			}
+			if err := parseTextPoint(describe(id), fields[2:]); err != nil {
+				return nil, fmt.Errorf("otd: line %d: %w", line, err)
+			}
+		default:
+			chain, ops, count, err := parseTextEdges(fields)
+			if err != nil {
+				return nil, fmt.Errorf("otd: line %d: %w", line, err)
+			}
+			for k, op := range ops {
+				e := Edge{PointA: chain[k], PointB: chain[k+1]}
+				switch op {
+				case '>':
+					e.Direction = Direction{From: e.PointA, To: e.PointB, Unidirectional: true}
+				case '<':
+					e.Direction = Direction{From: e.PointB, To: e.PointA, Unidirectional: true}
+				}
+				key := edgeKey{e.PointA, e.PointB, e.Direction.From}
+				if !e.Direction.Unidirectional && key.a > key.b {
+					key.a, key.b = key.b, key.a
+				}
+				if i, exists := edgeIndex[key]; exists {
+					if edges[i].Count > math.MaxUint16-count {
+						return nil, fmt.Errorf("otd: line %d: edge %d-%d drawn more than %d times", line, e.PointA, e.PointB, math.MaxUint16)
+					}
+					edges[i].Count += count
+					continue
+				}
+				e.Count = count
+				edgeIndex[key] = len(edges)
+				edges = append(edges, e)
+				onEdges = append(onEdges, int(e.PointA), int(e.PointB))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(points) > 0 {
+		for _, id := range onEdges {
+			describe(id)
+		}
+	}
+	np := NewPuzzle(edges)
+	np.Points = points
+	return np, nil
+}
+
+// splitTextFields splits a line on spaces, keeping quoted values such as
+// label="top left" in one field.
+func splitTextFields(text string) ([]string, error) {
+	var fields []string
+	var b strings.Builder
+	quoted := false
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '\\' && quoted && i+1 < len(text):
+			b.WriteByte(c)
+			b.WriteByte(text[i+1])
+			i++
+		case c == '"':
+			quoted = !quoted
+			b.WriteByte(c)
+		case (c == ' ' || c == '\t') && !quoted:
+			if b.Len() > 0 {
+				fields = append(fields, b.String())
+				b.Reset()
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if b.Len() > 0 {
+		fields = append(fields, b.String())
+	}
+	return fields, nil
+}
+
+func parseTextPoint(p *Point, attrs []string) error {
+	for _, attr := range attrs {
+		key, value, hasValue := strings.Cut(attr, "=")
+		var err error
+		switch key {
+		case "level":
+			p.Level, err = strconv.Atoi(value)
+		case "label":
+			p.Label, err = strconv.Unquote(value)
+			if err != nil && !strings.HasPrefix(value, `"`) {
+				p.Label, err = value, nil
+			}
+		case "x", "y":
+			var f float64
+			f, err = strconv.ParseFloat(value, 64)
+			if key == "x" {
+				p.X = &f
+			} else {
+				p.Y = &f
+			}
+		case "start", "end", "hide":
+			b := true
+			if hasValue {
+				b, err = strconv.ParseBool(value)
+			}
+			switch key {
+			case "start":
+				p.Start = b
+			case "end":
+				p.End = b
+			default:
+				p.Hide = b
+			}
+		default:
+			return fmt.Errorf("unknown point attribute %q", key)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", key, value)
+		}
+	}
+	return nil
+}
+
+// parseTextEdges reads a chain such as "1-2>3 x2": the points, the operator
+// between each pair and the count applied to every edge of the chain.
+func parseTextEdges(fields []string) (chain []uint16, ops []byte, count uint16, err error) {
+	count = 1
+	spec := fields[0]
+	rest := fields[1:]
+	// Allow spaces around operators: "1 - 2 x2".
+	for len(rest) > 0 && !strings.HasPrefix(rest[0], "x") {
+		spec += rest[0]
+		rest = rest[1:]
+	}
+	if len(rest) > 1 {
+		return nil, nil, 0, fmt.Errorf("unexpected %q", rest[1])
+	}
+	if len(rest) == 1 {
+		n, err := strconv.ParseUint(rest[0][1:], 10, 16)
+		if err != nil || n == 0 {
+			return nil, nil, 0, fmt.Errorf("invalid count %q", rest[0])
+		}
+		count = uint16(n)
+	}
+
+	start := 0
+	for i := 0; i <= len(spec); i++ {
+		if i < len(spec) && spec[i] != '-' && spec[i] != '>' && spec[i] != '<' {
+			continue
+		}
+		n, err := strconv.ParseUint(spec[start:i], 10, 16)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("invalid edge %q", spec)
+		}
+		chain = append(chain, uint16(n))
+		if i < len(spec) {
+			ops = append(ops, spec[i])
+		}
+		start = i + 1
+	}
+	if len(ops) == 0 {
+		return nil, nil, 0, fmt.Errorf("invalid edge %q", spec)
+	}
+	return chain, ops, count, nil
+}
@@ -0,0 +1,130 @@
+package solver
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTextRoundTripBundledPuzzles(t *testing.T) {
+	for name, p := range loadBundledPuzzles(t) {
+		var b bytes.Buffer
+		if err := (TextCodec{}).Encode(&b, p); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := TextCodec{}.Decode(b.Bytes())
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", name, err, b.String())
+		}
+		assertSamePuzzle(t, name, p, got)
+	}
+}
+
+func TestTextEncode(t *testing.T) {
+	x, y := 1.5, 2.0
+	p := NewPuzzle([]Edge{
+		{PointA: 1, PointB: 2, Count: 1},
+		{PointA: 2, PointB: 3, Count: 2},
+		{PointA: 3, PointB: 1, Count: 1, Direction: Direction{From: 3, To: 1, Unidirectional: true}},
+		{PointA: 1, PointB: 4, Count: 1, Direction: Direction{From: 4, To: 1, Unidirectional: true}},
+	})
+	p.Points = []Point{{ID: 1, Level: 1, Label: "top \"left\"", X: &x, Y: &y, Start: true}, {ID: -1, Hide: true}}
+
+	var b bytes.Buffer
+	TextCodec{}.Encode(&b, p)
+	expected := `point 1 level=1 label="top \"left\"" x=1.5 y=2
+point -1 hide
+start 1
+1-2
+2-3 x2
+3>1
+1<4
+`
+	if b.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, b.String())
+	}
+}
+
+func TestTextDecode(t *testing.T) {
+	src := `# quick sketch
+1-2
+2 - 3 x2   # spaces are fine
+3>1
+1<4
+4-5-6
+5-4
+start 1
+point 6 level=2 label="six # not a comment" end`
+	p, err := TextCodec{}.Decode([]byte(src))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expectedEdges := []Edge{
+		{PointA: 1, PointB: 2, Count: 1},
+		{PointA: 2, PointB: 3, Count: 2},
+		{PointA: 3, PointB: 1, Count: 1, Direction: Direction{From: 3, To: 1, Unidirectional: true}},
+		{PointA: 1, PointB: 4, Count: 1, Direction: Direction{From: 4, To: 1, Unidirectional: true}},
+		{PointA: 4, PointB: 5, Count: 2},
+		{PointA: 5, PointB: 6, Count: 1},
+	}
+	if !reflect.DeepEqual(p.Edges, expectedEdges) {
+		t.Errorf("Expected edges %+v, got %+v", expectedEdges, p.Edges)
+	}
+	if len(p.Points) != 6 {
+		t.Errorf("Expected every point on an edge to be listed, got %+v", p.Points)
+	}
+	if start := p.GetPoint(1); start == nil || !start.Start {
+		t.Errorf("Expected point 1 to be the start, got %+v", start)
+	}
+	six := p.GetPoint(6)
+	if six == nil || six.Level != 2 || six.Label != "six # not a comment" || !six.End {
+		t.Errorf("Expected point 6 described, got %+v", six)
+	}
+	if err := p.Validate(); err != nil {
+		t.Errorf("Expected a valid puzzle, got %v", err)
+	}
+}
+
+func TestTextDecodeErrors(t *testing.T) {
+	tests := map[string]string{
+		"single point":     "1",
+		"bad count":        "1-2 x0",
+		"extra field":      "1-2 x2 x3",
+		"negative edge":    "1--1",
+		"start argument":   "start",
+		"bad point":        "point a",
+		"bad attribute":    "point 1 color=red",
+		"bad level":        "point 1 level=top",
+		"unclosed quote":   `point 1 label="top`,
+		"edge over range":  "1-70000",
+		"count over range": "1-2-1 x40000",
+	}
+	for name, src := range tests {
+		_, err := TextCodec{}.Decode([]byte(src))
+		if err == nil {
+			t.Errorf("%s: expected error", name)
+		} else if !strings.HasPrefix(err.Error(), "otd: line 1: ") {
+			t.Errorf("%s: expected error with line number, got %v", name, err)
+		}
+	}
+}
+
+func TestNewPuzzleFromBytesSniffsFormat(t *testing.T) {
+	tests := map[string]string{
+		"json":      `{"Edges": [{"PointA": 1, "PointB": 2, "Count": 1}, {"PointA": 2, "PointB": 3, "Count": 1}]}`,
+		"edge list": `[{"PointA": 1, "PointB": 2, "Count": 1}, {"PointA": 2, "PointB": 3, "Count": 1}]`,
+		"dot":       "// sketch\ngraph { 1 -- 2 -- 3 }",
+		"text":      "# sketch\n1-2-3",
+	}
+	for name, src := range tests {
+		p, err := NewPuzzleFromBytes([]byte(src))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if len(p.Edges) != 2 || p.count != 2 {
+			t.Errorf("%s: expected 2 edges, got %+v", name, p.Edges)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/wricardo/OneTDraw-Solver/solver"
+)
+
+const defaultStreamInterval = 500 * time.Millisecond
+
+// streamStats is the data of the progress and done events of a stream.
+type streamStats struct {
+	jobProgress
+	Solutions int64 `json:"solutions"`
+	// Status and Error are only set in the done event.
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func newStreamStats(progress *solver.Progress) streamStats {
+	return streamStats{
+		jobProgress: jobProgress{
+			Nodes:      progress.Nodes.Load(),
+			Starts:     progress.Starts.Load(),
+			StartsDone: progress.StartsDone.Load(),
+		},
+		Solutions: progress.Solutions.Load(),
+	}
+}
+
+// writeEvent writes one server-sent event with v as JSON data.
+func writeEvent(w io.Writer, event string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
+
+// streamSolutions solves a puzzle as server-sent events: a solution event
+// with every solution as it is found, a progress event every interval
+// milliseconds and a done event at the end. Closing the connection stops
+// the search.
+func (this *Server) streamSolutions(w http.ResponseWriter, r *http.Request) error {
+	puzzle, err := this.puzzle(r)
+	if err != nil {
+		return err
+	}
+	interval := defaultStreamInterval
+	if param := r.URL.Query().Get("interval"); param != "" {
+		ms, err := strconv.Atoi(param)
+		if err != nil || ms <= 0 {
+			return httpErrorf(http.StatusBadRequest, "invalid interval %q", param)
+		}
+		interval = time.Duration(ms) * time.Millisecond
+	}
+
+	limits, err := this.limits(r)
+	if err != nil {
+		return err
+	}
+	hash := puzzle.CanonicalHash()
+	this.cache.seen(r.PathValue("filename"), hash)
+	cached, _ := this.cache.get(hash, true)
+	if cached != nil {
+		if err := limits.check(len(cached.Solutions)); err != nil {
+			return this.limitError(err, limits)
+		}
+	} else {
+		if err := this.acquireSlot(w); err != nil {
+			return err
+		}
+		defer this.slots.release()
+	}
+
+	rc := http.NewResponseController(w)
+	// The stream lasts as long as the search, past the server write timeout.
+	rc.SetWriteDeadline(time.Time{})
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	found := make(chan solver.Solution, 64)
+	progress := limits.apply(&solver.Progress{OnSolution: func(s solver.Solution) {
+		select {
+		case found <- s:
+		case <-ctx.Done():
+		}
+	}})
+	result := make(chan error, 1)
+	go func() {
+		if cached != nil {
+			// Replayed from the cache, as fast as the client takes them.
+			for _, s := range cached.Solutions {
+				progress.Solutions.Add(1)
+				progress.OnSolution(s)
+			}
+			result <- ctx.Err()
+			return
+		}
+		done := this.metrics.search(progress)
+		solutions, _, err := solver.SolveProgress(ctx, puzzle, progress)
+		done()
+		if err == nil {
+			this.cache.put(&cacheEntry{Hash: hash, Count: len(*solutions), Solutions: *solutions})
+		}
+		result <- err
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	// send writes an event and flushes it. Once the client is gone there is
+	// no one to report an error to, so it only stops the search.
+	send := func(event string, v interface{}) bool {
+		if err := writeEvent(w, event, v); err != nil {
+			cancel()
+			return false
+		}
+		if err := rc.Flush(); err != nil {
+			cancel()
+			return false
+		}
+		return true
+	}
+
+	for {
+		select {
+		case s := <-found:
+			send("solution", s)
+		case <-ticker.C:
+			send("progress", newStreamStats(progress))
+		case err := <-result:
+			// The walkers are done, only the buffered solutions are left.
+			for len(found) > 0 {
+				send("solution", <-found)
+			}
+			stats := newStreamStats(progress)
+			stats.Status = jobDone
+			switch {
+			case limitReason(err) != "":
+				this.metrics.reject(limitReason(err))
+				stats.Status = jobFailed
+				stats.Error = limits.describe(err).Error()
+			case err != nil:
+				stats.Status = jobCanceled
+				stats.Error = err.Error()
+			}
+			send("done", stats)
+			return nil
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type event struct {
+	name string
+	data string
+}
+
+func readEvents(t *testing.T, body string) []event {
+	t.Helper()
+	var events []event
+	for _, block := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		var e event
+		for _, line := range strings.Split(block, "\n") {
+			if name, ok := strings.CutPrefix(line, "event: "); ok {
+				e.name = name
+			} else if data, ok := strings.CutPrefix(line, "data: "); ok {
+				e.data = data
+			}
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestStreamSolutions(t *testing.T) {
+	w := serve(t, "/puzzle/stream/house")
+	if w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Expected an event stream, got %q", w.Header().Get("Content-Type"))
+	}
+	events := readEvents(t, w.Body.String())
+	solutions := 0
+	for _, e := range events[:len(events)-1] {
+		if e.name == "solution" {
+			solutions++
+		}
+	}
+	if solutions != 88 {
+		t.Errorf("Expected 88 solution events, got %d", solutions)
+	}
+
+	last := events[len(events)-1]
+	var stats streamStats
+	if err := json.Unmarshal([]byte(last.data), &stats); last.name != "done" || err != nil {
+		t.Fatalf("Expected a done event last, got %+v", last)
+	}
+	if stats.Status != jobDone || stats.Solutions != 88 || stats.Nodes == 0 {
+		t.Errorf("Unexpected done event %+v", stats)
+	}
+
+	assertJsonError(t, serve(t, "/puzzle/stream/nope"), 404, "not found")
+	assertJsonError(t, serve(t, "/puzzle/stream/house?interval=0"), 400, "invalid interval")
+}
+
+func TestStreamStopsWithTheClient(t *testing.T) {
+	ts := httptest.NewServer(newTestServer(t, ServerOptions{Workers: 1}))
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/puzzle/stream/level54?interval=10")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for progress, then hang up: the search must stop rather than run
+	// for minutes, which ts.Close would wait for.
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Stream ended before any progress: %v", err)
+		}
+		if line == "event: progress\n" {
+			break
+		}
+	}
+	resp.Body.Close()
+
+	done := make(chan struct{})
+	go func() {
+		ts.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the search to stop when the client went away")
+	}
+}
@@ -1,69 +1,311 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
-	"os"
-	"os/signal"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
-
-	"github.com/stretchr/goweb"
-	"github.com/stretchr/goweb/context"
-	"github.com/stretchr/goweb/responders"
 )
 
-type Webserver struct {
-	Address string
+// Server is the web interface and its JSON API. The zero value is not
+// usable, create it with NewServer.
+type Server struct {
+	// PuzzleDir holds the puzzle files served by name.
+	PuzzleDir string
+	// Index is the puzzles.json listing the puzzles shown in the UI.
+	Index string
+	// UserPuzzleDir holds the puzzles added through the API, listed in its
+	// own puzzles.json after those of Index. The puzzles of PuzzleDir
+	// cannot be changed through the API.
+	UserPuzzleDir string
+	// StaticDir holds the UI, served under /static/.
+	StaticDir string
+
+	mux   *http.ServeMux
+	jobs  *jobQueue
+	games *gameStore
+	cache *solveCache
+	// slots, limiter and the max fields are the limits of the searches,
+	// see ServerOptions.
+	slots           solveSlots
+	limiter         *rateLimiter
+	maxNodes        int64
+	maxSolutions    int64
+	maxJobNodes     int64
+	maxJobSolutions int64
+	solveTimeout    time.Duration
+	// puzzleToken is the bearer token of the routes changing puzzles.
+	puzzleToken string
+	// metrics counts what is served, and closing is set by Close.
+	metrics *serverMetrics
+	closing atomic.Bool
+	// storeMu serializes the changes to the puzzle files and the index.
+	storeMu sync.Mutex
 }
 
-func (this *Webserver) init() {
-	this.turnOffAutoEnvelop()
-	mapRoutes()
-	goweb.MapStatic("static/", "static")
+// ServerOptions tunes the background jobs of a Server. Zero values get the
+// defaults.
+type ServerOptions struct {
+	// Workers is the number of jobs run at once, the number of CPUs by
+	// default.
+	Workers int
+	// QueueSize is the number of jobs that can wait for a worker.
+	QueueSize int
+	// JobRetention is how long the results of a finished job are kept.
+	JobRetention time.Duration
+	// CacheEntries and CacheSolutions limit the number of puzzles and of
+	// solutions kept in memory by the cache of search results.
+	CacheEntries   int
+	CacheSolutions int
+	// CacheDir, when set, is where the cache also keeps its results, so
+	// that they survive restarts.
+	CacheDir string
+	// SessionDir is where the games played in the browser are saved,
+	// "sessions" by default.
+	SessionDir string
+	// SessionIdle is how long a game no one plays stays in memory, after
+	// which it is read from SessionDir again when needed.
+	SessionIdle time.Duration
+	// SessionExpiry is how long the file of a game no one plays is kept,
+	// 30 days by default.
+	SessionExpiry time.Duration
+	// MaxSolves is the number of searches run at once, by requests and
+	// jobs together, the number of CPUs by default. Requests finding them
+	// all taken get a 503, jobs wait.
+	MaxSolves int
+	// MaxNodes and MaxSolutions cap the searches run within a request, the
+	// request failing with a 503 past them. Zero is no limit.
+	MaxNodes     int64
+	MaxSolutions int64
+	// MaxJobNodes and MaxJobSolutions cap the searches of the jobs, which
+	// can ask for lower caps but not higher ones. Zero is no limit.
+	MaxJobNodes     int64
+	MaxJobSolutions int64
+	// SolveTimeout stops the searches run within a request, the request
+	// failing with a 503 past it. Zero is no limit; Webserver keeps it
+	// within its WriteTimeout.
+	SolveTimeout time.Duration
+	// RateLimit is the number of solve requests per second a client can
+	// send, after a burst of RateBurst. Zero is no limit.
+	RateLimit float64
+	RateBurst int
+	// PuzzleToken is the bearer token the requests adding, updating or
+	// deleting puzzles must send. Puzzles cannot be changed without one.
+	PuzzleToken string
+}
 
-	s := &http.Server{
-		Addr:           this.Address,
-		Handler:        goweb.DefaultHttpHandler(),
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
-		MaxHeaderBytes: 1 << 20,
+// NewServer returns a server reading its files from the working directory,
+// as laid out in the repository. Close stops its workers.
+func NewServer(opts ServerOptions) *Server {
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaultJobQueue
+	}
+	if opts.JobRetention <= 0 {
+		opts.JobRetention = defaultJobRetention
+	}
+	if opts.CacheEntries <= 0 {
+		opts.CacheEntries = defaultCacheEntries
+	}
+	if opts.CacheSolutions <= 0 {
+		opts.CacheSolutions = defaultCacheSolutions
+	}
+	if opts.SessionDir == "" {
+		opts.SessionDir = defaultSessionDir
+	}
+	if opts.SessionIdle <= 0 {
+		opts.SessionIdle = defaultSessionIdle
 	}
+	if opts.SessionExpiry <= 0 {
+		opts.SessionExpiry = defaultSessionExpiry
+	}
+	if opts.MaxSolves <= 0 {
+		opts.MaxSolves = runtime.NumCPU()
+	}
+	s := &Server{PuzzleDir: "puzzles", Index: "puzzles.json", UserPuzzleDir: defaultUserPuzzleDir, StaticDir: "static"}
+	s.cache = newSolveCache(opts.CacheEntries, opts.CacheSolutions, opts.CacheDir)
+	s.slots = newSolveSlots(opts.MaxSolves)
+	s.limiter = newRateLimiter(opts.RateLimit, opts.RateBurst)
+	s.maxNodes, s.maxSolutions = opts.MaxNodes, opts.MaxSolutions
+	s.maxJobNodes, s.maxJobSolutions = opts.MaxJobNodes, opts.MaxJobSolutions
+	s.solveTimeout = opts.SolveTimeout
+	s.puzzleToken = opts.PuzzleToken
+	s.metrics = newServerMetrics()
+	s.jobs = newJobQueue(opts.Workers, opts.QueueSize, opts.JobRetention, s.cache, s.metrics, s.slots)
+	s.games = newGameStore(opts.SessionDir, opts.SessionIdle, opts.SessionExpiry)
+	s.mux = http.NewServeMux()
+	s.routes()
+	return s
+}
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	listener, listenErr := net.Listen("tcp", this.Address)
+func (this *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	this.instrument(w, r)
+}
 
-	if listenErr != nil {
-		log.Fatalf("Could not listen: %s", listenErr)
+// Shutdown stops taking jobs and waits for the queued and running ones to
+// finish, canceling those left when ctx is done. /readyz fails from then
+// on.
+func (this *Server) Shutdown(ctx context.Context) {
+	this.closing.Store(true)
+	this.jobs.close(ctx)
+}
+
+// Close is Shutdown without waiting: the jobs are canceled at once.
+func (this *Server) Close() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	this.Shutdown(ctx)
+}
+
+// handlerFunc is a route of the JSON API. Returning an *httpError sets the
+// status of the error body, any other error is a 500.
+type handlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+func (this handlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	err := this(w, r)
+	if err == nil {
+		return
+	}
+	var he *httpError
+	if !errors.As(err, &he) {
+		he = &httpError{Status: http.StatusInternalServerError, Err: err}
 	}
-	fmt.Printf("Webserver listening on %s", this.Address)
+	// A 503 is the server turning work down, not failing.
+	if he.Status >= 500 && he.Status != http.StatusServiceUnavailable {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, he.Err)
+	}
+	writeJson(w, he.Status, errorResponse{Error: he.Err.Error()})
+}
 
-	goweb.Map(func(c context.Context) error {
-		return goweb.API.Respond(c, 404, nil, []string{"File not found"})
-	})
+type httpError struct {
+	Status int
+	Err    error
+}
 
-	this.registerShutdownFunction(listener, c)
+func (this *httpError) Error() string {
+	return this.Err.Error()
+}
 
-	log.Fatalf("Error in Serve: %s", s.Serve(listener))
+func httpErrorf(status int, format string, a ...interface{}) *httpError {
+	return &httpError{Status: status, Err: fmt.Errorf(format, a...)}
 }
 
-func (this *Webserver) turnOffAutoEnvelop() {
-	apiResponder := responders.NewGowebAPIResponder(goweb.CodecService, goweb.Respond)
-	apiResponder.AlwaysEnvelopResponse = false
-	goweb.API = apiResponder
+// errorResponse is the body of every failed API request.
+type errorResponse struct {
+	Error string `json:"error"`
 }
 
-func (this *Webserver) registerShutdownFunction(listener net.Listener, c chan os.Signal) {
-	go func() {
-		for _ = range c {
-			log.Print("Stopping the server...")
-			listener.Close()
+func writeJson(w http.ResponseWriter, status int, v interface{}) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
 
-			log.Print("Tearing down...")
+// Webserver serves a Server over HTTP, or HTTPS with TLSCert and TLSKey,
+// until its context is done.
+type Webserver struct {
+	Address string
+	Options ServerOptions
+	// PuzzleDir, Index, UserPuzzleDir and StaticDir, when set, replace the
+	// paths of the repository layout.
+	PuzzleDir     string
+	Index         string
+	UserPuzzleDir string
+	StaticDir     string
+
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// ShutdownTimeout is how long the jobs and the requests in flight are
+	// waited for once the context is done, before they are cut.
+	ShutdownTimeout time.Duration
 
-			log.Fatal("Finished - bye bye. ;-)")
+	TLSCert string
+	TLSKey  string
+}
+
+// Run listens on Address and serves until ctx is done.
+func (this *Webserver) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", this.Address)
+	if err != nil {
+		return fmt.Errorf("could not listen: %v", err)
+	}
+	return this.Serve(ctx, listener)
+}
+
+// Serve serves on listener until ctx is done, then shuts down gracefully:
+// the server stops taking connections and jobs, and the jobs and requests
+// in flight get ShutdownTimeout to finish.
+func (this *Webserver) Serve(ctx context.Context, listener net.Listener) error {
+	if (this.TLSCert == "") != (this.TLSKey == "") {
+		listener.Close()
+		return errors.New("TLS needs both a certificate and a key")
+	}
+	// A search still running when the response can no longer be written
+	// would leave the client with no answer at all.
+	opts := this.Options
+	if limit := this.WriteTimeout * 4 / 5; limit > 0 && (opts.SolveTimeout <= 0 || opts.SolveTimeout > limit) {
+		opts.SolveTimeout = limit
+	}
+	server := NewServer(opts)
+	if this.PuzzleDir != "" {
+		server.PuzzleDir = this.PuzzleDir
+	}
+	if this.Index != "" {
+		server.Index = this.Index
+	}
+	if this.UserPuzzleDir != "" {
+		server.UserPuzzleDir = this.UserPuzzleDir
+	}
+	if this.StaticDir != "" {
+		server.StaticDir = this.StaticDir
+	}
+	s := &http.Server{
+		Handler:        server,
+		ReadTimeout:    this.ReadTimeout,
+		WriteTimeout:   this.WriteTimeout,
+		IdleTimeout:    this.IdleTimeout,
+		MaxHeaderBytes: 1 << 20,
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		if this.TLSCert != "" {
+			log.Printf("Webserver listening on https://%s", listener.Addr())
+			served <- s.ServeTLS(listener, this.TLSCert, this.TLSKey)
+		} else {
+			log.Printf("Webserver listening on http://%s", listener.Addr())
+			served <- s.Serve(listener)
 		}
 	}()
+	select {
+	case err := <-served:
+		server.Close()
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down, waiting up to %s for the jobs and requests in flight...", this.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), this.ShutdownTimeout)
+	defer cancel()
+	// The jobs are waited for first, so that their results can still be
+	// fetched while they finish.
+	server.Shutdown(shutdownCtx)
+	err := s.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Printf("Requests still in flight, closing their connections: %v", err)
+		s.Close()
+	}
+	<-served
+	log.Print("Stopped")
+	return nil
 }